@@ -11,6 +11,7 @@ type Arithmetic struct {
 	Op    ArithmeticOp
 	Exprs []Node
 	Posx  Pos
+	Endx  Pos
 }
 
 func (n *Arithmetic) Accept(v Visitor) Node {
@@ -25,6 +26,10 @@ func (n *Arithmetic) Pos() Pos {
 	return n.Posx
 }
 
+func (n *Arithmetic) End() Pos {
+	return n.Endx
+}
+
 func (n *Arithmetic) GoString() string {
 	return fmt.Sprintf("*%#v", *n)
 }
@@ -17,15 +17,37 @@ type Node interface {
 	Type(Scope) (Type, error)
 }
 
-// Pos is the starting position of an AST node
+// Pos is a position in some source. Nodes have a range of positions:
+// Pos returns the position of their first character and End, if they
+// implement Ranged, the position just past their last one.
 type Pos struct {
 	Column, Line int // Column/Line number, starting at 1
+	Offset       int // Byte offset, starting at 0
 }
 
 func (p Pos) String() string {
 	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
+// Ranged is implemented by nodes that know where they end in the source.
+// All nodes in this package implement it. Nodes that aren't parsed but
+// replace other nodes, such as implicit conversions, have the range of the
+// nodes they replace.
+type Ranged interface {
+	// End returns the position just past the end of this node.
+	End() Pos
+}
+
+// End returns the position just past the end of n. Nodes that don't
+// implement Ranged are assumed to end where they start.
+func End(n Node) Pos {
+	if r, ok := n.(Ranged); ok {
+		return r.End()
+	}
+
+	return n.Pos()
+}
+
 // Visitors are just implementations of this function.
 //
 // The function must return the Node to replace this node with. "nil" is
@@ -39,11 +61,15 @@ func (p Pos) String() string {
 // generally requires proper type dispatch on the function. However,
 // implementing this basic visitor pattern style is still very useful even
 // if you have to type switch.
+//
+// Nodes are visited in post-order, children before their parents. See
+// Inspect and Rewrite for walks that need to know the parents of a node,
+// skip subtrees or stop early.
 type Visitor func(Node) Node
 
-//go:generate stringer -type=Type
-
-// Type is the type of any value.
+// Type is the type of any value. Besides the basic types below, there
+// are composite types such as List(TString), which are created by the
+// functions in type.go.
 type Type uint32
 
 const (
@@ -75,7 +101,11 @@ func (t Type) Printable() string {
 		return "type map"
 	case TBool:
 		return "type bool"
-	default:
-		return "unknown type"
 	}
+
+	if _, ok := t.composite(); ok {
+		return "type " + t.name()
+	}
+
+	return "unknown type"
 }
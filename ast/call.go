@@ -9,13 +9,32 @@ import (
 type Call struct {
 	Func string
 	Args []Node
+
+	// NamedArgs are the arguments passed by name, such as width = 10.
+	// They always follow the positional Args. The type checker binds them
+	// to their parameters, see Bind.
+	NamedArgs []*NamedArg
+
 	Posx Pos
+	Endx Pos
+}
+
+// NamedArg is an argument passed to a function by the name of the
+// parameter, such as width = 10.
+type NamedArg struct {
+	Name  string
+	Value Node
+	Posx  Pos
+	Endx  Pos
 }
 
 func (n *Call) Accept(v Visitor) Node {
 	for i, a := range n.Args {
 		n.Args[i] = a.Accept(v)
 	}
+	for _, a := range n.NamedArgs {
+		a.Value = a.Value.Accept(v)
+	}
 
 	return v(n)
 }
@@ -24,11 +43,28 @@ func (n *Call) Pos() Pos {
 	return n.Posx
 }
 
+func (n *Call) End() Pos {
+	return n.Endx
+}
+
+// Pos returns the position of the name of the argument.
+func (n *NamedArg) Pos() Pos {
+	return n.Posx
+}
+
+// End returns the position just past the end of the value.
+func (n *NamedArg) End() Pos {
+	return n.Endx
+}
+
 func (n *Call) String() string {
-	args := make([]string, len(n.Args))
+	args := make([]string, len(n.Args), len(n.Args)+len(n.NamedArgs))
 	for i, arg := range n.Args {
 		args[i] = fmt.Sprintf("%s", arg)
 	}
+	for _, arg := range n.NamedArgs {
+		args = append(args, fmt.Sprintf("%s = %s", arg.Name, arg.Value))
+	}
 
 	return fmt.Sprintf("Call(%s, %s)", n.Func, strings.Join(args, ", "))
 }
@@ -39,9 +75,96 @@ func (n *Call) Type(s Scope) (Type, error) {
 		return TUnsupported, fmt.Errorf("unknown function: %s", n.Func)
 	}
 
-	return f.ReturnType, nil
+	// The return type can only be known without the argument types if
+	// all signatures agree on it.
+	sigs := f.Signatures()
+	for _, sig := range sigs {
+		if sig.ReturnTypeFunc != nil || sig.ReturnType != sigs[0].ReturnType {
+			return TUnsupported, fmt.Errorf(
+				"return type of %s depends on its arguments", n.Func)
+		}
+	}
+
+	return sigs[0].ReturnType, nil
 }
 
 func (n *Call) GoString() string {
 	return fmt.Sprintf("*%#v", *n)
 }
+
+func (n *NamedArg) GoString() string {
+	return fmt.Sprintf("*%#v", *n)
+}
+
+// Bind returns the arguments of the call in the order of the parameters
+// of f. Named arguments are moved to the position of their parameter and
+// omitted optional parameters are filled in with literals of their
+// defaults. It is an error to pass an unknown or the same parameter more
+// than once, or to omit a required parameter.
+func (n *Call) Bind(f Function) ([]Node, error) {
+	if len(n.NamedArgs) == 0 && len(n.Args) >= len(f.ArgTypes) {
+		return n.Args, nil
+	}
+	if len(f.Overloads) > 0 && len(n.NamedArgs) > 0 {
+		return nil, fmt.Errorf(
+			"%s: overloaded functions can't be called with named arguments", n.Func)
+	}
+
+	size := len(f.ArgTypes)
+	if len(n.Args) > size {
+		size = len(n.Args)
+	}
+	bound := make([]Node, size)
+	copy(bound, n.Args)
+
+	for _, a := range n.NamedArgs {
+		i := -1
+		for j, name := range f.ArgNames {
+			if name == a.Name {
+				i = j
+				break
+			}
+		}
+
+		if i < 0 {
+			return nil, fmt.Errorf("%s: unknown argument %q", n.Func, a.Name)
+		}
+		if bound[i] != nil {
+			return nil, fmt.Errorf(
+				"%s: argument %q is given more than once", n.Func, a.Name)
+		}
+
+		bound[i] = a.Value
+	}
+
+	var missing []string
+	required := len(f.ArgTypes) - len(f.Defaults)
+	for i := range f.ArgTypes {
+		if bound[i] != nil {
+			continue
+		}
+
+		if i >= required {
+			d := f.Defaults[i-required]
+			bound[i] = &LiteralNode{
+				Value: d.Value,
+				Typex: d.Type,
+				Posx:  n.Posx,
+				Endx:  n.Endx,
+			}
+			continue
+		}
+
+		if i < len(f.ArgNames) {
+			missing = append(missing, fmt.Sprintf("%q", f.ArgNames[i]))
+		} else {
+			missing = append(missing, fmt.Sprintf("%d", i+1))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf(
+			"%s: missing required argument %s", n.Func, strings.Join(missing, ", "))
+	}
+
+	return bound, nil
+}
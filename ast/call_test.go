@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -34,3 +35,91 @@ func TestCallType_invalid(t *testing.T) {
 		t.Fatal("should error")
 	}
 }
+
+func TestCallType_overloads(t *testing.T) {
+	c := &Call{Func: "foo"}
+	scope := &BasicScope{
+		FuncMap: map[string]Function{
+			"foo": Function{
+				Overloads: []Function{
+					{ArgTypes: []Type{TInt}, ReturnType: TString},
+					{ArgTypes: []Type{TFloat}, ReturnType: TString},
+				},
+			},
+			"bar": Function{
+				Overloads: []Function{
+					{ArgTypes: []Type{TInt}, ReturnType: TInt},
+					{ArgTypes: []Type{TFloat}, ReturnType: TFloat},
+				},
+			},
+		},
+	}
+
+	actual, err := c.Type(scope)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual != TString {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	c = &Call{Func: "bar"}
+	if _, err := c.Type(scope); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestCallBind(t *testing.T) {
+	f := Function{
+		ArgTypes: []Type{TString, TInt, TBool},
+		ArgNames: []string{"value", "width"},
+		Defaults: []Variable{{Type: TBool, Value: true}},
+	}
+
+	value := &LiteralNode{Value: "foo", Typex: TString}
+	width := &LiteralNode{Value: 10, Typex: TInt}
+	cases := []struct {
+		Call   *Call
+		Result []Node
+		Error  string
+	}{
+		{
+			&Call{Func: "f", Args: []Node{value, width}},
+			[]Node{value, width, &LiteralNode{Value: true, Typex: TBool}},
+			"",
+		},
+		{
+			&Call{
+				Func:      "f",
+				NamedArgs: []*NamedArg{{Name: "width", Value: width}, {Name: "value", Value: value}},
+			},
+			[]Node{value, width, &LiteralNode{Value: true, Typex: TBool}},
+			"",
+		},
+		{
+			&Call{Func: "f", Args: []Node{value}},
+			nil,
+			`f: missing required argument "width"`,
+		},
+		{
+			&Call{Func: "f", NamedArgs: []*NamedArg{{Name: "value", Value: value}}},
+			nil,
+			`f: missing required argument "width"`,
+		},
+		{
+			&Call{Func: "f", NamedArgs: []*NamedArg{{Name: "pad", Value: value}}},
+			nil,
+			`f: unknown argument "pad"`,
+		},
+	}
+
+	for _, tc := range cases {
+		actual, err := tc.Call.Bind(f)
+		if (err != nil) != (tc.Error != "") || (err != nil && err.Error() != tc.Error) {
+			t.Fatalf("%s: err: %s", tc.Call, err)
+		}
+		if !reflect.DeepEqual(actual, tc.Result) {
+			t.Fatalf("%s: bad: %#v", tc.Call, actual)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package ast
+
+// ChainedScope is a scope that looks up variables and functions in its own
+// maps first and falls back to its Parent scope if they aren't found there.
+// Entries in the maps shadow entries of the same name in the parent.
+//
+// The parent is never modified, so a large shared scope can be used as the
+// parent of many short-lived scopes (for example one per request) without
+// having to copy it.
+type ChainedScope struct {
+	Parent  Scope
+	FuncMap map[string]Function
+	VarMap  map[string]Variable
+}
+
+// WithVariables returns a scope that contains the given variables on top
+// of the parent scope. Neither the parent nor the map are copied.
+func WithVariables(parent Scope, vars map[string]Variable) *ChainedScope {
+	return &ChainedScope{Parent: parent, VarMap: vars}
+}
+
+// WithFunctions returns a scope that contains the given functions on top
+// of the parent scope. Neither the parent nor the map are copied.
+func WithFunctions(parent Scope, funcs map[string]Function) *ChainedScope {
+	return &ChainedScope{Parent: parent, FuncMap: funcs}
+}
+
+func (s *ChainedScope) LookupFunc(n string) (Function, bool) {
+	if s == nil {
+		return Function{}, false
+	}
+
+	if v, ok := s.FuncMap[n]; ok {
+		return v, true
+	}
+
+	if s.Parent == nil {
+		return Function{}, false
+	}
+
+	return s.Parent.LookupFunc(n)
+}
+
+func (s *ChainedScope) LookupVar(n string) (Variable, bool) {
+	if s == nil {
+		return Variable{}, false
+	}
+
+	if v, ok := s.VarMap[n]; ok {
+		return v, true
+	}
+
+	if s.Parent == nil {
+		return Variable{}, false
+	}
+
+	return s.Parent.LookupVar(n)
+}
+
+// HasVar implements VarExister so that existence checks are passed on to
+// the parent scope.
+func (s *ChainedScope) HasVar(n string) bool {
+	if s == nil {
+		return false
+	}
+
+	if _, ok := s.VarMap[n]; ok {
+		return true
+	}
+
+	return HasVar(s.Parent, n)
+}
+
+// VarNames implements NameLister, including the names of the parent if it
+// implements NameLister as well.
+func (s *ChainedScope) VarNames() []string {
+	if s == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(s.VarMap))
+	for n := range s.VarMap {
+		names = append(names, n)
+	}
+
+	return mergeNames(names, VarNames(s.Parent))
+}
+
+// FuncNames implements NameLister, including the names of the parent if it
+// implements NameLister as well.
+func (s *ChainedScope) FuncNames() []string {
+	if s == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(s.FuncMap))
+	for n := range s.FuncMap {
+		names = append(names, n)
+	}
+
+	return mergeNames(names, FuncNames(s.Parent))
+}
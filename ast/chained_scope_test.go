@@ -0,0 +1,78 @@
+package ast
+
+import (
+	"testing"
+)
+
+func TestChainedScope_impl(t *testing.T) {
+	var _ Scope = new(ChainedScope)
+}
+
+func TestChainedScopeLookupFunc(t *testing.T) {
+	parent := &BasicScope{
+		FuncMap: map[string]Function{
+			"foo": Function{ReturnType: TString},
+			"bar": Function{ReturnType: TString},
+		},
+	}
+	scope := WithFunctions(parent, map[string]Function{
+		"bar": Function{ReturnType: TInt},
+		"baz": Function{ReturnType: TInt},
+	})
+
+	if f, ok := scope.LookupFunc("foo"); !ok || f.ReturnType != TString {
+		t.Fatalf("should find foo in parent: %#v", f)
+	}
+	if f, ok := scope.LookupFunc("bar"); !ok || f.ReturnType != TInt {
+		t.Fatalf("bar should be shadowed: %#v", f)
+	}
+	if _, ok := scope.LookupFunc("baz"); !ok {
+		t.Fatal("should find baz")
+	}
+	if _, ok := scope.LookupFunc("qux"); ok {
+		t.Fatal("should not find qux")
+	}
+	if _, ok := parent.LookupFunc("baz"); ok {
+		t.Fatal("parent should not be modified")
+	}
+}
+
+func TestChainedScopeLookupVar(t *testing.T) {
+	parent := &BasicScope{
+		VarMap: map[string]Variable{
+			"foo": Variable{Type: TString, Value: "foo"},
+			"bar": Variable{Type: TString, Value: "bar"},
+		},
+	}
+	scope := WithVariables(WithVariables(parent, map[string]Variable{
+		"bar": Variable{Type: TInt, Value: 42},
+	}), map[string]Variable{
+		"baz": Variable{Type: TInt, Value: 12},
+	})
+
+	if v, ok := scope.LookupVar("foo"); !ok || v.Value != "foo" {
+		t.Fatalf("should find foo in parent: %#v", v)
+	}
+	if v, ok := scope.LookupVar("bar"); !ok || v.Value != 42 {
+		t.Fatalf("bar should be shadowed: %#v", v)
+	}
+	if v, ok := scope.LookupVar("baz"); !ok || v.Value != 12 {
+		t.Fatalf("should find baz: %#v", v)
+	}
+	if _, ok := scope.LookupVar("qux"); ok {
+		t.Fatal("should not find qux")
+	}
+	if v, _ := parent.LookupVar("bar"); v.Value != "bar" {
+		t.Fatal("parent should not be modified")
+	}
+}
+
+func TestChainedScope_nilParent(t *testing.T) {
+	scope := &ChainedScope{}
+	if _, ok := scope.LookupVar("foo"); ok {
+		t.Fatal("should not find foo")
+	}
+	if _, ok := scope.LookupFunc("foo"); ok {
+		t.Fatal("should not find foo")
+	}
+}
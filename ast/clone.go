@@ -0,0 +1,265 @@
+package ast
+
+import (
+	"reflect"
+)
+
+// Cloner is implemented by nodes of other packages that copy themselves
+// instead of being copied by Clone. Implementations may call Clone for
+// their children, but not for themselves.
+type Cloner interface {
+	Clone() Node
+}
+
+// Equaler is implemented by nodes of other packages that compare
+// themselves to other nodes instead of being compared by Equal.
+// Implementations may call Equal for their children, but not for
+// themselves.
+type Equaler interface {
+	Equal(other Node, opts *EqualOptions) bool
+}
+
+// EqualOptions configures how Equal compares trees.
+type EqualOptions struct {
+	// IgnorePositions compares trees regardless of where they were
+	// parsed, ignoring all Pos values.
+	IgnorePositions bool
+}
+
+// Clone returns a deep copy of the tree n, including the values of
+// literals, so that the copy can be modified without affecting n, such as
+// by TypeCheck.
+//
+// Nodes are copied with reflection unless they implement Cloner: exported
+// fields are copied deeply, including the child nodes they hold, while
+// unexported fields are copied as they are. A pointer or map that is
+// reached more than once, such as a pointer back to a parent node, is
+// copied once and the copy is shared the same way.
+func Clone(n Node) Node {
+	if n == nil {
+		return nil
+	}
+
+	c := &cloner{seen: make(map[visit]reflect.Value)}
+	return c.value(reflect.ValueOf(n)).Interface().(Node)
+}
+
+// Equal reports whether the trees a and b have the same structure and
+// values. opts may be nil for the defaults. Unlike reflect.DeepEqual, nil
+// and empty slices and maps are equal.
+//
+// Nodes are compared with reflection unless they implement Equaler, in
+// which case a must be equal to b according to it. Unexported fields are
+// compared as well. Like reflect.DeepEqual, pointers and maps that are
+// reached again while they are being compared are taken to be equal, so
+// trees with cycles can be compared.
+func Equal(a, b Node, opts *EqualOptions) bool {
+	if opts == nil {
+		opts = new(EqualOptions)
+	}
+
+	e := &equaler{opts: opts, seen: make(map[[2]visit]bool)}
+	return e.values(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+var (
+	clonerType  = reflect.TypeOf((*Cloner)(nil)).Elem()
+	equalerType = reflect.TypeOf((*Equaler)(nil)).Elem()
+	posType     = reflect.TypeOf(Pos{})
+)
+
+// visit identifies a pointer or map that was reached while walking a
+// tree. The type is part of it since a pointer to a struct has the same
+// address as a pointer to its first field.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// cloner copies a tree, keeping the copies of the pointers and maps it
+// has seen so that they are copied only once.
+type cloner struct {
+	seen map[visit]reflect.Value
+}
+
+func (c *cloner) value(v reflect.Value) reflect.Value {
+	if v.Type().Implements(clonerType) && v.CanInterface() && !isNil(v) {
+		return reflect.ValueOf(v.Interface().(Cloner).Clone())
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+
+		key := visit{v.Pointer(), v.Type()}
+		if result, ok := c.seen[key]; ok {
+			return result
+		}
+
+		// The copy is recorded before its element is copied, so that
+		// pointers back to it are set to the copy
+		result := reflect.New(v.Type().Elem())
+		c.seen[key] = result
+		result.Elem().Set(c.value(v.Elem()))
+		return result
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+
+		result := reflect.New(v.Type()).Elem()
+		result.Set(c.value(v.Elem()))
+		return result
+	case reflect.Struct:
+		// Unexported fields can only be copied along with the struct
+		result := reflect.New(v.Type()).Elem()
+		result.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				result.Field(i).Set(c.value(v.Field(i)))
+			}
+		}
+
+		return result
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+
+		result := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			result.Index(i).Set(c.value(v.Index(i)))
+		}
+
+		return result
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+
+		key := visit{v.Pointer(), v.Type()}
+		if result, ok := c.seen[key]; ok {
+			return result
+		}
+
+		result := reflect.MakeMapWithSize(v.Type(), v.Len())
+		c.seen[key] = result
+		iter := v.MapRange()
+		for iter.Next() {
+			result.SetMapIndex(iter.Key(), c.value(iter.Value()))
+		}
+
+		return result
+	case reflect.Array:
+		result := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			result.Index(i).Set(c.value(v.Index(i)))
+		}
+
+		return result
+	default:
+		return v
+	}
+}
+
+// equaler compares two trees, keeping the pairs of pointers and maps
+// that it is comparing so that cycles end.
+type equaler struct {
+	opts *EqualOptions
+	seen map[[2]visit]bool
+}
+
+func (e *equaler) values(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	if a.Type() == posType && e.opts.IgnorePositions {
+		return true
+	}
+	if a.Type().Implements(equalerType) && a.CanInterface() && !isNil(a) {
+		return a.Interface().(Equaler).Equal(b.Interface().(Node), e.opts)
+	}
+
+	if k := a.Kind(); (k == reflect.Ptr || k == reflect.Map) && !a.IsNil() && !b.IsNil() {
+		key := [2]visit{{a.Pointer(), a.Type()}, {b.Pointer(), b.Type()}}
+		if e.seen[key] {
+			return true
+		}
+		e.seen[key] = true
+	}
+
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+
+		return e.values(a.Elem(), b.Elem())
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !e.values(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+
+		return true
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !e.values(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+
+		return true
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+
+		iter := a.MapRange()
+		for iter.Next() {
+			bv := b.MapIndex(iter.Key())
+			if !bv.IsValid() || !e.values(iter.Value(), bv) {
+				return false
+			}
+		}
+
+		return true
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() == b.Float()
+	case reflect.Complex64, reflect.Complex128:
+		return a.Complex() == b.Complex()
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Func:
+		// Functions can only be compared to nil
+		return a.IsNil() && b.IsNil()
+	default:
+		return a.Pointer() == b.Pointer()
+	}
+}
+
+// isNil reports whether v is a nil pointer or interface, on which methods
+// can't be called.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
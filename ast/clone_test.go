@@ -0,0 +1,245 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	cases := []Node{
+		testInspectTree(),
+		&LiteralNode{
+			Value: []Variable{
+				{Type: TString, Value: "foo"},
+				{Type: TMap, Value: map[string]Variable{"bar": {Type: TInt, Value: 42}}},
+			},
+			Typex: TList,
+			Posx:  Pos{Column: 3, Line: 1, Offset: 2},
+			Endx:  Pos{Column: 8, Line: 1, Offset: 7},
+		},
+		&Output{},
+		&testContainerNode{Exprs: []Node{&VariableAccess{Name: "foo"}}},
+	}
+
+	for _, tc := range cases {
+		actual := Clone(tc)
+		if !reflect.DeepEqual(actual, tc) {
+			t.Fatalf("bad: %#v\n\nexpected: %#v", actual, tc)
+		}
+		if actual == tc {
+			t.Fatalf("clone is the original: %#v", actual)
+		}
+	}
+}
+
+func TestClone_deep(t *testing.T) {
+	original := testInspectTree()
+	clone := Clone(original)
+
+	// Modify everything that can be reached from the clone
+	err := Inspect(clone, func(c *Cursor) error {
+		switch n := c.Node().(type) {
+		case *Call:
+			n.Func = "lower"
+			n.NamedArgs[0].Name = "separator"
+		case *VariableAccess:
+			n.Name = "var.other"
+		case *LiteralNode:
+			n.Value = "changed"
+		}
+
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(original, testInspectTree()) {
+		t.Fatalf("original was modified: %#v", original)
+	}
+
+	list := []Variable{{Type: TString, Value: "foo"}}
+	lit := &LiteralNode{Value: list, Typex: TList}
+	lit.Value.([]Variable)[0].Value = "bar"
+	Clone(lit).(*LiteralNode).Value.([]Variable)[0].Value = "baz"
+	if list[0].Value != "bar" {
+		t.Fatalf("literal value was modified: %#v", list)
+	}
+}
+
+// testCloneNode is a node of another package that copies itself.
+type testCloneNode struct {
+	Child  Node
+	clones *int
+}
+
+func (n *testCloneNode) Accept(v Visitor) Node    { return v(n) }
+func (n *testCloneNode) Pos() Pos                 { return Pos{} }
+func (n *testCloneNode) Type(Scope) (Type, error) { return TString, nil }
+
+func (n *testCloneNode) Clone() Node {
+	*n.clones++
+	return &testCloneNode{Child: Clone(n.Child), clones: n.clones}
+}
+
+func TestClone_cloner(t *testing.T) {
+	clones := 0
+	n := &testCloneNode{Child: &VariableAccess{Name: "foo"}, clones: &clones}
+
+	actual := Clone(&Output{Exprs: []Node{n}}).(*Output).Exprs[0].(*testCloneNode)
+	if clones != 1 {
+		t.Fatalf("bad: %d", clones)
+	}
+	if actual == n || actual.Child == n.Child {
+		t.Fatalf("not copied: %#v", actual)
+	}
+	if !reflect.DeepEqual(actual, n) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+// testParentNode is a node of another package that points back to the
+// node holding it.
+type testParentNode struct {
+	Name   string
+	Parent *Output
+	Shared *VariableAccess
+}
+
+func (n *testParentNode) Accept(v Visitor) Node    { return v(n) }
+func (n *testParentNode) Pos() Pos                 { return Pos{} }
+func (n *testParentNode) Type(Scope) (Type, error) { return TString, nil }
+
+func testParentTree(name string) *Output {
+	shared := &VariableAccess{Name: "var.foo"}
+	root := &Output{Exprs: []Node{shared}}
+	root.Exprs = append(root.Exprs, &testParentNode{Name: name, Parent: root, Shared: shared})
+	return root
+}
+
+func TestClone_cycles(t *testing.T) {
+	n := testParentTree("foo")
+
+	actual := Clone(n).(*Output)
+	child := actual.Exprs[1].(*testParentNode)
+	if actual == n || child.Parent != actual {
+		t.Fatalf("parent not copied: %#v", child.Parent)
+	}
+	if child.Shared != actual.Exprs[0] || child.Shared == n.Exprs[0] {
+		t.Fatalf("shared node not copied once: %#v", child.Shared)
+	}
+}
+
+func TestEqual_cycles(t *testing.T) {
+	if !Equal(testParentTree("foo"), testParentTree("foo"), nil) {
+		t.Fatal("trees should be equal")
+	}
+	if Equal(testParentTree("foo"), testParentTree("bar"), nil) {
+		t.Fatal("trees should not be equal")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	pos := Pos{Column: 3, Line: 1, Offset: 2}
+
+	cases := []struct {
+		A, B            Node
+		Equal           bool
+		IgnorePositions bool
+	}{
+		{testInspectTree(), testInspectTree(), true, false},
+		{
+			&VariableAccess{Name: "foo"},
+			&VariableAccess{Name: "bar"},
+			false, false,
+		},
+		{
+			&VariableAccess{Name: "foo"},
+			&VariableAccess{Name: "foo", Posx: pos},
+			false, false,
+		},
+		{
+			&VariableAccess{Name: "foo"},
+			&VariableAccess{Name: "foo", Posx: pos},
+			true, true,
+		},
+		{
+			&Call{Func: "foo", NamedArgs: []*NamedArg{
+				{Name: "a", Value: &LiteralNode{Value: 1, Typex: TInt, Posx: pos}},
+			}},
+			&Call{Func: "foo", NamedArgs: []*NamedArg{
+				{Name: "a", Value: &LiteralNode{Value: 1, Typex: TInt}, Endx: pos},
+			}},
+			true, true,
+		},
+		{
+			&LiteralNode{Value: 1, Typex: TInt},
+			&LiteralNode{Value: 1.0, Typex: TInt},
+			false, false,
+		},
+		{
+			&LiteralNode{Value: []Variable{{Type: TInt, Value: 1}}, Typex: TList},
+			&LiteralNode{Value: []Variable{{Type: TInt, Value: 2}}, Typex: TList},
+			false, false,
+		},
+		{
+			&Call{Func: "foo", Args: nil},
+			&Call{Func: "foo", Args: []Node{}},
+			true, false,
+		},
+		{
+			&Output{Exprs: []Node{&VariableAccess{Name: "foo"}}},
+			&Output{Exprs: []Node{&LiteralNode{Value: "foo", Typex: TString}}},
+			false, false,
+		},
+		{
+			&testContainerNode{Exprs: []Node{&VariableAccess{Name: "foo"}}},
+			&testContainerNode{Exprs: []Node{&VariableAccess{Name: "foo", Endx: pos}}},
+			true, true,
+		},
+		{
+			&testContainerNode{Exprs: []Node{&VariableAccess{Name: "foo"}}},
+			&testContainerNode{Exprs: []Node{&VariableAccess{Name: "bar"}}},
+			false, true,
+		},
+		{nil, nil, true, false},
+		{&Output{}, nil, false, false},
+	}
+
+	for _, tc := range cases {
+		opts := &EqualOptions{IgnorePositions: tc.IgnorePositions}
+		if actual := Equal(tc.A, tc.B, opts); actual != tc.Equal {
+			t.Fatalf("bad: %v\n\n%#v\n\n%#v", actual, tc.A, tc.B)
+		}
+		if actual := Equal(tc.B, tc.A, opts); actual != tc.Equal {
+			t.Fatalf("bad (reversed): %v\n\n%#v\n\n%#v", actual, tc.A, tc.B)
+		}
+	}
+}
+
+// testEqualNode is a node of another package that compares itself.
+type testEqualNode struct {
+	Name string
+}
+
+func (n *testEqualNode) Accept(v Visitor) Node    { return v(n) }
+func (n *testEqualNode) Pos() Pos                 { return Pos{} }
+func (n *testEqualNode) Type(Scope) (Type, error) { return TString, nil }
+
+func (n *testEqualNode) Equal(other Node, opts *EqualOptions) bool {
+	o, ok := other.(*testEqualNode)
+	return ok && len(o.Name) == len(n.Name)
+}
+
+func TestEqual_equaler(t *testing.T) {
+	a := &Output{Exprs: []Node{&testEqualNode{Name: "foo"}}}
+	b := &Output{Exprs: []Node{&testEqualNode{Name: "bar"}}}
+	if !Equal(a, b, nil) {
+		t.Fatal("nodes should be equal")
+	}
+
+	c := &Output{Exprs: []Node{&testEqualNode{Name: "foobar"}}}
+	if Equal(a, c, nil) {
+		t.Fatal("nodes should not be equal")
+	}
+}
@@ -0,0 +1,103 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Signatures returns the signatures a call to the function may resolve
+// to: its Overloads or, if there are none, the function itself.
+func (f Function) Signatures() []Function {
+	if len(f.Overloads) > 0 {
+		return f.Overloads
+	}
+
+	return []Function{f}
+}
+
+// Accepts reports whether the function can be called with arguments of
+// the given types without any conversions.
+func (f Function) Accepts(args []Type) bool {
+	if !f.AcceptsCount(len(args)) {
+		return false
+	}
+
+	for i, t := range args {
+		if !t.AssignableTo(f.ArgTypeAt(i)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ArgTypeAt returns the type expected for the argument at index i, which
+// is the VariadicType for arguments beyond ArgTypes.
+func (f Function) ArgTypeAt(i int) Type {
+	if i < len(f.ArgTypes) {
+		return f.ArgTypes[i]
+	}
+
+	return f.VariadicType
+}
+
+// AcceptsCount reports whether the function can be called with the given
+// number of arguments.
+func (f Function) AcceptsCount(n int) bool {
+	if n < len(f.ArgTypes)-len(f.Defaults) {
+		return false
+	}
+
+	return f.Variadic || n <= len(f.ArgTypes)
+}
+
+// Resolve returns the signature of the function that accepts arguments of
+// the given types. For overload sets this is the first overload that
+// accepts them.
+func (f Function) Resolve(args []Type) (Function, bool) {
+	for _, sig := range f.Signatures() {
+		if sig.Accepts(args) {
+			return sig, true
+		}
+	}
+
+	return Function{}, false
+}
+
+// ResultType returns the type a call of the function with arguments of the
+// given types returns, using ReturnTypeFunc if it is set.
+func (f Function) ResultType(args []Type) (Type, error) {
+	if f.ReturnTypeFunc == nil {
+		return f.ReturnType, nil
+	}
+
+	return f.ReturnTypeFunc(args)
+}
+
+// Signature returns a human readable representation of the argument and
+// return types of the function, such as "(int, width int = 10) string".
+func (f Function) Signature() string {
+	required := len(f.ArgTypes) - len(f.Defaults)
+	args := make([]string, 0, len(f.ArgTypes)+1)
+	for i, t := range f.ArgTypes {
+		arg := t.name()
+		if i < len(f.ArgNames) {
+			arg = f.ArgNames[i] + " " + arg
+		}
+		if i >= required {
+			arg = fmt.Sprintf("%s = %v", arg, f.Defaults[i-required].Value)
+		}
+
+		args = append(args, arg)
+	}
+	if f.Variadic {
+		args = append(args, f.VariadicType.name()+"...")
+	}
+
+	ret := f.ReturnType.name()
+	if f.ReturnTypeFunc != nil {
+		ret = "<computed>"
+	}
+
+	return fmt.Sprintf("(%s) %s", strings.Join(args, ", "), ret)
+}
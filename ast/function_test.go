@@ -0,0 +1,66 @@
+package ast
+
+import (
+	"testing"
+)
+
+func TestFunctionResolve(t *testing.T) {
+	f := Function{
+		Overloads: []Function{
+			{ArgTypes: []Type{TInt}, ReturnType: TInt},
+			{ArgTypes: []Type{Union(TInt, TFloat)}, ReturnType: TFloat},
+			{ArgTypes: []Type{TString}, Variadic: true, VariadicType: TString, ReturnType: TString},
+		},
+	}
+
+	cases := []struct {
+		Args   []Type
+		Result Type
+		OK     bool
+	}{
+		{[]Type{TInt}, TInt, true},
+		{[]Type{TFloat}, TFloat, true},
+		{[]Type{TString}, TString, true},
+		{[]Type{TString, TString, TString}, TString, true},
+		{[]Type{TInt, TInt}, TUnsupported, false},
+		{[]Type{TBool}, TUnsupported, false},
+	}
+
+	for _, tc := range cases {
+		sig, ok := f.Resolve(tc.Args)
+		if ok != tc.OK || sig.ReturnType != tc.Result {
+			t.Fatalf("%v: bad: %s %t", tc.Args, sig.ReturnType, ok)
+		}
+	}
+}
+
+func TestFunctionSignature(t *testing.T) {
+	cases := []struct {
+		Function Function
+		Result   string
+	}{
+		{
+			Function{ArgTypes: []Type{TInt, List(TString)}, ReturnType: TString},
+			"(int, list(string)) string",
+		},
+		{
+			Function{ArgTypes: []Type{TInt}, Variadic: true, VariadicType: TAny, ReturnType: TInt},
+			"(int, any...) int",
+		},
+		{
+			Function{
+				ArgTypes: []Type{Union(TInt, TFloat)},
+				ReturnTypeFunc: func(args []Type) (Type, error) {
+					return args[0], nil
+				},
+			},
+			"(union(float, int)) <computed>",
+		},
+	}
+
+	for _, tc := range cases {
+		if actual := tc.Function.Signature(); actual != tc.Result {
+			t.Fatalf("bad: %s", actual)
+		}
+	}
+}
@@ -10,9 +10,13 @@ type Index struct {
 	Target Node
 	Key    Node
 	Posx   Pos
+	Endx   Pos
 }
 
 func (n *Index) Accept(v Visitor) Node {
+	n.Target = n.Target.Accept(v)
+	n.Key = n.Key.Accept(v)
+
 	return v(n)
 }
 
@@ -20,6 +24,10 @@ func (n *Index) Pos() Pos {
 	return n.Posx
 }
 
+func (n *Index) End() Pos {
+	return n.Endx
+}
+
 func (n *Index) String() string {
 	return fmt.Sprintf("Index(%s, %s)", n.Target, n.Key)
 }
@@ -35,7 +43,7 @@ func (n *Index) Type(s Scope) (Type, error) {
 		return TUnsupported, fmt.Errorf("unknown variable accessed: %s", variableAccess.Name)
 	}
 
-	switch variable.Type {
+	switch variable.Type.Kind() {
 	case TList:
 		return n.TList(variable, variableAccess.Name)
 	case TMap:
@@ -45,7 +53,106 @@ func (n *Index) Type(s Scope) (Type, error) {
 	}
 }
 
+// KeyError is returned by DeclaredType if the literal key of an index into
+// an object or tuple doesn't exist in its type. Key is the string or int
+// used as key, and Pos and End are its range.
+type KeyError struct {
+	Pos    Pos
+	End    Pos
+	Target Type
+	Key    interface{}
+}
+
+func (e *KeyError) Error() string {
+	if name, ok := e.Key.(string); ok {
+		return fmt.Sprintf("%s: %s has no attribute %q", e.Pos, e.Target, name)
+	}
+
+	return fmt.Sprintf("%s: index %v out of range for %s", e.Pos, e.Key, e.Target)
+}
+
+// DeclaredType returns the type of the value this index refers to if it
+// can be determined from the type of the target alone, which is the case
+// for list(T) and map(T) types as well as for tuples and objects. The
+// second return value is false if the elements of the target have to be
+// looked at to determine the type.
+//
+// Tuples and objects must be indexed with a literal key unless all of
+// their elements have the same type.
+func (n *Index) DeclaredType(target Type) (Type, bool, error) {
+	if elem, ok := target.ElemType(); ok {
+		return elem, true, nil
+	}
+
+	var elems []Type
+	switch {
+	case target.IsObject():
+		if key, ok := n.Key.(*LiteralNode); ok {
+			name, ok := key.Value.(string)
+			if !ok {
+				return TUnsupported, true, fmt.Errorf(
+					"key for indexing %s must be a string", target)
+			}
+
+			attr, ok := target.AttrType(name)
+			if !ok {
+				return TUnsupported, true, &KeyError{
+					Pos:    key.Pos(),
+					End:    End(key),
+					Target: target,
+					Key:    name,
+				}
+			}
+
+			return attr, true, nil
+		}
+
+		for _, attr := range target.AttrTypes() {
+			elems = append(elems, attr)
+		}
+	case target.IsTuple():
+		elems = target.TupleTypes()
+		if key, ok := n.Key.(*LiteralNode); ok {
+			i, ok := key.Value.(int)
+			if !ok {
+				return TUnsupported, true, fmt.Errorf(
+					"key for indexing %s must be an int", target)
+			}
+			if i < 0 || i >= len(elems) {
+				return TUnsupported, true, &KeyError{
+					Pos:    key.Pos(),
+					End:    End(key),
+					Target: target,
+					Key:    i,
+				}
+			}
+
+			return elems[i], true, nil
+		}
+	default:
+		return TUnsupported, false, nil
+	}
+
+	if len(elems) == 0 {
+		return TUnsupported, true, fmt.Errorf("%s has no elements", target)
+	}
+	for _, e := range elems[1:] {
+		if e != elems[0] {
+			return TUnsupported, true, fmt.Errorf(
+				"%s has elements of different types and can only be indexed by a literal key",
+				target)
+		}
+	}
+
+	return elems[0], true, nil
+}
+
 func (n *Index) TList(variable Variable, variableName string) (Type, error) {
+	// If the type declares its element types we don't need the elements
+	if elem, ok, err := n.DeclaredType(variable.Type); ok {
+		return elem, err
+	}
+
 	// We assume type checking has already determined that this is a list
 	list := variable.Value.([]Variable)
 
@@ -53,6 +160,11 @@ func (n *Index) TList(variable Variable, variableName string) (Type, error) {
 }
 
 func (n *Index) TMap(variable Variable, variableName string) (Type, error) {
+	// If the type declares its element types we don't need the elements
+	if elem, ok, err := n.DeclaredType(variable.Type); ok {
+		return elem, err
+	}
+
 	// We assume type checking has already determined that this is a map
 	vmap := variable.Value.(map[string]Variable)
 
@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -268,3 +269,111 @@ func TestIndexTList_nonHomogenous(t *testing.T) {
 		t.Fatalf("expected error")
 	}
 }
+
+func TestIndexTList_declaredEmpty(t *testing.T) {
+	i := &Index{
+		Target: &VariableAccess{Name: "foo"},
+		Key: &LiteralNode{
+			Typex: TInt,
+			Value: 0,
+		},
+	}
+
+	scope := &BasicScope{
+		VarMap: map[string]Variable{
+			"foo": Variable{
+				Type:  List(TString),
+				Value: []Variable{},
+			},
+		},
+	}
+
+	actual, err := i.Type(scope)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual != TString {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestIndexTMap_declared(t *testing.T) {
+	i := &Index{
+		Target: &VariableAccess{Name: "foo"},
+		Key: &LiteralNode{
+			Typex: TString,
+			Value: "bar",
+		},
+	}
+
+	scope := &BasicScope{
+		VarMap: map[string]Variable{
+			"foo": Variable{
+				Type:  Map(List(TInt)),
+				Value: map[string]Variable{},
+			},
+		},
+	}
+
+	actual, err := i.Type(scope)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual != List(TInt) {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestIndexAccept(t *testing.T) {
+	i := &Index{
+		Target: &VariableAccess{Name: "foo"},
+		Key:    &VariableAccess{Name: "bar"},
+	}
+
+	var visited []Node
+	i.Accept(func(n Node) Node {
+		visited = append(visited, n)
+		return n
+	})
+
+	expected := []Node{i.Target, i.Key, i}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Fatalf("bad: %#v", visited)
+	}
+}
+
+func TestIndexDeclaredType_keyError(t *testing.T) {
+	pos := Pos{Column: 5, Line: 1, Offset: 4}
+	end := Pos{Column: 11, Line: 1, Offset: 10}
+	obj := Object(map[string]Type{"name": TString})
+	tuple := Tuple(TString, TInt)
+
+	cases := []struct {
+		Target  Type
+		Key     interface{}
+		KeyType Type
+		Error   string
+	}{
+		{obj, "host", TString, `1:5: object("name": string) has no attribute "host"`},
+		{tuple, 2, TInt, "1:5: index 2 out of range for tuple(string, int)"},
+	}
+
+	for _, tc := range cases {
+		i := &Index{
+			Target: &VariableAccess{Name: "foo"},
+			Key:    &LiteralNode{Value: tc.Key, Typex: tc.KeyType, Posx: pos, Endx: end},
+		}
+
+		_, _, err := i.DeclaredType(tc.Target)
+		keyErr, ok := err.(*KeyError)
+		if !ok {
+			t.Fatalf("bad: %#v", err)
+		}
+		if keyErr.Pos != pos || keyErr.End != end || keyErr.Key != tc.Key {
+			t.Fatalf("bad: %#v", keyErr)
+		}
+		if keyErr.Error() != tc.Error {
+			t.Fatalf("bad: %s", keyErr)
+		}
+	}
+}
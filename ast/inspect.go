@@ -0,0 +1,196 @@
+package ast
+
+import (
+	"errors"
+)
+
+var (
+	// SkipChildren is returned by the enter function of Inspect or Rewrite
+	// to not visit the children of the current node. The leave function
+	// is still called for it.
+	SkipChildren = errors.New("skip children")
+
+	// Stop is returned by the enter or leave function of Inspect or
+	// Rewrite to end the walk early without an error.
+	Stop = errors.New("stop walk")
+)
+
+// Container is implemented by nodes of other packages that have child
+// nodes, so that Inspect and Rewrite can walk into them. Nodes that don't
+// implement it are treated as leaves.
+type Container interface {
+	// Children returns the child nodes in the order they are evaluated.
+	Children() []Node
+
+	// SetChild replaces the child at index i of Children with n.
+	SetChild(i int, n Node)
+}
+
+// Cursor describes the node that the functions passed to Inspect and
+// Rewrite are called for. It is only valid during the call.
+type Cursor struct {
+	node    Node
+	path    []Node
+	index   int
+	rewrite bool
+}
+
+// Node returns the current node.
+func (c *Cursor) Node() Node {
+	return c.node
+}
+
+// Parent returns the parent of the current node, or nil for the root.
+func (c *Cursor) Parent() Node {
+	if len(c.path) == 0 {
+		return nil
+	}
+
+	return c.path[len(c.path)-1]
+}
+
+// Path returns the ancestors of the current node, starting with the root
+// and ending with its parent. The slice must not be modified or kept.
+func (c *Cursor) Path() []Node {
+	return c.path
+}
+
+// Index returns the index of the current node within the children of
+// its parent, or -1 for the root. The children of a Call are its Args
+// followed by the values of its NamedArgs, those of an Index are its
+// Target and Key.
+func (c *Cursor) Index() int {
+	return c.index
+}
+
+// Replace replaces the current node with n. When called before the
+// children of the node are visited, the children of n are visited instead.
+// It panics if called during Inspect.
+func (c *Cursor) Replace(n Node) {
+	if !c.rewrite {
+		panic("ast: Replace called during Inspect")
+	}
+
+	c.node = n
+}
+
+// Inspect walks the tree rooted at n depth-first. It calls enter for each
+// node before its children are visited and leave after, either of which
+// may be nil. If one of them returns an error other than SkipChildren or
+// Stop, the walk ends and Inspect returns the error.
+func Inspect(n Node, enter, leave func(*Cursor) error) error {
+	w := &walker{enter: enter, leave: leave}
+	_, err := w.walk(n, -1)
+	if err == Stop {
+		err = nil
+	}
+
+	return err
+}
+
+// Rewrite is like Inspect, but enter and leave may replace nodes using
+// Cursor.Replace. The tree is modified in place. It returns the new root,
+// which is n unless the root was replaced.
+func Rewrite(n Node, enter, leave func(*Cursor) error) (Node, error) {
+	w := &walker{enter: enter, leave: leave, rewrite: true}
+	result, err := w.walk(n, -1)
+	if err == Stop {
+		err = nil
+	}
+
+	return result, err
+}
+
+// walker holds the state of Inspect and Rewrite.
+type walker struct {
+	enter, leave func(*Cursor) error
+	rewrite      bool
+	path         []Node
+}
+
+// walk visits n and its children and returns the node that replaces n.
+func (w *walker) walk(n Node, index int) (Node, error) {
+	c := &Cursor{node: n, path: w.path, index: index, rewrite: w.rewrite}
+
+	skip := false
+	if w.enter != nil {
+		switch err := w.enter(c); err {
+		case nil:
+		case SkipChildren:
+			skip = true
+		default:
+			return c.node, err
+		}
+	}
+
+	if !skip {
+		w.path = append(w.path, c.node)
+		for i, child := range children(c.node) {
+			result, err := w.walk(child, i)
+			if result != child {
+				setChild(c.node, i, result)
+			}
+			if err != nil {
+				w.path = w.path[:len(w.path)-1]
+				return c.node, err
+			}
+		}
+		w.path = w.path[:len(w.path)-1]
+	}
+
+	if w.leave != nil {
+		if err := w.leave(c); err != nil && err != SkipChildren {
+			return c.node, err
+		}
+	}
+
+	return c.node, nil
+}
+
+// children returns the child nodes of n.
+func children(n Node) []Node {
+	switch n := n.(type) {
+	case *Arithmetic:
+		return n.Exprs
+	case *Call:
+		result := make([]Node, 0, len(n.Args)+len(n.NamedArgs))
+		result = append(result, n.Args...)
+		for _, a := range n.NamedArgs {
+			result = append(result, a.Value)
+		}
+
+		return result
+	case *Index:
+		return []Node{n.Target, n.Key}
+	case *Output:
+		return n.Exprs
+	case Container:
+		return n.Children()
+	default:
+		return nil
+	}
+}
+
+// setChild replaces the child at index i of children(n).
+func setChild(n Node, i int, child Node) {
+	switch n := n.(type) {
+	case *Arithmetic:
+		n.Exprs[i] = child
+	case *Call:
+		if i < len(n.Args) {
+			n.Args[i] = child
+		} else {
+			n.NamedArgs[i-len(n.Args)].Value = child
+		}
+	case *Index:
+		if i == 0 {
+			n.Target = child
+		} else {
+			n.Key = child
+		}
+	case *Output:
+		n.Exprs[i] = child
+	case Container:
+		n.SetChild(i, child)
+	}
+}
@@ -0,0 +1,302 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testInspectTree returns the tree of foo #{upper(var.list[var.i + 1], sep = "-")}
+func testInspectTree() Node {
+	return &Output{
+		Exprs: []Node{
+			&LiteralNode{Value: "foo ", Typex: TString},
+			&Call{
+				Func: "upper",
+				Args: []Node{
+					&Index{
+						Target: &VariableAccess{Name: "var.list"},
+						Key: &Arithmetic{
+							Op: ArithmeticOpAdd,
+							Exprs: []Node{
+								&VariableAccess{Name: "var.i"},
+								&LiteralNode{Value: 1, Typex: TInt},
+							},
+						},
+					},
+				},
+				NamedArgs: []*NamedArg{
+					{Name: "sep", Value: &LiteralNode{Value: "-", Typex: TString}},
+				},
+			},
+		},
+	}
+}
+
+// testNodeName returns a short name for a node of testInspectTree.
+func testNodeName(n Node) string {
+	switch n := n.(type) {
+	case *Output:
+		return "Output"
+	case *Call:
+		return "Call(" + n.Func + ")"
+	case *Index:
+		return "Index"
+	case *Arithmetic:
+		return "Arithmetic"
+	case *VariableAccess:
+		return n.Name
+	case *LiteralNode:
+		return fmt.Sprintf("%q", fmt.Sprint(n.Value))
+	default:
+		return fmt.Sprintf("%T", n)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	var events []string
+	err := Inspect(testInspectTree(),
+		func(c *Cursor) error {
+			events = append(events, fmt.Sprintf("enter %s %d", testNodeName(c.Node()), c.Index()))
+			return nil
+		},
+		func(c *Cursor) error {
+			events = append(events, "leave "+testNodeName(c.Node()))
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{
+		"enter Output -1",
+		`enter "foo " 0`,
+		`leave "foo "`,
+		"enter Call(upper) 1",
+		"enter Index 0",
+		"enter var.list 0",
+		"leave var.list",
+		"enter Arithmetic 1",
+		"enter var.i 0",
+		"leave var.i",
+		`enter "1" 1`,
+		`leave "1"`,
+		"leave Arithmetic",
+		"leave Index",
+		`enter "-" 1`,
+		`leave "-"`,
+		"leave Call(upper)",
+		"leave Output",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("bad:\n%s", strings.Join(events, "\n"))
+	}
+}
+
+func TestInspect_path(t *testing.T) {
+	var path []string
+	err := Inspect(testInspectTree(), func(c *Cursor) error {
+		if v, ok := c.Node().(*VariableAccess); ok && v.Name == "var.i" {
+			for _, n := range c.Path() {
+				path = append(path, testNodeName(n))
+			}
+			if _, ok := c.Parent().(*Arithmetic); !ok {
+				t.Fatalf("bad parent: %#v", c.Parent())
+			}
+		}
+
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{"Output", "Call(upper)", "Index", "Arithmetic"}
+	if !reflect.DeepEqual(path, expected) {
+		t.Fatalf("bad: %#v", path)
+	}
+}
+
+func TestInspect_skipChildren(t *testing.T) {
+	var entered, left []string
+	err := Inspect(testInspectTree(),
+		func(c *Cursor) error {
+			entered = append(entered, testNodeName(c.Node()))
+			if _, ok := c.Node().(*Index); ok {
+				return SkipChildren
+			}
+
+			return nil
+		},
+		func(c *Cursor) error {
+			left = append(left, testNodeName(c.Node()))
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{"Output", `"foo "`, "Call(upper)", "Index", `"-"`}
+	if !reflect.DeepEqual(entered, expected) {
+		t.Fatalf("bad: %#v", entered)
+	}
+	expected = []string{`"foo "`, "Index", `"-"`, "Call(upper)", "Output"}
+	if !reflect.DeepEqual(left, expected) {
+		t.Fatalf("bad: %#v", left)
+	}
+}
+
+func TestInspect_stop(t *testing.T) {
+	var entered []string
+	err := Inspect(testInspectTree(), func(c *Cursor) error {
+		entered = append(entered, testNodeName(c.Node()))
+		if _, ok := c.Node().(*VariableAccess); ok {
+			return Stop
+		}
+
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{"Output", `"foo "`, "Call(upper)", "Index", "var.list"}
+	if !reflect.DeepEqual(entered, expected) {
+		t.Fatalf("bad: %#v", entered)
+	}
+}
+
+func TestInspect_error(t *testing.T) {
+	expected := errors.New("boom")
+
+	var left []string
+	err := Inspect(testInspectTree(), nil, func(c *Cursor) error {
+		left = append(left, testNodeName(c.Node()))
+		if _, ok := c.Node().(*Arithmetic); ok {
+			return expected
+		}
+
+		return nil
+	})
+	if err != expected {
+		t.Fatalf("bad: %v", err)
+	}
+	if !reflect.DeepEqual(left, []string{`"foo "`, "var.list", "var.i", `"1"`, "Arithmetic"}) {
+		t.Fatalf("bad: %#v", left)
+	}
+}
+
+func TestInspect_replacePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+
+	Inspect(testInspectTree(), func(c *Cursor) error {
+		c.Replace(&LiteralNode{Value: "", Typex: TString})
+		return nil
+	}, nil)
+}
+
+func TestRewrite(t *testing.T) {
+	// Variables are replaced on the way down, the additions they were in
+	// on the way up.
+	var entered []string
+	root, err := Rewrite(testInspectTree(),
+		func(c *Cursor) error {
+			entered = append(entered, testNodeName(c.Node()))
+			if v, ok := c.Node().(*VariableAccess); ok && v.Name == "var.i" {
+				c.Replace(&LiteralNode{Value: 2, Typex: TInt})
+			}
+
+			return nil
+		},
+		func(c *Cursor) error {
+			if a, ok := c.Node().(*Arithmetic); ok {
+				c.Replace(&LiteralNode{
+					Value: a.Exprs[0].(*LiteralNode).Value.(int) + a.Exprs[1].(*LiteralNode).Value.(int),
+					Typex: TInt,
+				})
+			}
+
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	key := root.(*Output).Exprs[1].(*Call).Args[0].(*Index).Key
+	if !reflect.DeepEqual(key, &LiteralNode{Value: 3, Typex: TInt}) {
+		t.Fatalf("bad: %#v", key)
+	}
+	if entered[len(entered)-2] != `"1"` {
+		t.Fatalf("bad: %#v", entered)
+	}
+}
+
+func TestRewrite_root(t *testing.T) {
+	replacement := &LiteralNode{Value: "bar", Typex: TString}
+	root, err := Rewrite(testInspectTree(), func(c *Cursor) error {
+		if c.Parent() == nil {
+			c.Replace(replacement)
+		}
+
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if root != replacement {
+		t.Fatalf("bad: %#v", root)
+	}
+}
+
+func TestRewrite_namedArg(t *testing.T) {
+	root, err := Rewrite(testInspectTree(), nil, func(c *Cursor) error {
+		if _, ok := c.Parent().(*Call); ok && c.Index() == 1 {
+			c.Replace(&LiteralNode{Value: "+", Typex: TString})
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	value := root.(*Output).Exprs[1].(*Call).NamedArgs[0].Value
+	if !reflect.DeepEqual(value, &LiteralNode{Value: "+", Typex: TString}) {
+		t.Fatalf("bad: %#v", value)
+	}
+}
+
+// testContainerNode is a node of another package with children.
+type testContainerNode struct {
+	Exprs []Node
+}
+
+func (n *testContainerNode) Accept(v Visitor) Node    { return v(n) }
+func (n *testContainerNode) Pos() Pos                 { return Pos{} }
+func (n *testContainerNode) Type(Scope) (Type, error) { return TString, nil }
+func (n *testContainerNode) Children() []Node         { return n.Exprs }
+func (n *testContainerNode) SetChild(i int, c Node)   { n.Exprs[i] = c }
+
+func TestRewrite_container(t *testing.T) {
+	n := &testContainerNode{Exprs: []Node{&VariableAccess{Name: "foo"}}}
+	_, err := Rewrite(&Output{Exprs: []Node{n}}, func(c *Cursor) error {
+		if _, ok := c.Node().(*VariableAccess); ok {
+			c.Replace(&LiteralNode{Value: "bar", Typex: TString})
+		}
+
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(n.Exprs[0], &LiteralNode{Value: "bar", Typex: TString}) {
+		t.Fatalf("bad: %#v", n.Exprs[0])
+	}
+}
@@ -0,0 +1,630 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// JSONVersion is the version of the JSON encoding written by EncodeJSON.
+// It changes whenever the encoding changes in a way that older decoders
+// can't read.
+const JSONVersion = 1
+
+// EncodeJSON returns the JSON encoding of the tree n, which DecodeJSON
+// turns back into an equivalent tree. The encoding is a document of the
+// form
+//
+//	{"version": 1, "root": NODE}
+//
+// where every NODE is an object with the kind of node in "node", its range
+// in "pos" and "end" as objects with "line", "column" and "offset", and the
+// fields of that kind of node:
+//
+//	Output:         "exprs": [NODE, ...]
+//	Call:           "func": "name", "args": [NODE, ...],
+//	                "namedArgs": [{"name": "width", "value": NODE,
+//	                "pos": ..., "end": ...}, ...]
+//	Index:          "target": NODE, "key": NODE
+//	VariableAccess: "name": "var.foo"
+//	Literal:        "type": TYPE, "value": VALUE, or "op" instead of
+//	                "value" for the operation of an Arithmetic that the
+//	                type checker turned into a call
+//	Arithmetic:     "op": "add", "sub", "mul", "div" or "mod",
+//	                "exprs": [NODE, ...]
+//
+// A TYPE is the name of a basic type ("any", "string", "int", "float",
+// "bool", "list" or "map") or an object for a composite type:
+// {"list": TYPE}, {"map": TYPE}, {"object": {"name": TYPE, ...}},
+// {"tuple": [TYPE, ...]} or {"union": [TYPE, ...]}. A VALUE is a JSON
+// string, number or bool for the basic types, and for lists and maps an
+// array or object of {"type": TYPE, "value": VALUE} elements.
+//
+// Only the node types of this package can be encoded.
+func EncodeJSON(n Node) ([]byte, error) {
+	root, err := encodeNode(n)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonDocument{Version: JSONVersion, Root: root})
+}
+
+// DecodeJSON returns the tree encoded by EncodeJSON.
+//
+// Composite types in the document are created just like by List, Map and
+// the other constructors, and stay in the table of composite types for
+// the lifetime of the process. To bound what a document can add to the
+// table, the encoding of a single type can be at most 64 KiB and types
+// can be nested at most 32 levels deep.
+func DecodeJSON(data []byte) (Node, error) {
+	var doc jsonDocument
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	if doc.Version != JSONVersion {
+		return nil, fmt.Errorf("unsupported AST JSON version %d, expected %d",
+			doc.Version, JSONVersion)
+	}
+	if doc.Root == nil {
+		return nil, fmt.Errorf("AST JSON has no root node")
+	}
+
+	return decodeNode(doc.Root)
+}
+
+type jsonDocument struct {
+	Version int       `json:"version"`
+	Root    *jsonNode `json:"root"`
+}
+
+type jsonNode struct {
+	Node string  `json:"node"`
+	Pos  jsonPos `json:"pos"`
+	End  jsonPos `json:"end"`
+
+	Func      string          `json:"func,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Op        string          `json:"op,omitempty"`
+	Type      *jsonType       `json:"type,omitempty"`
+	Value     json.RawMessage `json:"value,omitempty"`
+	Target    *jsonNode       `json:"target,omitempty"`
+	Key       *jsonNode       `json:"key,omitempty"`
+	Args      []*jsonNode     `json:"args,omitempty"`
+	NamedArgs []*jsonNamedArg `json:"namedArgs,omitempty"`
+	Exprs     []*jsonNode     `json:"exprs,omitempty"`
+}
+
+type jsonNamedArg struct {
+	Name  string    `json:"name"`
+	Value *jsonNode `json:"value"`
+	Pos   jsonPos   `json:"pos"`
+	End   jsonPos   `json:"end"`
+}
+
+type jsonPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+type jsonVariable struct {
+	Type  jsonType        `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// jsonOps are the names of the arithmetic operators in JSON.
+var jsonOps = map[ArithmeticOp]string{
+	ArithmeticOpAdd: "add",
+	ArithmeticOpSub: "sub",
+	ArithmeticOpMul: "mul",
+	ArithmeticOpDiv: "div",
+	ArithmeticOpMod: "mod",
+}
+
+func encodePos(p Pos) jsonPos {
+	return jsonPos{Line: p.Line, Column: p.Column, Offset: p.Offset}
+}
+
+func decodePos(p jsonPos) Pos {
+	return Pos{Line: p.Line, Column: p.Column, Offset: p.Offset}
+}
+
+func encodeNode(n Node) (*jsonNode, error) {
+	result := &jsonNode{Pos: encodePos(n.Pos()), End: encodePos(End(n))}
+
+	var err error
+	switch n := n.(type) {
+	case *Output:
+		result.Node = "Output"
+		result.Exprs, err = encodeNodes(n.Exprs)
+	case *Call:
+		result.Node = "Call"
+		result.Func = n.Func
+		if result.Args, err = encodeNodes(n.Args); err != nil {
+			return nil, err
+		}
+
+		for _, a := range n.NamedArgs {
+			value, err := encodeNode(a.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			result.NamedArgs = append(result.NamedArgs, &jsonNamedArg{
+				Name:  a.Name,
+				Value: value,
+				Pos:   encodePos(a.Posx),
+				End:   encodePos(a.Endx),
+			})
+		}
+	case *Index:
+		result.Node = "Index"
+		if result.Target, err = encodeNode(n.Target); err != nil {
+			return nil, err
+		}
+		result.Key, err = encodeNode(n.Key)
+	case *VariableAccess:
+		result.Node = "VariableAccess"
+		result.Name = n.Name
+	case *LiteralNode:
+		result.Node = "Literal"
+		result.Type = &jsonType{n.Typex}
+		if op, ok := n.Value.(ArithmeticOp); ok {
+			if result.Op, ok = jsonOps[op]; !ok {
+				return nil, fmt.Errorf("%s: unknown arithmetic operation %d", n.Pos(), op)
+			}
+			break
+		}
+		result.Value, err = encodeValue(n.Typex, n.Value)
+	case *Arithmetic:
+		op, ok := jsonOps[n.Op]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown arithmetic operation %d", n.Pos(), n.Op)
+		}
+
+		result.Node = "Arithmetic"
+		result.Op = op
+		result.Exprs, err = encodeNodes(n.Exprs)
+	default:
+		return nil, fmt.Errorf("%s: can't encode node of type %T", n.Pos(), n)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func encodeNodes(nodes []Node) ([]*jsonNode, error) {
+	var result []*jsonNode
+	for _, n := range nodes {
+		jn, err := encodeNode(n)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, jn)
+	}
+
+	return result, nil
+}
+
+func decodeNode(jn *jsonNode) (Node, error) {
+	if jn == nil {
+		return nil, fmt.Errorf("missing node")
+	}
+
+	pos, end := decodePos(jn.Pos), decodePos(jn.End)
+	switch jn.Node {
+	case "Output":
+		exprs, err := decodeNodes(jn.Exprs)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Output{Exprs: exprs, Posx: pos, Endx: end}, nil
+	case "Call":
+		args, err := decodeNodes(jn.Args)
+		if err != nil {
+			return nil, err
+		}
+
+		var named []*NamedArg
+		for _, a := range jn.NamedArgs {
+			value, err := decodeNode(a.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			named = append(named, &NamedArg{
+				Name:  a.Name,
+				Value: value,
+				Posx:  decodePos(a.Pos),
+				Endx:  decodePos(a.End),
+			})
+		}
+
+		return &Call{
+			Func:      jn.Func,
+			Args:      args,
+			NamedArgs: named,
+			Posx:      pos,
+			Endx:      end,
+		}, nil
+	case "Index":
+		target, err := decodeNode(jn.Target)
+		if err != nil {
+			return nil, err
+		}
+		key, err := decodeNode(jn.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Index{Target: target, Key: key, Posx: pos, Endx: end}, nil
+	case "VariableAccess":
+		return &VariableAccess{Name: jn.Name, Posx: pos, Endx: end}, nil
+	case "Literal":
+		if jn.Type == nil {
+			return nil, fmt.Errorf("%s: literal without type", pos)
+		}
+
+		if jn.Op != "" {
+			op, ok := decodeOp(jn.Op)
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown arithmetic operation %q", pos, jn.Op)
+			}
+
+			return &LiteralNode{Value: op, Typex: jn.Type.Type, Posx: pos, Endx: end}, nil
+		}
+
+		value, err := decodeValue(jn.Type.Type, jn.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", pos, err)
+		}
+
+		return &LiteralNode{Value: value, Typex: jn.Type.Type, Posx: pos, Endx: end}, nil
+	case "Arithmetic":
+		op, ok := decodeOp(jn.Op)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown arithmetic operation %q", pos, jn.Op)
+		}
+
+		exprs, err := decodeNodes(jn.Exprs)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Arithmetic{Op: op, Exprs: exprs, Posx: pos, Endx: end}, nil
+	default:
+		return nil, fmt.Errorf("%s: unknown node %q", pos, jn.Node)
+	}
+}
+
+// decodeOp returns the arithmetic operation with the given JSON name.
+func decodeOp(name string) (ArithmeticOp, bool) {
+	for op, n := range jsonOps {
+		if n == name {
+			return op, true
+		}
+	}
+
+	return ArithmeticOpInvalid, false
+}
+
+func decodeNodes(jns []*jsonNode) ([]Node, error) {
+	var result []Node
+	for _, jn := range jns {
+		n, err := decodeNode(jn)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, n)
+	}
+
+	return result, nil
+}
+
+// encodeValue returns the JSON encoding of a value of type t.
+func encodeValue(t Type, v interface{}) (json.RawMessage, error) {
+	var result interface{}
+	switch t.Kind() {
+	case TString:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("value of %s is a %T", t, v)
+		}
+		result = s
+	case TInt:
+		i, ok := v.(int)
+		if !ok {
+			return nil, fmt.Errorf("value of %s is a %T", t, v)
+		}
+		result = i
+	case TFloat:
+		f, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("value of %s is a %T", t, v)
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("can't encode float %v", f)
+		}
+		result = f
+	case TBool:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("value of %s is a %T", t, v)
+		}
+		result = b
+	case TList:
+		list, ok := v.([]Variable)
+		if !ok {
+			return nil, fmt.Errorf("value of %s is a %T", t, v)
+		}
+
+		elems := make([]jsonVariable, len(list))
+		for i, elem := range list {
+			value, err := encodeValue(elem.Type, elem.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			elems[i] = jsonVariable{Type: jsonType{elem.Type}, Value: value}
+		}
+		result = elems
+	case TMap:
+		m, ok := v.(map[string]Variable)
+		if !ok {
+			return nil, fmt.Errorf("value of %s is a %T", t, v)
+		}
+
+		elems := make(map[string]jsonVariable, len(m))
+		for k, elem := range m {
+			value, err := encodeValue(elem.Type, elem.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			elems[k] = jsonVariable{Type: jsonType{elem.Type}, Value: value}
+		}
+		result = elems
+	default:
+		return nil, fmt.Errorf("can't encode value of %s", t)
+	}
+
+	return json.Marshal(result)
+}
+
+// decodeValue returns the value of type t encoded by encodeValue.
+func decodeValue(t Type, data json.RawMessage) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	switch t.Kind() {
+	case TString:
+		var s string
+		err := dec.Decode(&s)
+		return s, err
+	case TInt:
+		var n json.Number
+		if err := dec.Decode(&n); err != nil {
+			return nil, err
+		}
+
+		i, err := strconv.Atoi(n.String())
+		return i, err
+	case TFloat:
+		var n json.Number
+		if err := dec.Decode(&n); err != nil {
+			return nil, err
+		}
+
+		return n.Float64()
+	case TBool:
+		var b bool
+		err := dec.Decode(&b)
+		return b, err
+	case TList:
+		var elems []jsonVariable
+		if err := dec.Decode(&elems); err != nil {
+			return nil, err
+		}
+
+		list := make([]Variable, len(elems))
+		for i, elem := range elems {
+			value, err := decodeValue(elem.Type.Type, elem.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			list[i] = Variable{Type: elem.Type.Type, Value: value}
+		}
+
+		return list, nil
+	case TMap:
+		var elems map[string]jsonVariable
+		if err := dec.Decode(&elems); err != nil {
+			return nil, err
+		}
+
+		m := make(map[string]Variable, len(elems))
+		for k, elem := range elems {
+			value, err := decodeValue(elem.Type.Type, elem.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			m[k] = Variable{Type: elem.Type.Type, Value: value}
+		}
+
+		return m, nil
+	default:
+		return nil, fmt.Errorf("can't decode value of %s", t)
+	}
+}
+
+// jsonType is a Type in JSON. Types are encoded by their structure since
+// the values of composite types aren't the same in other processes.
+type jsonType struct {
+	Type Type
+}
+
+// jsonBasicTypes are the names of the basic types in JSON.
+var jsonBasicTypes = map[Type]string{
+	TAny:    "any",
+	TString: "string",
+	TInt:    "int",
+	TFloat:  "float",
+	TBool:   "bool",
+	TList:   "list",
+	TMap:    "map",
+}
+
+func (t jsonType) MarshalJSON() ([]byte, error) {
+	if name, ok := jsonBasicTypes[t.Type]; ok {
+		return json.Marshal(name)
+	}
+
+	c, ok := t.Type.composite()
+	if !ok {
+		return nil, fmt.Errorf("can't encode type %s", t.Type)
+	}
+
+	switch {
+	case c.Members != nil:
+		return json.Marshal(map[string][]jsonType{"union": jsonTypes(c.Members)})
+	case c.Attrs != nil:
+		attrs := make(map[string]jsonType, len(c.Attrs))
+		for k, v := range c.Attrs {
+			attrs[k] = jsonType{v}
+		}
+
+		return json.Marshal(map[string]map[string]jsonType{"object": attrs})
+	case c.Elems != nil:
+		return json.Marshal(map[string][]jsonType{"tuple": jsonTypes(c.Elems)})
+	case c.Kind == TList:
+		return json.Marshal(map[string]jsonType{"list": {c.Elem}})
+	default:
+		return json.Marshal(map[string]jsonType{"map": {c.Elem}})
+	}
+}
+
+// The limits for decoded types, see DecodeJSON.
+const (
+	maxJSONTypeSize  = 64 << 10
+	maxJSONTypeDepth = 32
+)
+
+func (t *jsonType) UnmarshalJSON(data []byte) error {
+	if len(data) > maxJSONTypeSize {
+		return fmt.Errorf("type is larger than %d bytes", maxJSONTypeSize)
+	}
+
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		for basic, n := range jsonBasicTypes {
+			if n == name {
+				t.Type = basic
+				return nil
+			}
+		}
+
+		return fmt.Errorf("unknown type %q", name)
+	}
+
+	var composite map[string]json.RawMessage
+	if err := json.Unmarshal(data, &composite); err != nil {
+		return err
+	}
+	if len(composite) != 1 {
+		return fmt.Errorf("invalid type %s", data)
+	}
+
+	for kind, raw := range composite {
+		switch kind {
+		case "list", "map":
+			var elem jsonType
+			if err := json.Unmarshal(raw, &elem); err != nil {
+				return err
+			}
+
+			if err := checkJSONTypeDepth(elem.Type); err != nil {
+				return err
+			}
+
+			if kind == "list" {
+				t.Type = List(elem.Type)
+			} else {
+				t.Type = Map(elem.Type)
+			}
+		case "object":
+			var attrs map[string]jsonType
+			if err := json.Unmarshal(raw, &attrs); err != nil {
+				return err
+			}
+
+			types := make(map[string]Type, len(attrs))
+			for k, v := range attrs {
+				if err := checkJSONTypeDepth(v.Type); err != nil {
+					return err
+				}
+
+				types[k] = v.Type
+			}
+			t.Type = Object(types)
+		case "tuple", "union":
+			var elems []jsonType
+			if err := json.Unmarshal(raw, &elems); err != nil {
+				return err
+			}
+
+			types := make([]Type, len(elems))
+			for i, e := range elems {
+				types[i] = e.Type
+			}
+			if err := checkJSONTypeDepth(types...); err != nil {
+				return err
+			}
+
+			if kind == "tuple" {
+				t.Type = Tuple(types...)
+			} else {
+				t.Type = Union(types...)
+			}
+		default:
+			return fmt.Errorf("unknown type %q", kind)
+		}
+
+		if t.Type == TUnsupported {
+			return fmt.Errorf("unknown type %s", data)
+		}
+	}
+
+	return nil
+}
+
+// checkJSONTypeDepth returns an error if a composite type built out of the
+// given types would be nested too deeply, see DecodeJSON.
+func checkJSONTypeDepth(types ...Type) error {
+	if depth(types...) > maxJSONTypeDepth {
+		return fmt.Errorf("type is nested more than %d levels deep", maxJSONTypeDepth)
+	}
+
+	return nil
+}
+
+func jsonTypes(types []Type) []jsonType {
+	result := make([]jsonType, len(types))
+	for i, t := range types {
+		result[i] = jsonType{t}
+	}
+
+	return result
+}
@@ -0,0 +1,246 @@
+package ast
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSON_roundTrip(t *testing.T) {
+	p := func(col, offset int) Pos {
+		return Pos{Column: col, Line: 1, Offset: offset}
+	}
+
+	cases := []Node{
+		&LiteralNode{Value: "foo", Typex: TString, Posx: p(1, 0), Endx: p(4, 3)},
+		&LiteralNode{Value: 42, Typex: TInt},
+		&LiteralNode{Value: 42.0, Typex: TFloat},
+		&LiteralNode{Value: 0.5, Typex: TFloat},
+		&LiteralNode{Value: true, Typex: TBool},
+		&LiteralNode{Value: ArithmeticOpSub, Typex: TInt},
+		&LiteralNode{
+			Value: []Variable{
+				{Type: TString, Value: "a"},
+				{Type: TInt, Value: 1},
+				{Type: List(TFloat), Value: []Variable{{Type: TFloat, Value: 2.0}}},
+			},
+			Typex: TList,
+		},
+		&LiteralNode{Value: []Variable{}, Typex: List(TString)},
+		&LiteralNode{
+			Value: map[string]Variable{
+				"name": {Type: TString, Value: "web"},
+				"port": {Type: TInt, Value: 80},
+			},
+			Typex: Object(map[string]Type{"name": TString, "port": TInt}),
+		},
+		&LiteralNode{
+			Value: []Variable{{Type: TString, Value: "a"}, {Type: TBool, Value: false}},
+			Typex: Tuple(TString, TBool),
+		},
+		&LiteralNode{
+			Value: map[string]Variable{"a": {Type: TInt, Value: 1}},
+			Typex: Map(Union(TInt, TFloat)),
+		},
+		&Output{
+			Exprs: []Node{
+				&LiteralNode{Value: "foo ", Typex: TString, Posx: p(1, 0), Endx: p(5, 4)},
+				&Arithmetic{
+					Op: ArithmeticOpMod,
+					Exprs: []Node{
+						&VariableAccess{Name: "var.a", Posx: p(7, 6), Endx: p(12, 11)},
+						&LiteralNode{Value: 2, Typex: TInt, Posx: p(15, 14), Endx: p(16, 15)},
+					},
+					Posx: p(7, 6),
+					Endx: p(16, 15),
+				},
+			},
+			Posx: p(1, 0),
+			Endx: p(17, 16),
+		},
+		&Call{
+			Func: "join",
+			Args: []Node{
+				&Index{
+					Target: &VariableAccess{Name: "var.list", Posx: p(6, 5), Endx: p(14, 13)},
+					Key:    &LiteralNode{Value: 0, Typex: TInt, Posx: p(15, 14), Endx: p(16, 15)},
+					Posx:   p(6, 5),
+					Endx:   p(17, 16),
+				},
+			},
+			NamedArgs: []*NamedArg{
+				{
+					Name:  "sep",
+					Value: &LiteralNode{Value: ",", Typex: TString, Posx: p(25, 24), Endx: p(28, 27)},
+					Posx:  p(19, 18),
+					Endx:  p(28, 27),
+				},
+			},
+			Posx: p(1, 0),
+			Endx: p(29, 28),
+		},
+	}
+
+	for _, tc := range cases {
+		data, err := EncodeJSON(tc)
+		if err != nil {
+			t.Fatalf("err: %s\n\n%#v", err, tc)
+		}
+
+		actual, err := DecodeJSON(data)
+		if err != nil {
+			t.Fatalf("err: %s\n\n%s", err, data)
+		}
+
+		if !reflect.DeepEqual(actual, tc) {
+			t.Fatalf("bad: %s\n\n%#v\n\n%#v", data, actual, tc)
+		}
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	n := &Output{
+		Exprs: []Node{
+			&Arithmetic{
+				Op: ArithmeticOpAdd,
+				Exprs: []Node{
+					&LiteralNode{Value: 1.0, Typex: TFloat},
+					&LiteralNode{Value: []Variable{}, Typex: List(TInt)},
+				},
+			},
+		},
+		Posx: Pos{Column: 1, Line: 1},
+		Endx: Pos{Column: 1, Line: 1},
+	}
+
+	expected := `{"version":1,"root":{"node":"Output",` +
+		`"pos":{"line":1,"column":1,"offset":0},` +
+		`"end":{"line":1,"column":1,"offset":0},"exprs":[` +
+		`{"node":"Arithmetic",` +
+		`"pos":{"line":0,"column":0,"offset":0},` +
+		`"end":{"line":0,"column":0,"offset":0},"op":"add","exprs":[` +
+		`{"node":"Literal",` +
+		`"pos":{"line":0,"column":0,"offset":0},` +
+		`"end":{"line":0,"column":0,"offset":0},"type":"float","value":1},` +
+		`{"node":"Literal",` +
+		`"pos":{"line":0,"column":0,"offset":0},` +
+		`"end":{"line":0,"column":0,"offset":0},"type":{"list":"int"},"value":[]}]}]}}`
+
+	data, err := EncodeJSON(n)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != expected {
+		t.Fatalf("bad:\n\n%s\n\nexpected:\n\n%s", data, expected)
+	}
+}
+
+type jsonUnknownNode struct{}
+
+func (jsonUnknownNode) Accept(v Visitor) Node    { return v(jsonUnknownNode{}) }
+func (jsonUnknownNode) Pos() Pos                 { return Pos{} }
+func (jsonUnknownNode) Type(Scope) (Type, error) { return TString, nil }
+
+func TestEncodeJSON_error(t *testing.T) {
+	cases := []struct {
+		Node  Node
+		Error string
+	}{
+		{
+			&LiteralNode{Value: math.NaN(), Typex: TFloat},
+			"can't encode float NaN",
+		},
+		{
+			&LiteralNode{Value: "1", Typex: TInt},
+			"value of TInt is a string",
+		},
+		{
+			&Output{Exprs: []Node{jsonUnknownNode{}}},
+			"can't encode node of type ast.jsonUnknownNode",
+		},
+		{
+			&Arithmetic{Op: ArithmeticOpInvalid},
+			"unknown arithmetic operation",
+		},
+	}
+
+	for _, tc := range cases {
+		_, err := EncodeJSON(tc.Node)
+		if err == nil || !strings.Contains(err.Error(), tc.Error) {
+			t.Fatalf("expected error containing %q, got: %v", tc.Error, err)
+		}
+	}
+}
+
+func TestDecodeJSON_error(t *testing.T) {
+	cases := []struct {
+		Input string
+		Error string
+	}{
+		{
+			`{"version":2,"root":{"node":"Output"}}`,
+			"unsupported AST JSON version 2",
+		},
+		{
+			`{"version":1}`,
+			"no root node",
+		},
+		{
+			`{"version":1,"root":{"node":"Foo"}}`,
+			`unknown node "Foo"`,
+		},
+		{
+			`{"version":1,"root":{"node":"Literal","type":"int","value":1.5}}`,
+			"invalid syntax",
+		},
+		{
+			`{"version":1,"root":{"node":"Literal","type":"strin","value":""}}`,
+			`unknown type "strin"`,
+		},
+		{
+			`{"version":1,"root":{"node":"Literal","type":{"list":` +
+				strings.Repeat(`{"list":`, maxJSONTypeDepth) + `"int"` +
+				strings.Repeat("}", maxJSONTypeDepth+1) + `,"value":[]}}`,
+			"type is nested more than 32 levels deep",
+		},
+		{
+			`{"version":1,"root":{"node":"Literal","type":{"tuple":[` +
+				strings.Repeat(`"int",`, maxJSONTypeSize/6) + `"int"]},"value":[]}}`,
+			"type is larger than 65536 bytes",
+		},
+		{
+			`{"version":1,"root":{"node":"Arithmetic","op":"pow"}}`,
+			`unknown arithmetic operation "pow"`,
+		},
+		{
+			`{"version":1,"root":{"node":"Literal","type":"int","op":"pow"}}`,
+			`unknown arithmetic operation "pow"`,
+		},
+		{
+			`{"version":1,"root":{"node":"Index","key":{"node":"Literal","type":"int","value":1}}}`,
+			"missing node",
+		},
+	}
+
+	for _, tc := range cases {
+		_, err := DecodeJSON([]byte(tc.Input))
+		if err == nil || !strings.Contains(err.Error(), tc.Error) {
+			t.Fatalf("%s: expected error containing %q, got: %v", tc.Input, tc.Error, err)
+		}
+	}
+}
+
+func TestDecodeJSON_newType(t *testing.T) {
+	// The type isn't created anywhere else, so decoding has to create it
+	input := `{"version":1,"root":{"node":"Literal","type":{"object":{"only decoded":{"list":"bool"}}},"value":{}}}`
+	n, err := DecodeJSON([]byte(input))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Object(map[string]Type{"only decoded": List(TBool)})
+	if actual := n.(*LiteralNode).Typex; actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+}
@@ -10,6 +10,7 @@ type LiteralNode struct {
 	Value interface{}
 	Typex Type
 	Posx  Pos
+	Endx  Pos
 }
 
 func (n *LiteralNode) Accept(v Visitor) Node {
@@ -20,6 +21,10 @@ func (n *LiteralNode) Pos() Pos {
 	return n.Posx
 }
 
+func (n *LiteralNode) End() Pos {
+	return n.Endx
+}
+
 func (n *LiteralNode) GoString() string {
 	return fmt.Sprintf("*%#v", *n)
 }
@@ -13,6 +13,7 @@ import (
 type Output struct {
 	Exprs []Node
 	Posx  Pos
+	Endx  Pos
 }
 
 func (n *Output) Accept(v Visitor) Node {
@@ -27,6 +28,10 @@ func (n *Output) Pos() Pos {
 	return n.Posx
 }
 
+func (n *Output) End() Pos {
+	return n.Endx
+}
+
 func (n *Output) GoString() string {
 	return fmt.Sprintf("*%#v", *n)
 }
@@ -52,11 +57,9 @@ func (n *Output) Type(s Scope) (Type, error) {
 		if err != nil {
 			return TUnsupported, err
 		}
-		switch exprType {
-		case TList:
-			return TList, nil
-		case TMap:
-			return TMap, nil
+		switch exprType.Kind() {
+		case TList, TMap:
+			return exprType, nil
 		}
 	}
 
@@ -67,7 +70,7 @@ func (n *Output) Type(s Scope) (Type, error) {
 			return TUnsupported, err
 		}
 		// We only look for things we know we can't coerce with an implicit conversion func
-		if exprType == TList || exprType == TMap {
+		if exprType.Kind() == TList || exprType.Kind() == TMap {
 			return TUnsupported, fmt.Errorf(
 				"multi-expression STOP outputs may only have string inputs: %d is type %s",
 				index, exprType)
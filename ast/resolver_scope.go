@@ -0,0 +1,143 @@
+package ast
+
+import (
+	"strings"
+)
+
+// Resolver resolves variables on demand for a ResolverScope.
+type Resolver interface {
+	// Resolve returns the variable with the given name, which has the
+	// prefix the resolver is registered under stripped off. The second
+	// return value is false if the variable doesn't exist (or couldn't
+	// be resolved).
+	Resolve(name string) (Variable, bool)
+}
+
+// ResolverFunc is a function that implements Resolver.
+type ResolverFunc func(name string) (Variable, bool)
+
+func (f ResolverFunc) Resolve(name string) (Variable, bool) {
+	return f(name)
+}
+
+// Exister can optionally be implemented by a Resolver to tell whether a
+// variable exists without resolving its value. It is used by HasVar, and
+// thus by the identifier check, to avoid doing expensive work early.
+type Exister interface {
+	Exists(name string) bool
+}
+
+// Lister can optionally be implemented by a Resolver to enumerate the
+// names of the variables it can resolve, without the prefix. It is used by
+// the ResolverScope to implement NameLister.
+type Lister interface {
+	Names() []string
+}
+
+// ResolverScope is a scope that resolves variables lazily by calling a
+// Resolver the first time they're looked up. Resolvers are registered by
+// the prefix of the variable names they handle, such as "env.", and the
+// longest matching prefix wins. Variables that don't match any prefix, as
+// well as all functions, are looked up in the Parent scope.
+//
+// The scope doesn't cache anything: every lookup calls the resolver.
+// Eval caches the variables it looks up for the duration of a single
+// evaluation, so a variable is resolved at most once per evaluation and
+// concurrent evaluations don't share values.
+type ResolverScope struct {
+	Parent    Scope
+	Resolvers map[string]Resolver
+}
+
+func (s *ResolverScope) LookupFunc(n string) (Function, bool) {
+	if s == nil || s.Parent == nil {
+		return Function{}, false
+	}
+
+	return s.Parent.LookupFunc(n)
+}
+
+func (s *ResolverScope) LookupVar(n string) (Variable, bool) {
+	if s == nil {
+		return Variable{}, false
+	}
+
+	prefix, r := s.resolver(n)
+	if r == nil {
+		if s.Parent == nil {
+			return Variable{}, false
+		}
+
+		return s.Parent.LookupVar(n)
+	}
+
+	return r.Resolve(n[len(prefix):])
+}
+
+// HasVar implements VarExister. If the resolver for the variable
+// implements Exister, the variable isn't resolved.
+func (s *ResolverScope) HasVar(n string) bool {
+	if s == nil {
+		return false
+	}
+
+	prefix, r := s.resolver(n)
+	if r == nil {
+		return HasVar(s.Parent, n)
+	}
+
+	if e, ok := r.(Exister); ok {
+		return e.Exists(n[len(prefix):])
+	}
+
+	_, ok := s.LookupVar(n)
+	return ok
+}
+
+// VarNames implements NameLister. It includes the names of the resolvers
+// that implement Lister and those of the parent scope.
+func (s *ResolverScope) VarNames() []string {
+	if s == nil {
+		return nil
+	}
+
+	var names []string
+	for prefix, r := range s.Resolvers {
+		l, ok := r.(Lister)
+		if !ok {
+			continue
+		}
+
+		for _, n := range l.Names() {
+			// A resolver with a longer prefix may take over the name
+			if p, _ := s.resolver(prefix + n); p == prefix {
+				names = append(names, prefix+n)
+			}
+		}
+	}
+
+	return mergeNames(names, VarNames(s.Parent))
+}
+
+// FuncNames implements NameLister by returning the names of the parent.
+func (s *ResolverScope) FuncNames() []string {
+	if s == nil {
+		return nil
+	}
+
+	return FuncNames(s.Parent)
+}
+
+// resolver returns the resolver with the longest prefix matching the
+// given variable name, along with that prefix.
+func (s *ResolverScope) resolver(n string) (string, Resolver) {
+	var prefix string
+	var result Resolver
+	for p, r := range s.Resolvers {
+		if strings.HasPrefix(n, p) && (result == nil || len(p) > len(prefix)) {
+			prefix, result = p, r
+		}
+	}
+
+	return prefix, result
+}
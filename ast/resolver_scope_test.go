@@ -0,0 +1,106 @@
+package ast
+
+import (
+	"testing"
+)
+
+type testExister struct {
+	ResolverFunc
+	names map[string]bool
+}
+
+func (e *testExister) Exists(n string) bool {
+	return e.names[n]
+}
+
+func TestResolverScope_impl(t *testing.T) {
+	var _ Scope = new(ResolverScope)
+	var _ VarExister = new(ResolverScope)
+}
+
+func TestResolverScopeLookupVar(t *testing.T) {
+	calls := make(map[string]int)
+	env := ResolverFunc(func(n string) (Variable, bool) {
+		calls[n]++
+		if n != "HOME" {
+			return Variable{}, false
+		}
+
+		return Variable{Type: TString, Value: "/home/foo"}, true
+	})
+
+	scope := &ResolverScope{
+		Parent: &BasicScope{
+			VarMap: map[string]Variable{
+				"var.foo": Variable{Type: TString, Value: "bar"},
+			},
+		},
+		Resolvers: map[string]Resolver{
+			"env.": env,
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		v, ok := scope.LookupVar("env.HOME")
+		if !ok || v.Value != "/home/foo" {
+			t.Fatalf("bad: %#v", v)
+		}
+		if _, ok := scope.LookupVar("env.NOPE"); ok {
+			t.Fatal("should not find env.NOPE")
+		}
+	}
+	if calls["HOME"] != 2 || calls["NOPE"] != 2 {
+		t.Fatalf("values should be resolved on every lookup: %#v", calls)
+	}
+
+	if v, ok := scope.LookupVar("var.foo"); !ok || v.Value != "bar" {
+		t.Fatalf("should find var.foo in parent: %#v", v)
+	}
+}
+
+func TestResolverScope_longestPrefix(t *testing.T) {
+	constant := func(s string) Resolver {
+		return ResolverFunc(func(n string) (Variable, bool) {
+			return Variable{Type: TString, Value: s + ":" + n}, true
+		})
+	}
+
+	scope := &ResolverScope{
+		Resolvers: map[string]Resolver{
+			"file.":     constant("file"),
+			"file.etc.": constant("etc"),
+		},
+	}
+
+	if v, _ := scope.LookupVar("file.foo"); v.Value != "file:foo" {
+		t.Fatalf("bad: %#v", v)
+	}
+	if v, _ := scope.LookupVar("file.etc.hosts"); v.Value != "etc:hosts" {
+		t.Fatalf("bad: %#v", v)
+	}
+}
+
+func TestResolverScopeHasVar(t *testing.T) {
+	resolved := false
+	secret := &testExister{
+		ResolverFunc: func(n string) (Variable, bool) {
+			resolved = true
+			return Variable{Type: TString, Value: "hunter2"}, true
+		},
+		names: map[string]bool{"db": true},
+	}
+
+	scope := WithVariables(&ResolverScope{
+		Resolvers: map[string]Resolver{"secret.": secret},
+	}, nil)
+
+	if !HasVar(scope, "secret.db") {
+		t.Fatal("should have secret.db")
+	}
+	if HasVar(scope, "secret.nope") {
+		t.Fatal("should not have secret.nope")
+	}
+	if resolved {
+		t.Fatal("should not resolve the value")
+	}
+}
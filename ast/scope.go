@@ -1,8 +1,10 @@
 package ast
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"sort"
 )
 
 // Scope is the interface used to look up variables and functions wSTOPe
@@ -12,6 +14,73 @@ type Scope interface {
 	LookupVar(string) (Variable, bool)
 }
 
+// VarExister is an optional interface that a Scope can implement if it
+// can tell whether a variable exists more cheaply than by looking it up.
+type VarExister interface {
+	HasVar(string) bool
+}
+
+// HasVar returns whether the variable with the given name exists in the
+// scope. It uses VarExister if the scope implements it and falls back to
+// LookupVar otherwise.
+func HasVar(s Scope, n string) bool {
+	if s == nil {
+		return false
+	}
+
+	if e, ok := s.(VarExister); ok {
+		return e.HasVar(n)
+	}
+
+	_, ok := s.LookupVar(n)
+	return ok
+}
+
+// NameLister is an optional interface that a Scope can implement to
+// enumerate the names of its variables and functions. It is used to
+// suggest similar names when an unknown one is used.
+type NameLister interface {
+	VarNames() []string
+	FuncNames() []string
+}
+
+// VarNames returns the sorted names of the variables in the scope, or nil
+// if the scope doesn't implement NameLister.
+func VarNames(s Scope) []string {
+	if l, ok := s.(NameLister); ok && s != nil {
+		return l.VarNames()
+	}
+
+	return nil
+}
+
+// FuncNames returns the sorted names of the functions in the scope, or
+// nil if the scope doesn't implement NameLister.
+func FuncNames(s Scope) []string {
+	if l, ok := s.(NameLister); ok && s != nil {
+		return l.FuncNames()
+	}
+
+	return nil
+}
+
+// mergeNames returns the sorted union of the given lists of names.
+func mergeNames(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, list := range lists {
+		for _, n := range list {
+			if !seen[n] {
+				seen[n] = true
+				result = append(result, n)
+			}
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
+
 // Variable is a variable value for execution given as input to the engine.
 // It records the value of a variables along with their type.
 type Variable struct {
@@ -51,6 +120,17 @@ type Function struct {
 	ArgTypes   []Type
 	ReturnType Type
 
+	// ArgNames are the names of the arguments in ArgTypes, allowing them
+	// to be passed by name, such as width = 10. It may be shorter than
+	// ArgTypes, in which case the remaining arguments can only be passed
+	// positionally.
+	//
+	// Defaults are the values of trailing optional arguments: the last
+	// len(Defaults) arguments in ArgTypes may be omitted, in which case
+	// the Callback receives these values instead.
+	ArgNames []string
+	Defaults []Variable
+
 	// Variadic, if true, says that this function is variadic, meaning
 	// it takes a variable number of arguments. In this case, the
 	// VariadicType must be set.
@@ -62,6 +142,27 @@ type Function struct {
 	// The length of the args is strictly == len(ArgTypes) unless Varidiac
 	// is true, in which case its >= len(ArgTypes).
 	Callback func([]interface{}) (interface{}, error)
+
+	// ContextCallback is like Callback but additionally receives the
+	// context the evaluation was started with. Long-running functions
+	// should watch it and return early once it is done. If both are set,
+	// ContextCallback takes precedence over Callback.
+	ContextCallback func(context.Context, []interface{}) (interface{}, error)
+
+	// ReturnTypeFunc, if set, computes the return type from the types of
+	// the arguments instead of using ReturnType. This allows functions such
+	// as max(union(int, float)...) to return the type they were called
+	// with. It is called by the type checker as well as at evaluation time
+	// with the types of the actual argument values.
+	ReturnTypeFunc func(args []Type) (Type, error)
+
+	// Overloads, if not empty, makes this function an overload set. Calls
+	// are resolved to the first overload whose signature accepts the
+	// argument types. If there is none, the type checker uses the one
+	// overload that accepts them after converting ints to floats, and
+	// reports the call as ambiguous if several do. All other fields of
+	// this function are ignored.
+	Overloads []Function
 }
 
 // BasicScope is a simple scope that looks up variables and functions
@@ -88,3 +189,29 @@ func (s *BasicScope) LookupVar(n string) (Variable, bool) {
 	v, ok := s.VarMap[n]
 	return v, ok
 }
+
+func (s *BasicScope) VarNames() []string {
+	if s == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(s.VarMap))
+	for n := range s.VarMap {
+		names = append(names, n)
+	}
+
+	return mergeNames(names)
+}
+
+func (s *BasicScope) FuncNames() []string {
+	if s == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(s.FuncMap))
+	for n := range s.FuncMap {
+		names = append(names, n)
+	}
+
+	return mergeNames(names)
+}
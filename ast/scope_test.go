@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -52,3 +53,58 @@ func TestVariableStringer(t *testing.T) {
 			expected, actual)
 	}
 }
+
+func TestScopeNames(t *testing.T) {
+	basic := &BasicScope{
+		FuncMap: map[string]Function{"lower": Function{}, "upper": Function{}},
+		VarMap:  map[string]Variable{"var.b": Variable{}, "var.a": Variable{}},
+	}
+	envNames := ResolverFunc(func(string) (Variable, bool) { return Variable{}, false })
+
+	cases := []struct {
+		Scope Scope
+		Vars  []string
+		Funcs []string
+	}{
+		{basic, []string{"var.a", "var.b"}, []string{"lower", "upper"}},
+		{
+			WithVariables(basic, map[string]Variable{"var.c": Variable{}, "var.a": Variable{}}),
+			[]string{"var.a", "var.b", "var.c"},
+			[]string{"lower", "upper"},
+		},
+		{
+			&ResolverScope{
+				Parent: basic,
+				Resolvers: map[string]Resolver{
+					"env.":        &testLister{envNames, []string{"HOME", "SECRET.KEY"}},
+					"env.SECRET.": envNames,
+				},
+			},
+			[]string{"env.HOME", "var.a", "var.b"},
+			[]string{"lower", "upper"},
+		},
+		{
+			struct{ Scope }{basic},
+			nil,
+			nil,
+		},
+	}
+
+	for i, tc := range cases {
+		if actual := VarNames(tc.Scope); !reflect.DeepEqual(actual, tc.Vars) {
+			t.Fatalf("%d: bad vars: %#v", i, actual)
+		}
+		if actual := FuncNames(tc.Scope); !reflect.DeepEqual(actual, tc.Funcs) {
+			t.Fatalf("%d: bad funcs: %#v", i, actual)
+		}
+	}
+}
+
+type testLister struct {
+	ResolverFunc
+	names []string
+}
+
+func (l *testLister) Names() []string {
+	return l.names
+}
@@ -0,0 +1,562 @@
+package ast
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// typeComposite is set on every Type that isn't one of the basic types
+// declared above. The remaining bits of such a type are an index into the
+// table of composite types.
+const typeComposite Type = 1 << 31
+
+// compositeType describes a Type built out of other types, such as a list
+// of strings.
+type compositeType struct {
+	// Kind is the basic type that values of this type have, i.e. TList
+	// for a list of strings.
+	Kind Type
+
+	// Elem is the type of the elements of a list or map.
+	Elem Type
+
+	// Attrs are the types of the attributes of an object.
+	Attrs map[string]Type
+
+	// Elems are the types of the elements of a tuple.
+	Elems []Type
+
+	// Members are the types a value of a union type can have.
+	Members []Type
+
+	// Name is the canonical name of the type, such as "list(string)".
+	// Composite types with the same name are the same type.
+	Name string
+
+	// Depth is how deeply composite types are nested in this type, 1 for
+	// a list of strings and 2 for a list of lists of strings.
+	Depth int
+}
+
+// compositeTypes is the table of all composite types created so far.
+// Composite types are interned so that types can keep being compared with
+// == and used as map keys.
+var compositeTypes struct {
+	sync.RWMutex
+	list   []compositeType
+	byName map[string]Type
+}
+
+// internType returns the Type for the given composite type, adding it to
+// the table if it isn't there yet.
+func internType(c compositeType) Type {
+	compositeTypes.RLock()
+	t, ok := compositeTypes.byName[c.Name]
+	compositeTypes.RUnlock()
+	if ok {
+		return t
+	}
+
+	compositeTypes.Lock()
+	defer compositeTypes.Unlock()
+	if t, ok := compositeTypes.byName[c.Name]; ok {
+		return t
+	}
+
+	if compositeTypes.byName == nil {
+		compositeTypes.byName = make(map[string]Type)
+	}
+
+	t = typeComposite | Type(len(compositeTypes.list))
+	compositeTypes.list = append(compositeTypes.list, c)
+	compositeTypes.byName[c.Name] = t
+	return t
+}
+
+// composite returns the description of a composite type.
+func (t Type) composite() (compositeType, bool) {
+	if t&typeComposite == 0 {
+		return compositeType{}, false
+	}
+
+	compositeTypes.RLock()
+	defer compositeTypes.RUnlock()
+	i := int(t &^ typeComposite)
+	if i >= len(compositeTypes.list) {
+		return compositeType{}, false
+	}
+
+	return compositeTypes.list[i], true
+}
+
+// depth returns the depth of the most deeply nested of the given types
+// plus one, which is the depth of a composite type built out of them.
+func depth(types ...Type) int {
+	max := 0
+	for _, t := range types {
+		if c, ok := t.composite(); ok && c.Depth > max {
+			max = c.Depth
+		}
+	}
+
+	return max + 1
+}
+
+// List returns the type of a list whose elements are all of type elem,
+// i.e. list(string). Values of the type are []Variable just like for
+// TList, but the element type is known without looking at the elements,
+// so even empty lists can be type checked.
+func List(elem Type) Type {
+	return internType(compositeType{
+		Kind:  TList,
+		Elem:  elem,
+		Name:  fmt.Sprintf("list(%s)", elem.name()),
+		Depth: depth(elem),
+	})
+}
+
+// Map returns the type of a map whose values are all of type elem,
+// i.e. map(string). Values of the type are map[string]Variable just like
+// for TMap.
+func Map(elem Type) Type {
+	return internType(compositeType{
+		Kind:  TMap,
+		Elem:  elem,
+		Name:  fmt.Sprintf("map(%s)", elem.name()),
+		Depth: depth(elem),
+	})
+}
+
+// Object returns the type of a map with a fixed set of keys, called
+// attributes, whose values each have their own type. Values of the type are
+// map[string]Variable just like for TMap. Indexing an object with a literal
+// key results in the type of that attribute.
+func Object(attrs map[string]Type) Type {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	copied := make(map[string]Type, len(attrs))
+	types := make([]Type, len(keys))
+	for i, k := range keys {
+		// Keys are quoted so that keys containing ", " or ": " can't make
+		// different objects share a name
+		parts[i] = fmt.Sprintf("%s: %s", strconv.Quote(k), attrs[k].name())
+		copied[k] = attrs[k]
+		types[i] = attrs[k]
+	}
+
+	return internType(compositeType{
+		Kind:  TMap,
+		Attrs: copied,
+		Name:  fmt.Sprintf("object(%s)", strings.Join(parts, ", ")),
+		Depth: depth(types...),
+	})
+}
+
+// Tuple returns the type of a list with a fixed number of elements, each
+// with its own type. Values of the type are []Variable just like for TList.
+// Indexing a tuple with a literal index results in the type of that element.
+func Tuple(elems ...Type) Type {
+	parts := make([]string, len(elems))
+	for i, e := range elems {
+		parts[i] = e.name()
+	}
+
+	// Elems must not be nil, even for the empty tuple, to mark the type as
+	// a tuple
+	copied := make([]Type, len(elems))
+	copy(copied, elems)
+
+	return internType(compositeType{
+		Kind:  TList,
+		Elems: copied,
+		Name:  fmt.Sprintf("tuple(%s)", strings.Join(parts, ", ")),
+		Depth: depth(copied...),
+	})
+}
+
+// Union returns a type that accepts values of any of the given types, i.e.
+// union(float, int). It is meant for declaring the argument types of
+// functions; values always have one of the member types. Nested unions are
+// flattened, and a union of a single type is that type.
+func Union(types ...Type) Type {
+	seen := make(map[Type]bool)
+	var members []Type
+	var add func(t Type)
+	add = func(t Type) {
+		if c, ok := t.composite(); ok && c.Members != nil {
+			for _, m := range c.Members {
+				add(m)
+			}
+
+			return
+		}
+
+		if !seen[t] {
+			seen[t] = true
+			members = append(members, t)
+		}
+	}
+	for _, t := range types {
+		add(t)
+	}
+
+	if seen[TAny] {
+		return TAny
+	}
+	switch len(members) {
+	case 0:
+		return TUnsupported
+	case 1:
+		return members[0]
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].name() < members[j].name()
+	})
+	parts := make([]string, len(members))
+	for i, m := range members {
+		parts[i] = m.name()
+	}
+
+	return internType(compositeType{
+		Kind:    TAny,
+		Members: members,
+		Name:    fmt.Sprintf("union(%s)", strings.Join(parts, ", ")),
+		Depth:   depth(members...),
+	})
+}
+
+// AssignableTo reports whether the value of the variable can be used where
+// a value of type t is expected. The elements of lists and maps are
+// checked against the element, attribute or tuple types of t one by one,
+// so values such as decoded JSON can be checked without creating a type
+// for them.
+func (v Variable) AssignableTo(t Type) bool {
+	if t == TAny {
+		return true
+	}
+
+	if members := t.UnionTypes(); members != nil {
+		for _, m := range members {
+			if v.AssignableTo(m) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	switch value := v.Value.(type) {
+	case []Variable:
+		if v.Type.Kind() != TList || len(value) == 0 {
+			break
+		}
+		if t.Kind() != TList {
+			return false
+		}
+
+		c, ok := t.composite()
+		switch {
+		case !ok:
+			return true
+		case c.Elems != nil:
+			if len(value) != len(c.Elems) {
+				return false
+			}
+
+			for i, e := range value {
+				if !e.AssignableTo(c.Elems[i]) {
+					return false
+				}
+			}
+
+			return true
+		default:
+			for _, e := range value {
+				if !e.AssignableTo(c.Elem) {
+					return false
+				}
+			}
+
+			return true
+		}
+	case map[string]Variable:
+		if v.Type.Kind() != TMap {
+			break
+		}
+		if t.Kind() != TMap {
+			return false
+		}
+
+		c, ok := t.composite()
+		switch {
+		case !ok:
+			return true
+		case c.Attrs != nil:
+			if len(value) != len(c.Attrs) {
+				return false
+			}
+
+			for k, e := range value {
+				attr, ok := c.Attrs[k]
+				if !ok || !e.AssignableTo(attr) {
+					return false
+				}
+			}
+
+			return true
+		default:
+			for _, e := range value {
+				if !e.AssignableTo(c.Elem) {
+					return false
+				}
+			}
+
+			return true
+		}
+	}
+
+	return v.Type.AssignableTo(t)
+}
+
+// Kind returns the basic type of values of this type. For the basic types
+// this is the type itself, for list(string) it is TList. Unions have the
+// kind TAny.
+func (t Type) Kind() Type {
+	if c, ok := t.composite(); ok {
+		return c.Kind
+	}
+
+	return t
+}
+
+// ElemType returns the element type of a list or map type. The second
+// return value is false if the type doesn't declare a single element type,
+// as is the case for TList and TMap as well as objects and tuples.
+func (t Type) ElemType() (Type, bool) {
+	c, ok := t.composite()
+	if !ok || c.Elem == TUnsupported {
+		return TUnsupported, false
+	}
+
+	return c.Elem, true
+}
+
+// AttrType returns the type of the attribute with the given name of an
+// object type. The second return value is false if t isn't an object type
+// or has no such attribute.
+func (t Type) AttrType(name string) (Type, bool) {
+	c, ok := t.composite()
+	if !ok {
+		return TUnsupported, false
+	}
+
+	attr, ok := c.Attrs[name]
+	return attr, ok
+}
+
+// AttrTypes returns the attribute types of an object type, or nil if t
+// isn't an object type. The map must not be modified.
+func (t Type) AttrTypes() map[string]Type {
+	c, _ := t.composite()
+	return c.Attrs
+}
+
+// TupleTypes returns the element types of a tuple type, or nil if t isn't
+// a tuple type. The slice must not be modified.
+func (t Type) TupleTypes() []Type {
+	c, _ := t.composite()
+	return c.Elems
+}
+
+// UnionTypes returns the member types of a union type, or nil if t isn't a
+// union type. The slice must not be modified.
+func (t Type) UnionTypes() []Type {
+	c, _ := t.composite()
+	return c.Members
+}
+
+// IsUnion reports whether t is a union type.
+func (t Type) IsUnion() bool {
+	c, _ := t.composite()
+	return c.Members != nil
+}
+
+// IsObject reports whether t is an object type.
+func (t Type) IsObject() bool {
+	c, _ := t.composite()
+	return c.Attrs != nil
+}
+
+// IsTuple reports whether t is a tuple type.
+func (t Type) IsTuple() bool {
+	c, _ := t.composite()
+	return c.Elems != nil
+}
+
+// AssignableTo reports whether a value of type t can be used where a value
+// of type u is expected. Besides identical types this is the case if:
+//
+//   - u is TAny
+//   - u is a union and t is assignable to one of its members
+//   - t is a union and all of its members are assignable to u
+//   - both are lists or maps whose element types are assignable
+//   - both are objects with the same attributes whose types are assignable
+//   - both are tuples of the same length whose element types are assignable
+//   - t is an object or tuple and u is a map or list respectively whose
+//     element type all attributes or elements are assignable to
+//
+// TList and TMap, which don't declare their element types, are assignable
+// to and from any list or map type respectively.
+func (t Type) AssignableTo(u Type) bool {
+	if t == u || u == TAny {
+		return true
+	}
+
+	if members := t.UnionTypes(); members != nil {
+		for _, m := range members {
+			if !m.AssignableTo(u) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if members := u.UnionTypes(); members != nil {
+		for _, m := range members {
+			if t.AssignableTo(m) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if t.Kind() != u.Kind() || (t.Kind() != TList && t.Kind() != TMap) {
+		return false
+	}
+
+	tc, tok := t.composite()
+	uc, uok := u.composite()
+	if !tok || !uok {
+		return true
+	}
+
+	// The element types t may contain
+	var tElems []Type
+	switch {
+	case tc.Attrs != nil:
+		if uc.Attrs != nil {
+			if len(tc.Attrs) != len(uc.Attrs) {
+				return false
+			}
+
+			for k, ta := range tc.Attrs {
+				ua, ok := uc.Attrs[k]
+				if !ok || !ta.AssignableTo(ua) {
+					return false
+				}
+			}
+
+			return true
+		}
+
+		for _, ta := range tc.Attrs {
+			tElems = append(tElems, ta)
+		}
+	case tc.Elems != nil:
+		if uc.Elems != nil {
+			if len(tc.Elems) != len(uc.Elems) {
+				return false
+			}
+
+			for i, te := range tc.Elems {
+				if !te.AssignableTo(uc.Elems[i]) {
+					return false
+				}
+			}
+
+			return true
+		}
+
+		tElems = tc.Elems
+	default:
+		tElems = []Type{tc.Elem}
+	}
+
+	// u must be list(T) or map(T) for this to work since we can't know
+	// which elements or attributes a value of type t has.
+	if uc.Elem == TUnsupported {
+		return false
+	}
+
+	for _, te := range tElems {
+		if !te.AssignableTo(uc.Elem) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// name returns the name of the type as used in composite type names.
+func (t Type) name() string {
+	if c, ok := t.composite(); ok {
+		return c.Name
+	}
+
+	switch t {
+	case TAny:
+		return "any"
+	case TString:
+		return "string"
+	case TInt:
+		return "int"
+	case TFloat:
+		return "float"
+	case TList:
+		return "list"
+	case TMap:
+		return "map"
+	case TBool:
+		return "bool"
+	default:
+		return t.String()
+	}
+}
+
+func (t Type) String() string {
+	if c, ok := t.composite(); ok {
+		return c.Name
+	}
+
+	switch t {
+	case TUnsupported:
+		return "TUnsupported"
+	case TAny:
+		return "TAny"
+	case TString:
+		return "TString"
+	case TInt:
+		return "TInt"
+	case TFloat:
+		return "TFloat"
+	case TList:
+		return "TList"
+	case TMap:
+		return "TMap"
+	case TBool:
+		return "TBool"
+	default:
+		return fmt.Sprintf("Type(%d)", t)
+	}
+}
@@ -0,0 +1,234 @@
+package ast
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCompositeType_identity(t *testing.T) {
+	if List(TString) != List(TString) {
+		t.Fatal("list(string) should equal itself")
+	}
+	if List(TString) == List(TInt) {
+		t.Fatal("list(string) should not equal list(int)")
+	}
+	if List(TString) == Map(TString) {
+		t.Fatal("list(string) should not equal map(string)")
+	}
+	if Map(List(TInt)) != Map(List(TInt)) {
+		t.Fatal("map(list(int)) should equal itself")
+	}
+}
+
+func TestCompositeType(t *testing.T) {
+	cases := []struct {
+		Type      Type
+		Kind      Type
+		Elem      Type
+		String    string
+		Printable string
+	}{
+		{TString, TString, TUnsupported, "TString", "type string"},
+		{TList, TList, TUnsupported, "TList", "type list"},
+		{List(TString), TList, TString, "list(string)", "type list(string)"},
+		{Map(TInt), TMap, TInt, "map(int)", "type map(int)"},
+		{Map(List(TInt)), TMap, List(TInt), "map(list(int))", "type map(list(int))"},
+		{List(TMap), TList, TMap, "list(map)", "type list(map)"},
+		{
+			Object(map[string]Type{"port": TInt, "name": TString}),
+			TMap, TUnsupported,
+			`object("name": string, "port": int)`,
+			`type object("name": string, "port": int)`,
+		},
+		{Tuple(TString, TInt), TList, TUnsupported, "tuple(string, int)", "type tuple(string, int)"},
+	}
+
+	for _, tc := range cases {
+		if tc.Type.Kind() != tc.Kind {
+			t.Fatalf("%s: bad kind: %s", tc.String, tc.Type.Kind())
+		}
+		elem, ok := tc.Type.ElemType()
+		if elem != tc.Elem || ok != (tc.Elem != TUnsupported) {
+			t.Fatalf("%s: bad elem: %s", tc.String, elem)
+		}
+		if tc.Type.String() != tc.String {
+			t.Fatalf("%s: bad string: %s", tc.String, tc.Type.String())
+		}
+		if tc.Type.Printable() != tc.Printable {
+			t.Fatalf("%s: bad printable: %s", tc.String, tc.Type.Printable())
+		}
+	}
+}
+
+func TestTypeAssignableTo(t *testing.T) {
+	obj := Object(map[string]Type{"name": TString, "port": TInt})
+	cases := []struct {
+		From, To Type
+		Result   bool
+	}{
+		{TString, TString, true},
+		{TString, TInt, false},
+		{TString, TAny, true},
+		{List(TString), TAny, true},
+		{List(TString), List(TString), true},
+		{List(TString), List(TInt), false},
+		{List(TString), List(TAny), true},
+		{List(TString), TList, true},
+		{TList, List(TString), true},
+		{List(TString), Map(TString), false},
+		{Map(List(TInt)), Map(List(TInt)), true},
+		{Map(List(TInt)), Map(List(TString)), false},
+		{Map(List(TInt)), Map(TList), true},
+		{TList, TMap, false},
+		{obj, obj, true},
+		{obj, Object(map[string]Type{"name": TString, "port": TAny}), true},
+		{obj, Object(map[string]Type{"name": TString}), false},
+		{obj, Object(map[string]Type{"name": TString, "host": TInt}), false},
+		{obj, TMap, true},
+		{obj, Map(TAny), true},
+		{obj, Map(TString), false},
+		{Map(TString), obj, false},
+		{TMap, obj, true},
+		{Tuple(TString, TInt), Tuple(TString, TInt), true},
+		{Tuple(TString, TInt), Tuple(TString), false},
+		{Tuple(TString, TString), List(TString), true},
+		{Tuple(TString, TInt), List(TString), false},
+		{Tuple(TString, TInt), TList, true},
+		{List(TString), Tuple(TString), false},
+		{TInt, Union(TInt, TFloat), true},
+		{TString, Union(TInt, TFloat), false},
+		{Union(TInt, TFloat), Union(TFloat, TInt, TString), true},
+		{Union(TInt, TString), Union(TInt, TFloat), false},
+		{Union(TInt, TFloat), TInt, false},
+		{List(TInt), List(Union(TInt, TFloat)), true},
+	}
+
+	for _, tc := range cases {
+		if tc.From.AssignableTo(tc.To) != tc.Result {
+			t.Fatalf("%s assignable to %s should be %t", tc.From, tc.To, tc.Result)
+		}
+	}
+}
+
+func TestObjectType(t *testing.T) {
+	obj := Object(map[string]Type{"name": TString, "port": TInt})
+	if obj != Object(map[string]Type{"port": TInt, "name": TString}) {
+		t.Fatal("objects with the same attributes should be equal")
+	}
+	if !obj.IsObject() || obj.IsTuple() {
+		t.Fatalf("%s should be an object", obj)
+	}
+	if attr, ok := obj.AttrType("port"); !ok || attr != TInt {
+		t.Fatalf("bad attribute type: %s", attr)
+	}
+	if _, ok := obj.AttrType("host"); ok {
+		t.Fatal("host shouldn't be an attribute")
+	}
+	if Map(TInt).IsObject() {
+		t.Fatal("map(int) shouldn't be an object")
+	}
+
+	tuple := Tuple(TString, TInt)
+	if !tuple.IsTuple() || tuple.IsObject() {
+		t.Fatalf("%s should be a tuple", tuple)
+	}
+	if elems := tuple.TupleTypes(); len(elems) != 2 || elems[1] != TInt {
+		t.Fatalf("bad tuple types: %v", elems)
+	}
+	if empty := Tuple(); !empty.IsTuple() || empty == TList {
+		t.Fatalf("%s should be a tuple", empty)
+	}
+
+	// Keys that look like attributes must not collide
+	a := Object(map[string]Type{"a: int, b": TString})
+	b := Object(map[string]Type{"a": TInt, "b": TString})
+	if a == b {
+		t.Fatalf("%s should not equal %s", a, b)
+	}
+}
+
+func TestVariableAssignableTo(t *testing.T) {
+	config := Variable{Type: TMap, Value: map[string]Variable{
+		"name": {Type: TString, Value: "foo"},
+		"tags": {Type: TList, Value: []Variable{
+			{Type: TString, Value: "bar"},
+		}},
+	}}
+	pair := Variable{Type: TList, Value: []Variable{
+		{Type: TString, Value: "foo"},
+		{Type: TInt, Value: 1},
+	}}
+
+	cases := []struct {
+		Input      Variable
+		Type       Type
+		Assignable bool
+	}{
+		{Variable{Type: TString, Value: "foo"}, TString, true},
+		{Variable{Type: TString, Value: "foo"}, TInt, false},
+		{Variable{Type: TInt, Value: 1}, Union(TInt, TFloat), true},
+		{Variable{Type: TList, Value: []Variable{}}, List(TInt), true},
+		{pair, TList, true},
+		{pair, Tuple(TString, TInt), true},
+		{pair, Tuple(TString, TString), false},
+		{pair, Tuple(TString), false},
+		{pair, List(TString), false},
+		{pair, List(TAny), true},
+		{pair, TMap, false},
+		{config, TMap, true},
+		{config, Object(map[string]Type{"name": TString, "tags": List(TString)}), true},
+		{config, Object(map[string]Type{"name": TString, "tags": List(TInt)}), false},
+		{config, Object(map[string]Type{"name": TString}), false},
+		{config, Map(TString), false},
+		{config, Map(TAny), true},
+		{config, TList, false},
+	}
+
+	for _, tc := range cases {
+		if actual := tc.Input.AssignableTo(tc.Type); actual != tc.Assignable {
+			t.Fatalf("Input: %s\n\nType: %s\n\nbad: %t", tc.Input, tc.Type, actual)
+		}
+	}
+}
+
+func TestVariableAssignableTo_noTypes(t *testing.T) {
+	list := make([]Variable, 100)
+	for i := range list {
+		list[i] = Variable{Type: TMap, Value: map[string]Variable{
+			fmt.Sprintf("key%d", i): {Type: TInt, Value: i},
+		}}
+	}
+	v := Variable{Type: TList, Value: list}
+
+	compositeTypes.RLock()
+	count := len(compositeTypes.list)
+	compositeTypes.RUnlock()
+
+	if !v.AssignableTo(List(Map(TInt))) {
+		t.Fatal("should be assignable")
+	}
+
+	compositeTypes.RLock()
+	defer compositeTypes.RUnlock()
+	if len(compositeTypes.list) > count+2 {
+		t.Fatalf("types were created: %d", len(compositeTypes.list)-count)
+	}
+}
+
+func TestUnionType(t *testing.T) {
+	if Union(TInt, TFloat) != Union(TFloat, TInt) {
+		t.Fatal("unions should not depend on order")
+	}
+	if Union(TInt, Union(TFloat, TInt)) != Union(TFloat, TInt) {
+		t.Fatal("nested unions should be flattened")
+	}
+	if Union(TInt, TInt) != TInt {
+		t.Fatal("union of a single type should be that type")
+	}
+	if Union(TInt, TAny) != TAny {
+		t.Fatal("union with any should be any")
+	}
+	if u := Union(TInt, TFloat); !u.IsUnion() || u.Kind() != TAny || u.String() != "union(float, int)" {
+		t.Fatalf("bad union: %s", u)
+	}
+}
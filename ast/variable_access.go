@@ -8,6 +8,7 @@ import (
 type VariableAccess struct {
 	Name string
 	Posx Pos
+	Endx Pos
 }
 
 func (n *VariableAccess) Accept(v Visitor) Node {
@@ -18,6 +19,10 @@ func (n *VariableAccess) Pos() Pos {
 	return n.Posx
 }
 
+func (n *VariableAccess) End() Pos {
+	return n.Endx
+}
+
 func (n *VariableAccess) GoString() string {
 	return fmt.Sprintf("*%#v", *n)
 }
@@ -9,28 +9,83 @@ import (
 
 // NOTE: All builtins are tested in engine_test.go
 
-func registerBuiltins(scope *ast.BasicScope) *ast.BasicScope {
-	if scope == nil {
-		scope = new(ast.BasicScope)
+// registerBuiltins returns a scope that looks up everything in the given
+// scope first and falls back to the builtin functions. The given scope is
+// not modified, so it can safely be shared between concurrent evaluations.
+//
+// Builtins can be replaced by adding them to overrides, or removed by
+// listing their name in disabled.
+func registerBuiltins(
+	scope ast.Scope,
+	overrides map[string]ast.Function,
+	disabled []string) ast.Scope {
+	funcs := builtinFuncs()
+	for k, v := range overrides {
+		funcs[k] = v
 	}
-	if scope.FuncMap == nil {
-		scope.FuncMap = make(map[string]ast.Function)
+	for _, k := range disabled {
+		delete(funcs, k)
 	}
 
-	// Implicit conversions
-	scope.FuncMap["__builtin_BoolToString"] = builtinBoolToString()
-	scope.FuncMap["__builtin_FloatToInt"] = builtinFloatToInt()
-	scope.FuncMap["__builtin_FloatToString"] = builtinFloatToString()
-	scope.FuncMap["__builtin_IntToFloat"] = builtinIntToFloat()
-	scope.FuncMap["__builtin_IntToString"] = builtinIntToString()
-	scope.FuncMap["__builtin_StringToInt"] = builtinStringToInt()
-	scope.FuncMap["__builtin_StringToFloat"] = builtinStringToFloat()
-	scope.FuncMap["__builtin_StringToBool"] = builtinStringToBool()
+	return &builtinScope{Scope: scope, builtins: funcs}
+}
+
+// builtinScope is the scope used for evaluation. It layers the user scope
+// on top of the builtin functions.
+type builtinScope struct {
+	ast.Scope
+	builtins map[string]ast.Function
+}
+
+func (s *builtinScope) LookupFunc(n string) (ast.Function, bool) {
+	if s.Scope != nil {
+		if f, ok := s.Scope.LookupFunc(n); ok {
+			return f, true
+		}
+	}
+
+	f, ok := s.builtins[n]
+	return f, ok
+}
+
+func (s *builtinScope) LookupVar(n string) (ast.Variable, bool) {
+	if s.Scope == nil {
+		return ast.Variable{}, false
+	}
+
+	return s.Scope.LookupVar(n)
+}
 
-	// Math operations
-	scope.FuncMap["__builtin_IntMath"] = builtinIntMath()
-	scope.FuncMap["__builtin_FloatMath"] = builtinFloatMath()
-	return scope
+func (s *builtinScope) HasVar(n string) bool {
+	return ast.HasVar(s.Scope, n)
+}
+
+func (s *builtinScope) VarNames() []string {
+	return ast.VarNames(s.Scope)
+}
+
+// FuncNames only returns the functions of the user scope since the
+// builtins aren't meant to be called directly.
+func (s *builtinScope) FuncNames() []string {
+	return ast.FuncNames(s.Scope)
+}
+
+func builtinFuncs() map[string]ast.Function {
+	return map[string]ast.Function{
+		// Implicit conversions
+		"__builtin_BoolToString":  builtinBoolToString(),
+		"__builtin_FloatToInt":    builtinFloatToInt(),
+		"__builtin_FloatToString": builtinFloatToString(),
+		"__builtin_IntToFloat":    builtinIntToFloat(),
+		"__builtin_IntToString":   builtinIntToString(),
+		"__builtin_StringToInt":   builtinStringToInt(),
+		"__builtin_StringToFloat": builtinStringToFloat(),
+		"__builtin_StringToBool":  builtinStringToBool(),
+
+		// Math operations
+		"__builtin_IntMath":   builtinIntMath(),
+		"__builtin_FloatMath": builtinFloatMath(),
+	}
 }
 
 func builtinFloatMath() ast.Function {
@@ -152,8 +207,8 @@ func builtinIntToString() ast.Function {
 
 func builtinStringToInt() ast.Function {
 	return ast.Function{
-		ArgTypes:   []ast.Type{ast.TInt},
-		ReturnType: ast.TString,
+		ArgTypes:   []ast.Type{ast.TString},
+		ReturnType: ast.TInt,
 		Callback: func(args []interface{}) (interface{}, error) {
 			v, err := strconv.ParseInt(args[0].(string), 0, 0)
 			if err != nil {
@@ -9,27 +9,35 @@ import (
 
 // IdentifierCheck is a SemanticCheck that checks that all identifiers
 // resolve properly and that the right number of arguments are passed
-// to functions.
+// to functions. All errors in the tree are reported, as a *MultiError if
+// there is more than one.
 type IdentifierCheck struct {
 	Scope ast.Scope
 
-	err  error
+	errs []error
+
+	// failed are the nodes with errors
+	failed map[ast.Node]bool
+
 	lock sync.Mutex
 }
 
 func (c *IdentifierCheck) Visit(root ast.Node) error {
+	_, err := c.check(root)
+	return err
+}
+
+// check is like Visit but also returns the nodes that failed the check.
+func (c *IdentifierCheck) check(root ast.Node) (map[ast.Node]bool, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	defer c.reset()
+	c.failed = make(map[ast.Node]bool)
 	root.Accept(c.visit)
-	return c.err
+	return c.failed, errorList(c.errs)
 }
 
 func (c *IdentifierCheck) visit(raw ast.Node) ast.Node {
-	if c.err != nil {
-		return raw
-	}
-
 	switch n := raw.(type) {
 	case *ast.Call:
 		c.visitCall(n)
@@ -51,7 +59,44 @@ func (c *IdentifierCheck) visitCall(n *ast.Call) {
 	// Look up the function in the map
 	function, ok := c.Scope.LookupFunc(n.Func)
 	if !ok {
-		c.createErr(n, fmt.Sprintf("unknown function called: %s", n.Func))
+		c.errs = append(c.errs, unknownFunction(c.Scope, n, n.Func))
+		c.failed[n] = true
+		return
+	}
+
+	// Overload sets need at least one overload taking that many arguments
+	if len(function.Overloads) > 0 {
+		if len(n.NamedArgs) > 0 {
+			c.createErr(n, fmt.Sprintf(
+				"%s: overloaded functions can't be called with named arguments", n.Func))
+			return
+		}
+
+		for _, o := range function.Overloads {
+			if o.AcceptsCount(len(n.Args)) {
+				return
+			}
+		}
+
+		c.createErr(n, fmt.Sprintf(
+			"%s: no signature takes %d arguments", n.Func, len(n.Args)))
+		return
+	}
+
+	// Named and omitted optional arguments have to be bound to the
+	// parameters, which tells us about unknown, duplicate or missing ones.
+	if len(n.NamedArgs) > 0 || len(function.Defaults) > 0 {
+		if !function.Variadic && len(n.Args) > len(function.ArgTypes) {
+			c.createErr(n, fmt.Sprintf(
+				"%s: expected at most %d arguments, got %d",
+				n.Func, len(function.ArgTypes), len(n.Args)))
+			return
+		}
+
+		if _, err := n.Bind(function); err != nil {
+			c.createErr(n, err.Error())
+		}
+
 		return
 	}
 
@@ -71,18 +116,21 @@ func (c *IdentifierCheck) visitCall(n *ast.Call) {
 }
 
 func (c *IdentifierCheck) visitVariableAccess(n *ast.VariableAccess) {
-	// Look up the variable in the map
-	if _, ok := c.Scope.LookupVar(n.Name); !ok {
-		c.createErr(n, fmt.Sprintf(
-			"unknown variable accessed: %s", n.Name))
+	// Check that the variable exists. This avoids resolving its value if
+	// the scope supports it.
+	if !ast.HasVar(c.Scope, n.Name) {
+		c.errs = append(c.errs, unknownVariable(c.Scope, n, n.Name))
+		c.failed[n] = true
 		return
 	}
 }
 
 func (c *IdentifierCheck) createErr(n ast.Node, str string) {
-	c.err = fmt.Errorf("%s: %s", n.Pos(), str)
+	c.errs = append(c.errs, errorAt(n, "%s", str))
+	c.failed[n] = true
 }
 
 func (c *IdentifierCheck) reset() {
-	c.err = nil
+	c.errs = nil
+	c.failed = nil
 }
@@ -1,6 +1,8 @@
 package stop
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/patdhlk/stop/ast"
@@ -124,6 +126,55 @@ func TestIdentifierCheck(t *testing.T) {
 			},
 			false,
 		},
+
+		{
+			"foo #{max(1, 2)}",
+			&ast.BasicScope{
+				FuncMap: map[string]ast.Function{
+					"max": ast.Function{
+						Overloads: []ast.Function{
+							{ArgTypes: []ast.Type{ast.TInt}, ReturnType: ast.TInt},
+							{ArgTypes: []ast.Type{ast.TInt, ast.TInt}, ReturnType: ast.TInt},
+						},
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"foo #{max(1, 2, 3)}",
+			&ast.BasicScope{
+				FuncMap: map[string]ast.Function{
+					"max": ast.Function{
+						Overloads: []ast.Function{
+							{ArgTypes: []ast.Type{ast.TInt}, ReturnType: ast.TInt},
+							{ArgTypes: []ast.Type{ast.TInt, ast.TInt}, ReturnType: ast.TInt},
+						},
+					},
+				},
+			},
+			true,
+		},
+
+		{
+			"foo #{bar[var.key]}",
+			&ast.BasicScope{
+				VarMap: map[string]ast.Variable{
+					"bar": ast.Variable{
+						Value: []ast.Variable{},
+						Type:  ast.TList,
+					},
+				},
+			},
+			true,
+		},
+
+		{
+			"foo #{bar[0]}",
+			&ast.BasicScope{},
+			true,
+		},
 	}
 
 	for _, tc := range cases {
@@ -139,3 +190,87 @@ func TestIdentifierCheck(t *testing.T) {
 		}
 	}
 }
+
+func TestIdentifierCheck_exister(t *testing.T) {
+	node, err := Parse("#{secret.db} #{secret.nope}")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	resolved := false
+	scope := &ast.ResolverScope{
+		Resolvers: map[string]ast.Resolver{
+			"secret.": &testSecretResolver{
+				Resolved: &resolved,
+				Names:    map[string]bool{"db": true},
+			},
+		},
+	}
+
+	visitor := &IdentifierCheck{Scope: scope}
+	err = visitor.Visit(node)
+	if err == nil || !strings.Contains(err.Error(), "secret.nope") {
+		t.Fatalf("bad err: %v", err)
+	}
+	if resolved {
+		t.Fatal("identifier check should not resolve values")
+	}
+}
+
+type testSecretResolver struct {
+	Resolved *bool
+	Names    map[string]bool
+}
+
+func (r *testSecretResolver) Resolve(n string) (ast.Variable, bool) {
+	*r.Resolved = true
+	return ast.Variable{Type: ast.TString, Value: "hunter2"}, r.Names[n]
+}
+
+func (r *testSecretResolver) Exists(n string) bool {
+	return r.Names[n]
+}
+
+func TestIdentifierCheck_multipleErrors(t *testing.T) {
+	scope := &ast.BasicScope{
+		FuncMap: map[string]ast.Function{
+			"upper": ast.Function{
+				ArgTypes:   []ast.Type{ast.TString},
+				ReturnType: ast.TString,
+			},
+		},
+	}
+
+	node, err := Parse(`#{var.foo} #{upper()} #{nope(var.bar)}`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	visitor := &IdentifierCheck{Scope: scope}
+	err = visitor.Visit(node)
+
+	var merr *MultiError
+	if !errors.As(err, &merr) {
+		t.Fatalf("err: %#v", err)
+	}
+
+	expected := []string{
+		"1:3: unknown variable accessed: var.foo",
+		"1:14: upper: expected 1 arguments, got 0",
+		"1:30: unknown variable accessed: var.bar",
+		"1:25: unknown function called: nope",
+	}
+	if len(merr.Errors) != len(expected) {
+		t.Fatalf("bad: %s", err)
+	}
+	for i, err := range merr.Errors {
+		if err.Error() != expected[i] {
+			t.Fatalf("bad: %s", err)
+		}
+	}
+
+	var uerr *UnknownFunctionError
+	if !errors.As(err, &uerr) || uerr.Name != "nope" {
+		t.Fatalf("bad: %#v", uerr)
+	}
+}
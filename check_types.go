@@ -2,6 +2,7 @@ package stop
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/patdhlk/stop/ast"
@@ -15,20 +16,34 @@ import (
 // field. Note that this is kind of organizationally weird to put into
 // this structure but we'd rather do that than duplicate the type checking
 // logic multiple times.
+//
+// All type errors in the tree are reported, as a *MultiError if there is
+// more than one. Expressions depending on a node with an error aren't
+// checked to avoid reporting consequential errors.
 type TypeCheck struct {
 	Scope ast.Scope
 
 	// Implicit is a map of implicit type conversions that we can do,
 	// and that shouldn't error. The key of the first map is the from type,
 	// the key of the second map is the to type, and the final string
-	// value is the function to call (which must be registered in the Scope).
+	// value is the function to call. Conversions whose function isn't
+	// registered in the Scope are not done.
 	Implicit map[ast.Type]map[ast.Type]string
 
 	// Stack of types. This shouldn't be used directly except by implementations
 	// of TypeCheckNode.
 	Stack []ast.Type
 
-	err  error
+	errs []error
+
+	// abort stops the check after an error in a node whose effect on the
+	// stack is unknown, see typeCheckInputs.
+	abort bool
+
+	// failed are nodes that failed an earlier check. They aren't checked
+	// again, just like the nodes depending on them.
+	failed map[ast.Node]bool
+
 	lock sync.Mutex
 }
 
@@ -44,14 +59,25 @@ func (v *TypeCheck) Visit(root ast.Node) error {
 	defer v.lock.Unlock()
 	defer v.reset()
 	root.Accept(v.visit)
-	return v.err
+	return errorList(v.errs)
 }
 
 func (v *TypeCheck) visit(raw ast.Node) ast.Node {
-	if v.err != nil {
+	if v.abort {
 		return raw
 	}
 
+	// If one of the inputs of this node failed to check, so does this
+	// node. That error has been reported already though, as have those of
+	// nodes that failed an earlier check.
+	inputs := typeCheckInputs(raw)
+	if inputs >= 0 && (v.failed[raw] || v.stackHasError(inputs)) {
+		v.Stack = v.Stack[:len(v.Stack)-inputs]
+		v.StackPush(typeError)
+		return raw
+	}
+	before := len(v.Stack)
+
 	var result ast.Node
 	var err error
 	switch n := raw.(type) {
@@ -84,14 +110,60 @@ func (v *TypeCheck) visit(raw ast.Node) ast.Node {
 	}
 
 	if err != nil {
-		pos := raw.Pos()
-		v.err = fmt.Errorf("At column %d, line %d: %s",
-			pos.Column, pos.Line, err)
+		if _, ok := err.(positionedError); !ok {
+			err = &posError{Pos: raw.Pos(), End: ast.End(raw), Err: err}
+		}
+		v.errs = append(v.errs, err)
+
+		// Replace whatever the node took from the stack with an error so
+		// that the check can go on with the next node.
+		if inputs < 0 {
+			v.abort = true
+			return raw
+		}
+		v.Stack = v.Stack[:before-inputs]
+		v.StackPush(typeError)
+		return raw
 	}
 
 	return result
 }
 
+// typeError is pushed to the stack in place of the type of a node that
+// failed to check.
+const typeError = ast.TUnsupported
+
+// typeCheckInputs returns the number of types the given node takes from
+// the stack, or -1 if it isn't known.
+func typeCheckInputs(raw ast.Node) int {
+	switch n := raw.(type) {
+	case *ast.Arithmetic:
+		return len(n.Exprs)
+	case *ast.Call:
+		return len(n.Args) + len(n.NamedArgs)
+	case *ast.Output:
+		return len(n.Exprs)
+	case *ast.Index:
+		return 2
+	case *ast.LiteralNode, *ast.VariableAccess:
+		return 0
+	default:
+		return -1
+	}
+}
+
+// stackHasError reports whether one of the top n types on the stack is
+// typeError.
+func (v *TypeCheck) stackHasError(n int) bool {
+	for _, t := range v.Stack[len(v.Stack)-n:] {
+		if t == typeError {
+			return true
+		}
+	}
+
+	return false
+}
+
 type typeCheckArithmetic struct {
 	n *ast.Arithmetic
 }
@@ -137,9 +209,13 @@ func (tc *typeCheckArithmetic) TypeCheck(v *TypeCheck) (ast.Node, error) {
 				continue
 			}
 
-			return nil, fmt.Errorf(
-				"operand %d should be %s, got %s",
-				i+1, mathType, arg)
+			return nil, &TypeMismatchError{
+				Pos:      tc.n.Exprs[i].Pos(),
+				End:      ast.End(tc.n.Exprs[i]),
+				What:     fmt.Sprintf("operand %d", i+1),
+				Expected: mathType,
+				Actual:   arg,
+			}
 		}
 	}
 
@@ -158,12 +234,14 @@ func (tc *typeCheckArithmetic) TypeCheck(v *TypeCheck) (ast.Node, error) {
 		Value: tc.n.Op,
 		Typex: ast.TInt,
 		Posx:  tc.n.Pos(),
+		Endx:  tc.n.End(),
 	}
 	copy(args[1:], tc.n.Exprs)
 	return &ast.Call{
 		Func: mathFunc,
 		Args: args,
 		Posx: tc.n.Pos(),
+		Endx: tc.n.End(),
 	}, nil
 }
 
@@ -175,61 +253,180 @@ func (tc *typeCheckCall) TypeCheck(v *TypeCheck) (ast.Node, error) {
 	// Look up the function in the map
 	function, ok := v.Scope.LookupFunc(tc.n.Func)
 	if !ok {
-		return nil, fmt.Errorf("unknown function called: %s", tc.n.Func)
+		return nil, unknownFunction(v.Scope, tc.n, tc.n.Func)
 	}
 
 	// The arguments are on the stack in reverse order, so pop them off.
-	args := make([]ast.Type, len(tc.n.Args))
-	for i, _ := range tc.n.Args {
-		args[len(tc.n.Args)-1-i] = v.StackPop()
+	// Named arguments were visited after the positional ones.
+	count := len(tc.n.Args) + len(tc.n.NamedArgs)
+	args := make([]ast.Type, count)
+	for i := 0; i < count; i++ {
+		args[count-1-i] = v.StackPop()
 	}
 
-	// Verify the args
-	for i, expected := range function.ArgTypes {
-		if expected == ast.TAny {
-			continue
+	// Overloads are resolved to the first one that takes the arguments
+	// as they are. Otherwise the only one that takes them after widening
+	// ints to floats is used, since any other conversion may lose
+	// information and would make the choice depend on the order of the
+	// overloads.
+	if len(function.Overloads) > 0 {
+		if len(tc.n.NamedArgs) > 0 {
+			return nil, fmt.Errorf(
+				"%s: overloaded functions can't be called with named arguments",
+				tc.n.Func)
 		}
 
-		if args[i] != expected {
-			cn := v.ImplicitConversion(args[i], expected, tc.n.Args[i])
-			if cn != nil {
-				tc.n.Args[i] = cn
-				continue
+		names := make([]string, len(args))
+		for i, t := range args {
+			names[i] = t.Printable()
+		}
+
+		sig, ok := function.Resolve(args)
+		if !ok {
+			var matches []ast.Function
+			for _, o := range function.Overloads {
+				if !o.AcceptsCount(len(args)) || !widens(o, args) {
+					continue
+				}
+
+				if _, _, err := tc.checkArgs(v, o, tc.n.Args, args); err == nil {
+					matches = append(matches, o)
+				}
+			}
+
+			if len(matches) > 1 {
+				sigs := make([]string, len(matches))
+				for i, o := range matches {
+					sigs[i] = o.Signature()
+				}
+
+				return nil, fmt.Errorf(
+					"%s: call with arguments (%s) is ambiguous, candidates are: %s",
+					tc.n.Func, strings.Join(names, ", "), strings.Join(sigs, ", "))
+			}
+			if len(matches) == 1 {
+				sig, ok = matches[0], true
+			}
+		}
+		if !ok {
+			sigs := make([]string, len(function.Overloads))
+			for i, o := range function.Overloads {
+				sigs[i] = o.Signature()
 			}
 
 			return nil, fmt.Errorf(
-				"%s: argument %d should be %s, got %s",
-				tc.n.Func, i+1, expected.Printable(), args[i].Printable())
+				"%s: no signature matches arguments (%s), candidates are: %s",
+				tc.n.Func, strings.Join(names, ", "), strings.Join(sigs, ", "))
 		}
+
+		function = sig
 	}
 
-	// If we're variadic, then verify the types there
-	if function.Variadic && function.VariadicType != ast.TAny {
-		args = args[len(function.ArgTypes):]
-		for i, t := range args {
-			if t != function.VariadicType {
-				realI := i + len(function.ArgTypes)
-				cn := v.ImplicitConversion(
-					t, function.VariadicType, tc.n.Args[realI])
-				if cn != nil {
-					tc.n.Args[realI] = cn
-					continue
-				}
+	// Bind named arguments and defaults to their parameters so that
+	// evaluation only has to deal with positional arguments.
+	nodes, err := tc.n.Bind(function)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) != len(tc.n.Args) || len(tc.n.NamedArgs) > 0 {
+		types := make(map[ast.Node]ast.Type, count)
+		for i, a := range tc.n.Args {
+			types[a] = args[i]
+		}
+		for i, a := range tc.n.NamedArgs {
+			types[a.Value] = args[len(tc.n.Args)+i]
+		}
 
-				return nil, fmt.Errorf(
-					"%s: argument %d should be %s, got %s",
-					tc.n.Func, realI,
-					function.VariadicType.Printable(), t.Printable())
+		args = make([]ast.Type, len(nodes))
+		for i, a := range nodes {
+			t, ok := types[a]
+			if !ok {
+				// A default
+				t = a.(*ast.LiteralNode).Typex
 			}
+
+			args[i] = t
 		}
 	}
 
+	// Verify the args
+	converted, args, err := tc.checkArgs(v, function, nodes, args)
+	if err != nil {
+		return nil, err
+	}
+	for i, cn := range converted {
+		if cn != nil {
+			nodes[i] = cn
+		}
+	}
+	tc.n.Args = nodes
+	tc.n.NamedArgs = nil
+
 	// Return type
-	v.StackPush(function.ReturnType)
+	ret, err := function.ResultType(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", tc.n.Func, err)
+	}
+	v.StackPush(ret)
 
 	return tc.n, nil
 }
 
+// widens reports whether the function takes arguments of the given types
+// as they are or after converting ints to floats, which doesn't lose
+// information.
+func widens(function ast.Function, args []ast.Type) bool {
+	for i, t := range args {
+		expected := function.ArgTypeAt(i)
+		if t.AssignableTo(expected) {
+			continue
+		}
+		if t == ast.TInt && ast.TFloat.AssignableTo(expected) {
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// checkArgs verifies that the function can be called with arguments of the
+// given types, with nodes being the arguments in the order of the
+// parameters. Arguments that need an implicit conversion get the
+// conversion node at their index in the first result. The second result
+// are the argument types after the conversions. The call itself isn't
+// modified so that a failed overload doesn't affect the next one.
+func (tc *typeCheckCall) checkArgs(
+	v *TypeCheck, function ast.Function, nodes []ast.Node, args []ast.Type) ([]ast.Node, []ast.Type, error) {
+	converted := make([]ast.Node, len(args))
+	types := make([]ast.Type, len(args))
+	for i, t := range args {
+		types[i] = t
+
+		expected := function.ArgTypeAt(i)
+		if t.AssignableTo(expected) {
+			continue
+		}
+
+		cn := v.ImplicitConversion(t, expected, nodes[i])
+		if cn == nil {
+			return nil, nil, &TypeMismatchError{
+				Pos:      nodes[i].Pos(),
+				End:      ast.End(nodes[i]),
+				What:     fmt.Sprintf("%s: argument %d", tc.n.Func, i+1),
+				Expected: expected,
+				Actual:   t,
+			}
+		}
+
+		converted[i] = cn
+		types[i] = expected
+	}
+
+	return converted, types, nil
+}
+
 type typeCheckOutput struct {
 	n *ast.Output
 }
@@ -242,14 +439,14 @@ func (tc *typeCheckOutput) TypeCheck(v *TypeCheck) (ast.Node, error) {
 	}
 
 	// If there is only one argument and it is a list, we evaluate to a list
-	if len(types) == 1 && types[0] == ast.TList {
-		v.StackPush(ast.TList)
+	if len(types) == 1 && types[0].Kind() == ast.TList {
+		v.StackPush(types[0])
 		return n, nil
 	}
 
 	// If there is only one argument and it is a map, we evaluate to a map
-	if len(types) == 1 && types[0] == ast.TMap {
-		v.StackPush(ast.TMap)
+	if len(types) == 1 && types[0].Kind() == ast.TMap {
+		v.StackPush(types[0])
 		return n, nil
 	}
 
@@ -262,8 +459,15 @@ func (tc *typeCheckOutput) TypeCheck(v *TypeCheck) (ast.Node, error) {
 				continue
 			}
 
-			return nil, fmt.Errorf(
-				"output of an STOP expression must be a string, or a single list (argument %d is %s)", i+1, t)
+			// A single list or map would have been fine as well, but
+			// that's handled above.
+			return nil, &TypeMismatchError{
+				Pos:      n.Exprs[i].Pos(),
+				End:      ast.End(n.Exprs[i]),
+				What:     fmt.Sprintf("expression %d of the output", i+1),
+				Expected: ast.TString,
+				Actual:   t,
+			}
 		}
 	}
 
@@ -290,8 +494,7 @@ func (tc *typeCheckVariableAccess) TypeCheck(v *TypeCheck) (ast.Node, error) {
 	// Look up the variable in the map
 	variable, ok := v.Scope.LookupVar(tc.n.Name)
 	if !ok {
-		return nil, fmt.Errorf(
-			"unknown variable accessed: %s", tc.n.Name)
+		return nil, unknownVariable(v.Scope, tc.n, tc.n.Name)
 	}
 
 	// Add the type to the stack
@@ -311,38 +514,61 @@ func (tc *typeCheckIndex) TypeCheck(v *TypeCheck) (ast.Node, error) {
 		return nil, fmt.Errorf("target of an index must be a VariableAccess node, was %T", tc.n.Target)
 	}
 
-	// Get the variable
+	// The target and key are on the stack in reverse order
+	keyType := v.StackPop()
+	v.StackPop()
+
+	// The value of the variable is needed for untyped lists and maps
 	variable, ok := v.Scope.LookupVar(varAccessNode.Name)
 	if !ok {
-		return nil, fmt.Errorf("unknown variable accessed: %s", varAccessNode.Name)
-	}
-
-	keyType, err := tc.n.Key.Type(v.Scope)
-	if err != nil {
-		return nil, err
+		return nil, unknownVariable(v.Scope, varAccessNode, varAccessNode.Name)
 	}
 
-	switch variable.Type {
+	switch variable.Type.Kind() {
 	case ast.TList:
 		if keyType != ast.TInt {
-			return nil, fmt.Errorf("key of an index must be an int, was %s", keyType)
+			return nil, &TypeMismatchError{
+				Pos:      tc.n.Key.Pos(),
+				End:      ast.End(tc.n.Key),
+				What:     fmt.Sprintf("key for indexing %s", varAccessNode.Name),
+				Expected: ast.TInt,
+				Actual:   keyType,
+			}
 		}
 
-		valType, err := ast.VariableListElementTypesAreHomogenous(varAccessNode.Name, variable.Value.([]ast.Variable))
+		valType, ok, err := tc.n.DeclaredType(variable.Type)
 		if err != nil {
-			return tc.n, err
+			return tc.n, declaredTypeError(err, tc.n, varAccessNode.Name, variable.Type)
+		}
+		if !ok {
+			valType, err = ast.VariableListElementTypesAreHomogenous(varAccessNode.Name, variable.Value.([]ast.Variable))
+			if err != nil {
+				return tc.n, err
+			}
 		}
 
 		v.StackPush(valType)
 		return tc.n, nil
 	case ast.TMap:
 		if keyType != ast.TString {
-			return nil, fmt.Errorf("key of an index must be a string, was %s", keyType)
+			return nil, &TypeMismatchError{
+				Pos:      tc.n.Key.Pos(),
+				End:      ast.End(tc.n.Key),
+				What:     fmt.Sprintf("key for indexing %s", varAccessNode.Name),
+				Expected: ast.TString,
+				Actual:   keyType,
+			}
 		}
 
-		valType, err := ast.VariableMapValueTypesAreHomogenous(varAccessNode.Name, variable.Value.(map[string]ast.Variable))
+		valType, ok, err := tc.n.DeclaredType(variable.Type)
 		if err != nil {
-			return tc.n, err
+			return tc.n, declaredTypeError(err, tc.n, varAccessNode.Name, variable.Type)
+		}
+		if !ok {
+			valType, err = ast.VariableMapValueTypesAreHomogenous(varAccessNode.Name, variable.Value.(map[string]ast.Variable))
+			if err != nil {
+				return tc.n, err
+			}
 		}
 
 		v.StackPush(valType)
@@ -368,16 +594,24 @@ func (v *TypeCheck) ImplicitConversion(
 		return nil
 	}
 
+	// The conversion function may have been removed from the scope
+	if _, ok := v.Scope.LookupFunc(toFunc); !ok {
+		return nil
+	}
+
 	return &ast.Call{
 		Func: toFunc,
 		Args: []ast.Node{n},
 		Posx: n.Pos(),
+		Endx: ast.End(n),
 	}
 }
 
 func (v *TypeCheck) reset() {
 	v.Stack = nil
-	v.err = nil
+	v.errs = nil
+	v.abort = false
+	v.failed = nil
 }
 
 func (v *TypeCheck) StackPush(t ast.Type) {
@@ -389,3 +623,27 @@ func (v *TypeCheck) StackPop() ast.Type {
 	x, v.Stack = v.Stack[len(v.Stack)-1], v.Stack[:len(v.Stack)-1]
 	return x
 }
+
+// declaredTypeError turns the ast.KeyError returned by DeclaredType for a
+// key that isn't in an object or tuple type into the IndexOutOfRangeError
+// that evaluating the index n into the variable with the given name and
+// type would fail with.
+func declaredTypeError(err error, n *ast.Index, name string, t ast.Type) error {
+	keyErr, ok := err.(*ast.KeyError)
+	if !ok {
+		return err
+	}
+
+	length := len(t.TupleTypes())
+	if t.IsObject() {
+		length = len(t.AttrTypes())
+	}
+
+	return &IndexOutOfRangeError{
+		Pos:  n.Pos(),
+		End:  ast.End(n),
+		Name: name,
+		Key:  keyErr.Key,
+		Len:  length,
+	}
+}
@@ -1,6 +1,8 @@
 package stop
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/patdhlk/stop/ast"
@@ -308,7 +310,7 @@ func TestTypeCheck_implicit(t *testing.T) {
 		},
 
 		{
-			`#{foo[bar[var.keyint]]}`,
+			`#{foo[bar[var.key]]}`,
 			&ast.BasicScope{
 				VarMap: map[string]ast.Variable{
 					"foo": ast.Variable{
@@ -381,3 +383,369 @@ func TestTypeCheck_implicit(t *testing.T) {
 		}
 	}
 }
+
+func TestTypeCheck_collectionTypes(t *testing.T) {
+	scope := &ast.BasicScope{
+		VarMap: map[string]ast.Variable{
+			"var.empty": ast.Variable{
+				Type:  ast.List(ast.TString),
+				Value: []ast.Variable{},
+			},
+			"var.ints": ast.Variable{
+				Type: ast.List(ast.TInt),
+				Value: []ast.Variable{
+					{Type: ast.TInt, Value: 1},
+				},
+			},
+			"var.lists": ast.Variable{
+				Type:  ast.Map(ast.List(ast.TInt)),
+				Value: map[string]ast.Variable{},
+			},
+			"var.untyped": ast.Variable{
+				Type: ast.TList,
+				Value: []ast.Variable{
+					{Type: ast.TString, Value: "foo"},
+				},
+			},
+			"var.server": ast.Variable{
+				Type: ast.Object(map[string]ast.Type{
+					"name": ast.TString,
+					"port": ast.TInt,
+				}),
+				Value: map[string]ast.Variable{
+					"name": {Type: ast.TString, Value: "web"},
+					"port": {Type: ast.TInt, Value: 80},
+				},
+			},
+			"var.pair": ast.Variable{
+				Type: ast.Tuple(ast.TString, ast.TInt),
+				Value: []ast.Variable{
+					{Type: ast.TString, Value: "web"},
+					{Type: ast.TInt, Value: 80},
+				},
+			},
+		},
+		FuncMap: map[string]ast.Function{
+			"join": ast.Function{
+				ArgTypes:   []ast.Type{ast.List(ast.TString)},
+				ReturnType: ast.TString,
+			},
+			"sum": ast.Function{
+				ArgTypes:   []ast.Type{ast.List(ast.TInt)},
+				ReturnType: ast.TInt,
+			},
+			"itoa": ast.Function{
+				ArgTypes:   []ast.Type{ast.TInt},
+				ReturnType: ast.TString,
+			},
+		},
+	}
+
+	cases := []struct {
+		Input string
+		Error string
+	}{
+		{`#{var.empty[0]}`, ""},
+		{`#{var.empty}`, ""},
+		{`#{join(var.empty)}`, ""},
+		{`#{join(var.untyped)}`, ""},
+		{`#{join(var.ints)}`, "argument 1 should be type list(string), got type list(int)"},
+		{`#{var.lists["foo"]}`, ""},
+		{`foo #{var.lists["foo"]}`, "list(int)"},
+		{`#{var.server["name"]}`, ""},
+		{`#{itoa(sum(var.ints) + var.server["port"])}`, ""},
+		{`#{join(var.server["port"])}`, "got type int"},
+		{`#{var.server["host"]}`, `1:3: key "host" does not exist in map var.server`},
+		{`#{var.server[var.server["name"]]}`, "literal key"},
+		{`#{itoa(var.pair[1] + 1)}`, ""},
+		{`#{var.pair[2]}`, "1:3: index 2 out of range for list var.pair (length 2)"},
+		{`#{var.pair["foo"]}`, "key for indexing var.pair should be type int, got type string"},
+	}
+
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+
+		visitor := &TypeCheck{Scope: scope}
+		err = visitor.Visit(node)
+		if (err != nil) != (tc.Error != "") {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+		if err != nil && !strings.Contains(err.Error(), tc.Error) {
+			t.Fatalf("Bad error: %s\n\nInput: %s", err, tc.Input)
+		}
+	}
+}
+
+func TestTypeCheck_overloads(t *testing.T) {
+	num := ast.Union(ast.TInt, ast.TFloat)
+	scope := &ast.BasicScope{
+		FuncMap: map[string]ast.Function{
+			"max": ast.Function{
+				Overloads: []ast.Function{
+					{ArgTypes: []ast.Type{ast.TInt, ast.TInt}, ReturnType: ast.TInt},
+					{ArgTypes: []ast.Type{ast.TFloat, ast.TFloat}, ReturnType: ast.TFloat},
+				},
+			},
+			"abs": ast.Function{
+				ArgTypes: []ast.Type{num},
+				ReturnTypeFunc: func(args []ast.Type) (ast.Type, error) {
+					return args[0], nil
+				},
+			},
+			"half": ast.Function{
+				ArgTypes:   []ast.Type{num},
+				ReturnType: ast.TFloat,
+			},
+			"str": ast.Function{
+				ArgTypes:   []ast.Type{ast.TString},
+				ReturnType: ast.TString,
+			},
+			"intstr": ast.Function{
+				ArgTypes:   []ast.Type{ast.TInt},
+				ReturnType: ast.TString,
+			},
+			"floatstr": ast.Function{
+				ArgTypes:   []ast.Type{ast.TFloat},
+				ReturnType: ast.TString,
+			},
+		},
+	}
+
+	cases := []struct {
+		Input string
+		Error string
+	}{
+		{`#{intstr(max(1, 2))}`, ""},
+		{`#{floatstr(max(1.0, 2.0))}`, ""},
+		{`#{intstr(max(1.0, 2.0))}`, "argument 1 should be type int, got type float"},
+		{`#{max("a", "b")}`, "no signature matches arguments (type string, type string)"},
+		{`#{max("a", "b")}`, "candidates are: (int, int) int, (float, float) float"},
+		{`#{intstr(abs(1))}`, ""},
+		{`#{floatstr(abs(1.5))}`, ""},
+		{`#{abs("a")}`, "argument 1 should be type union(float, int), got type string"},
+		{`#{floatstr(half(3))}`, ""},
+		{`#{str(half(3))}`, "got type float"},
+	}
+
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+
+		visitor := &TypeCheck{Scope: scope}
+		err = visitor.Visit(node)
+		if (err != nil) != (tc.Error != "") {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+		if err != nil && !strings.Contains(err.Error(), tc.Error) {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+	}
+}
+
+func TestTypeCheck_overloadConversions(t *testing.T) {
+	convert := func(from, to ast.Type) ast.Function {
+		return ast.Function{ArgTypes: []ast.Type{from}, ReturnType: to}
+	}
+	scope := &ast.BasicScope{
+		FuncMap: map[string]ast.Function{
+			"max": ast.Function{
+				Overloads: []ast.Function{
+					{ArgTypes: []ast.Type{ast.TInt, ast.TInt}, ReturnType: ast.TInt},
+					{ArgTypes: []ast.Type{ast.TFloat, ast.TFloat}, ReturnType: ast.TFloat},
+				},
+			},
+			"pad": ast.Function{
+				Overloads: []ast.Function{
+					{ArgTypes: []ast.Type{ast.TFloat, ast.TInt}, ReturnType: ast.TString},
+					{ArgTypes: []ast.Type{ast.TInt, ast.TFloat}, ReturnType: ast.TString},
+				},
+			},
+			"floatstr":    convert(ast.TFloat, ast.TString),
+			"intToFloat":  convert(ast.TInt, ast.TFloat),
+			"floatToInt":  convert(ast.TFloat, ast.TInt),
+			"stringToInt": convert(ast.TString, ast.TInt),
+		},
+	}
+	implicitMap := map[ast.Type]map[ast.Type]string{
+		ast.TInt:    {ast.TFloat: "intToFloat"},
+		ast.TFloat:  {ast.TInt: "floatToInt"},
+		ast.TString: {ast.TInt: "stringToInt"},
+	}
+
+	cases := []struct {
+		Input string
+		Error string
+	}{
+		// Ints are widened to floats rather than floats narrowed to ints
+		{`#{floatstr(max(1, 2.5))}`, ""},
+		{`#{floatstr(max(2.5, 1))}`, ""},
+		{`#{max("1", "2")}`, "no signature matches arguments (type string, type string)"},
+		{`#{pad(1.5, 1)}`, ""},
+		{`#{pad(1, 1)}`, "call with arguments (type int, type int) is ambiguous"},
+	}
+
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+
+		visitor := &TypeCheck{Scope: scope, Implicit: implicitMap}
+		err = visitor.Visit(node)
+		if (err != nil) != (tc.Error != "") {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+		if err != nil && !strings.Contains(err.Error(), tc.Error) {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+	}
+}
+
+func TestTypeCheck_multipleErrors(t *testing.T) {
+	scope := &ast.BasicScope{
+		VarMap: map[string]ast.Variable{
+			"var.list": ast.Variable{
+				Type:  ast.List(ast.TString),
+				Value: []ast.Variable{},
+			},
+		},
+		FuncMap: map[string]ast.Function{
+			"upper": ast.Function{
+				ArgTypes:   []ast.Type{ast.TString},
+				ReturnType: ast.TString,
+			},
+		},
+	}
+
+	cases := []struct {
+		Input  string
+		Errors []string
+	}{
+		{
+			`#{upper(var.list)} #{upper(1)} #{var.nope}`,
+			[]string{
+				"1:9: upper: argument 1 should be type string, got type list(string)",
+				"1:28: upper: argument 1 should be type string, got type int",
+				"1:34: unknown variable accessed: var.nope",
+			},
+		},
+		{
+			// The error in the inner call isn't repeated for the outer one
+			`#{upper(upper(var.list))}`,
+			[]string{
+				"1:15: upper: argument 1 should be type string, got type list(string)",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+
+		visitor := &TypeCheck{Scope: scope}
+		err = visitor.Visit(node)
+
+		var errs []error
+		if merr, ok := err.(*MultiError); ok {
+			errs = merr.Errors
+		} else if err != nil {
+			errs = []error{err}
+		}
+
+		actual := make([]string, len(errs))
+		for i, err := range errs {
+			actual[i] = err.Error()
+		}
+		if !reflect.DeepEqual(actual, tc.Errors) {
+			t.Fatalf("Bad: %#v\n\nInput: %s", actual, tc.Input)
+		}
+	}
+}
+
+func TestTypeCheck_ranges(t *testing.T) {
+	scope := &ast.BasicScope{
+		VarMap: map[string]ast.Variable{
+			"bar": ast.Variable{Value: 42, Type: ast.TInt},
+		},
+		FuncMap: map[string]ast.Function{
+			"intToString": ast.Function{
+				ArgTypes:   []ast.Type{ast.TInt},
+				ReturnType: ast.TString,
+			},
+		},
+	}
+	implicitMap := map[ast.Type]map[ast.Type]string{
+		ast.TInt: {
+			ast.TString: "intToString",
+		},
+	}
+
+	node, err := Parse("foo #{bar + 1}")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	visitor := &TypeCheck{Scope: scope, Implicit: implicitMap}
+	if err := visitor.Visit(node); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The conversion, the call replacing the arithmetic and its operator
+	// all have the range of the arithmetic.
+	pos := ast.Pos{Column: 7, Line: 1, Offset: 6}
+	end := ast.Pos{Column: 14, Line: 1, Offset: 13}
+	conv := node.(*ast.Output).Exprs[1].(*ast.Call)
+	math := conv.Args[0].(*ast.Call)
+	for _, n := range []ast.Node{conv, math, math.Args[0]} {
+		if n.Pos() != pos || ast.End(n) != end {
+			t.Fatalf("bad range for %s: %#v-%#v", n, n.Pos(), ast.End(n))
+		}
+	}
+}
+
+func TestTypeCheck_json(t *testing.T) {
+	scope := &ast.BasicScope{
+		VarMap: map[string]ast.Variable{
+			"bar": ast.Variable{Value: 42, Type: ast.TInt},
+		},
+		FuncMap: map[string]ast.Function{
+			"intToString": ast.Function{
+				ArgTypes:   []ast.Type{ast.TInt},
+				ReturnType: ast.TString,
+			},
+		},
+	}
+	implicitMap := map[ast.Type]map[ast.Type]string{
+		ast.TInt: {
+			ast.TString: "intToString",
+		},
+	}
+
+	node, err := Parse("foo #{bar * 2 - 1}")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	visitor := &TypeCheck{Scope: scope, Implicit: implicitMap}
+	if err := visitor.Visit(node); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The operators of the replaced arithmetic are encoded as well
+	data, err := ast.EncodeJSON(node)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	decoded, err := ast.DecodeJSON(data)
+	if err != nil {
+		t.Fatalf("err: %s\n\n%s", err, data)
+	}
+	if !reflect.DeepEqual(decoded, node) {
+		t.Fatalf("Bad: %#v\n\nExpected: %#v\n\nJSON: %s", decoded, node, data)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 3
+
+// diffLine is a line of a diff: ' ' for lines in both texts, '-' for
+// lines only in the old and '+' for lines only in the new text.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// lineDiff returns a unified diff between the old and new text of the
+// file with the given name, or "" if they are the same.
+func lineDiff(name, old, new string) string {
+	if old == new {
+		return ""
+	}
+
+	lines := diffLines(splitLines(old), splitLines(new))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s.orig\n+++ %s\n", name, name)
+
+	// Group changes that are close to each other into hunks
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+
+		first := start - diffContext
+		if first < 0 {
+			first = 0
+		}
+
+		last, unchanged := start, 0
+		for i := start; i < len(lines) && unchanged <= 2*diffContext; i++ {
+			if lines[i].op == ' ' {
+				unchanged++
+				continue
+			}
+
+			last, unchanged = i, 0
+		}
+		end := last + diffContext + 1
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		writeHunk(&b, lines, first, end)
+		start = end
+	}
+
+	return b.String()
+}
+
+// writeHunk writes the lines from first up to end as a hunk.
+func writeHunk(b *strings.Builder, lines []diffLine, first, end int) {
+	// Line numbers start at 1 and count the lines before the hunk
+	oldStart, newStart := 1, 1
+	for _, l := range lines[:first] {
+		if l.op != '+' {
+			oldStart++
+		}
+		if l.op != '-' {
+			newStart++
+		}
+	}
+
+	oldLen, newLen := 0, 0
+	for _, l := range lines[first:end] {
+		if l.op != '+' {
+			oldLen++
+		}
+		if l.op != '-' {
+			newLen++
+		}
+	}
+
+	fmt.Fprintf(b, "@@ -%s +%s @@\n",
+		hunkRange(oldStart, oldLen), hunkRange(newStart, newLen))
+	for _, l := range lines[first:end] {
+		b.WriteByte(l.op)
+		b.WriteString(l.text)
+		if !strings.HasSuffix(l.text, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats the start and length of a hunk. An empty range starts
+// at the line before it.
+func hunkRange(start, length int) string {
+	if length == 0 {
+		start--
+	}
+	if length == 1 {
+		return fmt.Sprint(start)
+	}
+
+	return fmt.Sprintf("%d,%d", start, length)
+}
+
+// splitLines splits s into lines, keeping the line endings.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// diffLines returns the edits that turn a into b, keeping the longest
+// common subsequence of lines.
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] is the length of the longest common subsequence of
+	// a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var result []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			result = append(result, diffLine{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			result = append(result, diffLine{'-', a[i]})
+			i++
+		default:
+			result = append(result, diffLine{'+', b[j]})
+			j++
+		}
+	}
+
+	return result
+}
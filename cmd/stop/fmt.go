@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/patdhlk/stop"
+	"github.com/patdhlk/stop/ast"
+)
+
+const fmtUsage = `usage: stop fmt [-d] [-check] [file ...]
+
+Fmt rewrites the given template files in their canonical format. Without
+files it formats standard input to standard output.
+`
+
+// runFmt runs the fmt subcommand and returns the exit code: 1 if -check
+// found files that aren't formatted and 2 if a file couldn't be read,
+// parsed or written.
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, fmtUsage)
+		flags.PrintDefaults()
+	}
+	diff := flags.Bool("d", false, "print a diff instead of rewriting files")
+	check := flags.Bool("check", false, "list files that aren't formatted and exit with 1 if there are any")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+
+		out, err := formatSource(string(src))
+		if err != nil {
+			stop.RenderError(stderr, string(src), err, stop.FormatText)
+			return 2
+		}
+
+		switch {
+		case *diff:
+			io.WriteString(stdout, lineDiff("<standard input>", string(src), out))
+		case *check:
+		default:
+			io.WriteString(stdout, out)
+		}
+
+		if *check && out != string(src) {
+			return 1
+		}
+
+		return 0
+	}
+
+	code := 0
+	for _, path := range flags.Args() {
+		changed, err := fmtFile(path, *diff, *check, stdout, stderr)
+		if err != nil {
+			code = 2
+			continue
+		}
+
+		if changed && *check {
+			fmt.Fprintln(stdout, path)
+			if code == 0 {
+				code = 1
+			}
+		}
+	}
+
+	return code
+}
+
+// fmtFile formats the file at path, printing a diff if diff is set and
+// rewriting it unless diff or check is set. It returns whether the file
+// wasn't formatted. Errors are reported to stderr.
+func fmtFile(path string, diff, check bool, stdout, stderr io.Writer) (bool, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return false, err
+	}
+
+	out, err := formatSource(string(src))
+	if err != nil {
+		fmt.Fprintf(stderr, "%s:\n", path)
+		stop.RenderError(stderr, string(src), err, stop.FormatText)
+		return false, err
+	}
+
+	if bytes.Equal(src, []byte(out)) {
+		return false, nil
+	}
+
+	if diff {
+		io.WriteString(stdout, lineDiff(path, string(src), out))
+	}
+	if diff || check {
+		return true, nil
+	}
+
+	if err := writeFile(path, []byte(out)); err != nil {
+		fmt.Fprintln(stderr, err)
+		return true, err
+	}
+
+	return true, nil
+}
+
+// writeFile replaces the file at path with data, keeping its permissions.
+// The data is written to a temporary file that is renamed over the file,
+// so the file isn't left half written if writing fails.
+func writeFile(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(info.Mode().Perm()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// formatSource returns the canonical format of a template. The result is
+// parsed again and must give the same tree, so that formatting never
+// changes what a template does.
+func formatSource(src string) (string, error) {
+	node, err := stop.Parse(src)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := stop.Print(node)
+	if err != nil {
+		return "", err
+	}
+
+	reparsed, err := stop.Parse(out)
+	if err != nil {
+		return "", fmt.Errorf("formatted template doesn't parse: %s", err)
+	}
+	if !ast.Equal(reparsed, node, &ast.EqualOptions{IgnorePositions: true}) {
+		return "", fmt.Errorf("formatting would change the template")
+	}
+
+	return out, nil
+}
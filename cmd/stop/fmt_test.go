@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineDiff(t *testing.T) {
+	cases := []struct {
+		Old, New string
+		Output   string
+	}{
+		{
+			"a\n",
+			"a\n",
+			"",
+		},
+		{
+			"a\nb\nc\n",
+			"a\nB\nc\n",
+			"--- f.orig\n+++ f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"x\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\nx\n",
+			"--- f.orig\n+++ f\n" +
+				"@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n" +
+				"@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+x\n",
+		},
+		{
+			"a",
+			"",
+			"--- f.orig\n+++ f\n@@ -1 +0,0 @@\n-a\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, tc := range cases {
+		actual := lineDiff("f", tc.Old, tc.New)
+		if actual != tc.Output {
+			t.Fatalf("Bad: %q\n\nExpected: %q", actual, tc.Output)
+		}
+	}
+}
+
+func TestRunFmt(t *testing.T) {
+	dir := t.TempDir()
+	formatted := filepath.Join(dir, "formatted.tpl")
+	unformatted := filepath.Join(dir, "unformatted.tpl")
+	invalid := filepath.Join(dir, "invalid.tpl")
+	files := map[string]string{
+		formatted:   "foo #{lower(var.foo)}\n",
+		unformatted: "foo #{ lower( var.foo )+1 }\n",
+		invalid:     "foo #{lower(}\n",
+	}
+	for path, src := range files {
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	run := func(args ...string) int {
+		stdout.Reset()
+		stderr.Reset()
+		return runFmt(args, strings.NewReader(""), &stdout, &stderr)
+	}
+
+	// -check lists the unformatted files without changing them
+	if code := run("-check", formatted, unformatted); code != 1 {
+		t.Fatalf("bad exit code: %d\n\n%s", code, stderr.String())
+	}
+	if stdout.String() != unformatted+"\n" {
+		t.Fatalf("bad: %q", stdout.String())
+	}
+
+	// -d prints a diff
+	if code := run("-d", unformatted); code != 0 {
+		t.Fatalf("bad exit code: %d\n\n%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "+foo #{lower(var.foo) + 1}\n") {
+		t.Fatalf("bad: %q", stdout.String())
+	}
+
+	// Without flags files are rewritten
+	if code := run(formatted, unformatted); code != 0 {
+		t.Fatalf("bad exit code: %d\n\n%s", code, stderr.String())
+	}
+	src, err := os.ReadFile(unformatted)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(src) != "foo #{lower(var.foo) + 1}\n" {
+		t.Fatalf("bad: %q", src)
+	}
+
+	// Rewritten files keep their permissions and no temporary files are
+	// left behind
+	if info, err := os.Stat(unformatted); err != nil || info.Mode().Perm() != 0644 {
+		t.Fatalf("bad: %v %v", info.Mode(), err)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != len(files) {
+		t.Fatalf("bad: %v %v", entries, err)
+	}
+	if code := run("-check", formatted, unformatted); code != 0 {
+		t.Fatalf("bad exit code: %d\n\n%s", code, stdout.String())
+	}
+
+	// Errors are reported as diagnostics
+	if code := run(invalid); code != 2 {
+		t.Fatalf("bad exit code: %d", code)
+	}
+	if !strings.Contains(stderr.String(), "parse error") {
+		t.Fatalf("bad: %q", stderr.String())
+	}
+
+	// Standard input is formatted to standard output
+	stdout.Reset()
+	code := runFmt(nil, strings.NewReader("#{1+2}"), &stdout, &stderr)
+	if code != 0 || stdout.String() != "#{1 + 2}" {
+		t.Fatalf("bad: %d %q", code, stdout.String())
+	}
+
+	// Adjacent string literals are kept apart
+	cases := map[string]string{
+		`a#{"b"}`:    `a#{"b"}`,
+		`#{ "x" } y`: `x#{" y"}`,
+	}
+	for input, expected := range cases {
+		stdout.Reset()
+		stderr.Reset()
+		code := runFmt(nil, strings.NewReader(input), &stdout, &stderr)
+		if code != 0 || stdout.String() != expected {
+			t.Fatalf("bad: %d %q\n\n%s\n\nInput: %s", code, stdout.String(), stderr.String(), input)
+		}
+
+		code = runFmt([]string{"-check"}, strings.NewReader(expected), &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("bad exit code: %d\n\n%s\n\nInput: %s", code, stderr.String(), expected)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"os"
 	"reflect"
 	"strings"
 
@@ -13,58 +14,58 @@ import (
 )
 
 var (
-	inputPtr string
+	inputPtr  string
+	formatPtr string
+
+	// errorFormat is the format named by formatPtr.
+	errorFormat stop.DiagnosticFormat
 )
 
 var (
-	LowerCase = ast.Function{
-		ArgTypes:   []ast.Type{ast.TString},
-		ReturnType: ast.TString,
-		Variadic:   false,
-		Callback: func(inputs []interface{}) (interface{}, error) {
-			input := inputs[0].(string)
-			return strings.ToLower(input), nil
-		},
-	}
-
-	Pow = ast.Function{
-		ArgTypes:   []ast.Type{ast.TFloat, ast.TFloat},
-		ReturnType: ast.TFloat,
-		Variadic:   false,
-		Callback: func(inputs []interface{}) (interface{}, error) {
-			basis := inputs[0].(float64)
-			exponent := inputs[1].(float64)
-			return math.Pow(basis, exponent), nil
-		},
-	}
+	LowerCase = stop.Func(strings.ToLower)
 
-	Equal = ast.Function{
-		ArgTypes: []ast.Type{
-			ast.TAny,
-			ast.TAny,
-		},
-		ReturnType: ast.TBool,
-		Variadic:   false,
-		Callback: func(inputs []interface{}) (interface{}, error) {
-			first := inputs[0]
-			second := inputs[1]
+	Pow = stop.Func(math.Pow)
 
-			return reflect.DeepEqual(first, second), nil
-		},
-	}
+	Equal = stop.Func(func(first, second interface{}) bool {
+		return reflect.DeepEqual(first, second)
+	})
 )
 
 func init() {
 	flag.StringVar(&inputPtr, "input", `#{lower(var.test)} - #{6 + 2} + #{pow(var2.test,2)}`, "the stop string which should be parsed")
+	flag.StringVar(&formatPtr, "format", "text", "the format of errors: text, ansi or json")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: stop [flags]\n       stop fmt [-d] [-check] [file ...]\n\n")
+		flag.PrintDefaults()
+	}
+}
+
+// fail reports the error for the given input and exits.
+func fail(input string, err error) {
+	if rerr := stop.RenderError(os.Stderr, input, err, errorFormat); rerr != nil {
+		log.Fatal(rerr)
+	}
+
+	os.Exit(1)
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		os.Exit(runFmt(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
 	flag.Parse()
+	format, err := stop.ParseDiagnosticFormat(formatPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	errorFormat = format
+
 	var five float64 = 5
 	fmt.Printf("Input: %s\n", inputPtr)
 	tree, err := stop.Parse(inputPtr)
 	if err != nil {
-		log.Fatal(err)
+		fail(inputPtr, err)
 	}
 
 	config := &stop.EvalConfig{
@@ -88,7 +89,7 @@ func main() {
 
 	result, err := stop.Eval(tree, config)
 	if err != nil {
-		log.Fatal(err)
+		fail(inputPtr, err)
 	}
 
 	fmt.Printf("Type: %s\n", result.Type)
@@ -99,7 +100,7 @@ func main() {
 
 	tree, err = stop.Parse(input)
 	if err != nil {
-		log.Fatal(err)
+		fail(input, err)
 	}
 
 	config = &stop.EvalConfig{
@@ -118,7 +119,7 @@ func main() {
 
 	result, err = stop.Eval(tree, config)
 	if err != nil {
-		log.Fatal(err)
+		fail(input, err)
 	}
 
 	fmt.Printf("Type: %s\n", result.Type)
@@ -1,8 +1,10 @@
 package stop
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"strconv"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/patdhlk/stop/ast"
@@ -41,108 +43,488 @@ func STOPMapstructureWeakDecode(m interface{}, rawVal interface{}) error {
 	return decoder.Decode(m)
 }
 
+// InterfaceToVariable converts a Go value to an ast.Variable, keeping its
+// type: strings become TString, integers TInt, floats TFloat, bools TBool,
+// slices TList and maps with string keys as well as structs TMap. Lists
+// and maps are converted recursively, so mixed and nested values such as
+// those produced by encoding/json keep their types. json.Number values
+// become TInt if they are integers and TFloat otherwise. nil values, such
+// as JSON null, become empty strings.
+//
+// Variables are returned as they are. VariableToInterface is the inverse
+// of this function.
 func InterfaceToVariable(input interface{}) (ast.Variable, error) {
-	if inputVariable, ok := input.(ast.Variable); ok {
-		return inputVariable, nil
+	result, err := reflectToVariable(reflect.ValueOf(input))
+	if err != nil {
+		return ast.Variable{}, fmt.Errorf("cannot convert %T: %s", input, err)
 	}
 
-	var stringVal string
-	if err := STOPMapstructureWeakDecode(input, &stringVal); err == nil {
-		return ast.Variable{
-			Type:  ast.TString,
-			Value: stringVal,
-		}, nil
-	}
+	return result, nil
+}
 
-	var mapVal map[string]interface{}
-	if err := STOPMapstructureWeakDecode(input, &mapVal); err == nil {
-		elements := make(map[string]ast.Variable)
-		for i, element := range mapVal {
-			varElement, err := InterfaceToVariable(element)
+// VariableToInterface converts an ast.Variable to its natural Go value:
+// TString to string, TInt to int, TFloat to float64, TBool to bool, TList
+// to []interface{} and TMap to map[string]interface{}. It is the inverse
+// of InterfaceToVariable.
+func VariableToInterface(input ast.Variable) (interface{}, error) {
+	switch input.Type.Kind() {
+	case ast.TString:
+		if inputStr, ok := input.Value.(string); ok {
+			return inputStr, nil
+		}
+
+		return nil, fmt.Errorf("ast.Variable with type string has value which is not a string")
+	case ast.TInt:
+		if inputInt, ok := input.Value.(int); ok {
+			return inputInt, nil
+		}
+
+		return nil, fmt.Errorf("ast.Variable with type int has value which is not an int")
+	case ast.TFloat:
+		if inputFloat, ok := input.Value.(float64); ok {
+			return inputFloat, nil
+		}
+
+		return nil, fmt.Errorf("ast.Variable with type float has value which is not a float64")
+	case ast.TBool:
+		if inputBool, ok := input.Value.(bool); ok {
+			return inputBool, nil
+		}
+
+		return nil, fmt.Errorf("ast.Variable with type bool has value which is not a bool")
+	case ast.TList:
+		inputList, ok := input.Value.([]ast.Variable)
+		if !ok {
+			return nil, fmt.Errorf("ast.Variable with type list has value which is not a []ast.Variable")
+		}
+
+		result := make([]interface{}, 0, len(inputList))
+		for _, element := range inputList {
+			convertedElement, err := VariableToInterface(element)
 			if err != nil {
-				return ast.Variable{}, err
+				return nil, err
 			}
-			elements[i] = varElement
+
+			result = append(result, convertedElement)
+		}
+
+		return result, nil
+	case ast.TMap:
+		inputMap, ok := input.Value.(map[string]ast.Variable)
+		if !ok {
+			return nil, fmt.Errorf("ast.Variable with type map has value which is not a map[string]ast.Variable")
 		}
 
-		return ast.Variable{
-			Type:  ast.TMap,
-			Value: elements,
-		}, nil
+		result := make(map[string]interface{}, len(inputMap))
+		for key, value := range inputMap {
+			convertedValue, err := VariableToInterface(value)
+			if err != nil {
+				return nil, err
+			}
+
+			result[key] = convertedValue
+		}
+
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unknown input type: %s", input.Type)
 	}
+}
 
-	var sliceVal []interface{}
-	if err := STOPMapstructureWeakDecode(input, &sliceVal); err == nil {
-		elements := make([]ast.Variable, len(sliceVal))
-		for i, element := range sliceVal {
-			varElement, err := InterfaceToVariable(element)
+var (
+	variableType   = reflect.TypeOf(ast.Variable{})
+	jsonNumberType = reflect.TypeOf(json.Number(""))
+)
+
+// reflectType returns the STOP type that values of the given Go type are
+// converted to.
+func reflectType(t reflect.Type) (ast.Type, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return ast.TString, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return ast.TInt, nil
+	case reflect.Float32, reflect.Float64:
+		return ast.TFloat, nil
+	case reflect.Bool:
+		return ast.TBool, nil
+	case reflect.Slice, reflect.Array:
+		elem, err := reflectType(t.Elem())
+		if err != nil {
+			return ast.TUnsupported, err
+		}
+		if elem == ast.TAny {
+			return ast.TList, nil
+		}
+
+		return ast.List(elem), nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return ast.TUnsupported, fmt.Errorf("map keys must be strings, got %s", t.Key())
+		}
+
+		elem, err := reflectType(t.Elem())
+		if err != nil {
+			return ast.TUnsupported, err
+		}
+		if elem == ast.TAny {
+			return ast.TMap, nil
+		}
+
+		return ast.Map(elem), nil
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return ast.TUnsupported, fmt.Errorf("unsupported interface type %s", t)
+		}
+
+		return ast.TAny, nil
+	default:
+		return ast.TUnsupported, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// reflectToVariable converts a Go value to a Variable, preserving its
+// type. Interfaces and pointers are followed to the value they contain and
+// structs are converted to maps as described on StructScope. Values that
+// contain themselves, such as a struct pointing back to itself, are an
+// error.
+func reflectToVariable(v reflect.Value) (ast.Variable, error) {
+	c := &converter{path: make(map[reference]bool)}
+	return c.variable(v)
+}
+
+// reference identifies the pointer, map or slice a value is reached
+// through.
+type reference struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// converter converts values with reflectToVariable, keeping the pointers,
+// maps and slices that lead to the value being converted to find cycles.
+type converter struct {
+	path map[reference]bool
+}
+
+// enter adds the reference v to the path, returning a function that
+// removes it again. It fails if v is on the path already.
+func (c *converter) enter(v reflect.Value) (func(), error) {
+	ref := reference{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		ref.len = v.Len()
+	}
+	if c.path[ref] {
+		return nil, fmt.Errorf("%s contains itself", v.Type())
+	}
+
+	c.path[ref] = true
+	return func() { delete(c.path, ref) }, nil
+}
+
+func (c *converter) variable(v reflect.Value) (ast.Variable, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ast.Variable{Type: ast.TString, Value: ""}, nil
+		}
+
+		if v.Kind() == reflect.Ptr {
+			leave, err := c.enter(v)
 			if err != nil {
 				return ast.Variable{}, err
 			}
-			elements[i] = varElement
+			defer leave()
 		}
 
-		return ast.Variable{
-			Type:  ast.TList,
-			Value: elements,
-		}, nil
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return ast.Variable{Type: ast.TString, Value: ""}, nil
+	}
+	if (v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && !v.IsNil() {
+		leave, err := c.enter(v)
+		if err != nil {
+			return ast.Variable{}, err
+		}
+		defer leave()
 	}
 
-	return ast.Variable{}, fmt.Errorf("value for conversion must be a string, interface{} or map[string]interface: got %T", input)
-}
+	switch v.Type() {
+	case variableType, jsonNumberType:
+		// Fields promoted through unexported embedded structs can be read
+		// but not turned back into interfaces
+		if !v.CanInterface() {
+			return ast.Variable{}, fmt.Errorf("%s can't be read through an unexported field", v.Type())
+		}
+	}
 
-func VariableToInterface(input ast.Variable) (interface{}, error) {
-	if input.Type == ast.TString {
-		if inputStr, ok := input.Value.(string); ok {
-			return inputStr, nil
-		} else {
-			return nil, fmt.Errorf("ast.Variable with type string has value which is not a string")
+	switch v.Type() {
+	case variableType:
+		return v.Interface().(ast.Variable), nil
+	case jsonNumberType:
+		n := v.Interface().(json.Number)
+		if i, err := strconv.ParseInt(string(n), 10, 0); err == nil {
+			return ast.Variable{Type: ast.TInt, Value: int(i)}, nil
 		}
+
+		f, err := n.Float64()
+		if err != nil {
+			return ast.Variable{}, err
+		}
+
+		return ast.Variable{Type: ast.TFloat, Value: f}, nil
 	}
 
-	if input.Type == ast.TList {
-		inputList, ok := input.Value.([]ast.Variable)
-		if !ok {
-			return nil, fmt.Errorf("ast.Variable with type list has value which is not a []ast.Variable")
+	switch v.Kind() {
+	case reflect.String:
+		return ast.Variable{Type: ast.TString, Value: v.String()}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		if int64(int(i)) != i {
+			return ast.Variable{}, fmt.Errorf("%d overflows int", i)
 		}
 
-		result := make([]interface{}, 0)
-		if len(inputList) == 0 {
-			return result, nil
+		return ast.Variable{Type: ast.TInt, Value: int(i)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i := v.Uint()
+		if int(i) < 0 || uint64(int(i)) != i {
+			return ast.Variable{}, fmt.Errorf("%d overflows int", i)
 		}
 
-		for _, element := range inputList {
-			if convertedElement, err := VariableToInterface(element); err == nil {
-				result = append(result, convertedElement)
-			} else {
-				return nil, err
+		return ast.Variable{Type: ast.TInt, Value: int(i)}, nil
+	case reflect.Float32, reflect.Float64:
+		return ast.Variable{Type: ast.TFloat, Value: v.Float()}, nil
+	case reflect.Bool:
+		return ast.Variable{Type: ast.TBool, Value: v.Bool()}, nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]ast.Variable, v.Len())
+		for i := range elements {
+			element, err := c.variable(v.Index(i))
+			if err != nil {
+				return ast.Variable{}, fmt.Errorf("element %d: %s", i, err)
 			}
+			elements[i] = element
 		}
 
-		return result, nil
+		return ast.Variable{Type: ast.TList, Value: elements}, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return ast.Variable{}, fmt.Errorf("map keys must be strings, got %s", v.Type().Key())
+		}
+
+		elements := make(map[string]ast.Variable, v.Len())
+		for _, k := range v.MapKeys() {
+			element, err := c.variable(v.MapIndex(k))
+			if err != nil {
+				return ast.Variable{}, fmt.Errorf("key %q: %s", k.String(), err)
+			}
+			elements[k.String()] = element
+		}
+
+		return ast.Variable{Type: ast.TMap, Value: elements}, nil
+	case reflect.Struct:
+		elements := make(map[string]ast.Variable)
+		var err error
+		eachStructField(v, func(name string, field reflect.Value) bool {
+			// Nil fields are left out rather than failing the conversion
+			if !indirect(field).IsValid() {
+				return true
+			}
+
+			var element ast.Variable
+			element, err = c.variable(field)
+			if err != nil {
+				err = fmt.Errorf("field %q: %s", name, err)
+				return false
+			}
+
+			elements[name] = element
+			return true
+		})
+		if err != nil {
+			return ast.Variable{}, err
+		}
+
+		return ast.Variable{Type: ast.TMap, Value: elements}, nil
+	default:
+		return ast.Variable{}, fmt.Errorf("unsupported type %s", v.Type())
+	}
+}
+
+// checkResult verifies that the value returned by a function callback is
+// of type t and returns it along with its type. Unless strict is set, Go
+// values of compatible types are converted, such as an int64 to an int or
+// a []string to a list of strings. For unions the type returned is the
+// type of the value.
+func checkResult(value interface{}, t ast.Type, strict bool) (interface{}, ast.Type, error) {
+	if t == ast.TAny {
+		return value, t, nil
 	}
 
-	if input.Type == ast.TMap {
-		inputMap, ok := input.Value.(map[string]ast.Variable)
+	var actual ast.Variable
+	if strict {
+		switch v := value.(type) {
+		case string:
+			actual = ast.Variable{Type: ast.TString, Value: v}
+		case int:
+			actual = ast.Variable{Type: ast.TInt, Value: v}
+		case float64:
+			actual = ast.Variable{Type: ast.TFloat, Value: v}
+		case bool:
+			actual = ast.Variable{Type: ast.TBool, Value: v}
+		case []ast.Variable:
+			actual = ast.Variable{Type: ast.TList, Value: v}
+		case map[string]ast.Variable:
+			actual = ast.Variable{Type: ast.TMap, Value: v}
+		default:
+			return nil, ast.TUnsupported, fmt.Errorf(
+				"returned unsupported Go type %T, expected %s", value, t.Printable())
+		}
+	} else {
+		var err error
+		actual, err = reflectToVariable(reflect.ValueOf(value))
+		if err != nil {
+			return nil, ast.TUnsupported, fmt.Errorf(
+				"returned %T, expected %s: %s", value, t.Printable(), err)
+		}
+	}
+
+	if !actual.AssignableTo(t) {
+		return nil, ast.TUnsupported, &TypeMismatchError{
+			What:     "return value",
+			Expected: t,
+			Actual:   actual.Type,
+		}
+	}
+
+	if t.IsUnion() {
+		t = actual.Type
+	}
+
+	return actual.Value, t, nil
+}
+
+// valueToReflect converts the value of a Variable to the given Go type.
+// It is the inverse of reflectToVariable.
+func valueToReflect(value interface{}, t reflect.Type) (reflect.Value, error) {
+	result := reflect.New(t).Elem()
+	mismatch := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("cannot use %T as %s", value, t)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		s, ok := value.(string)
 		if !ok {
-			return nil, fmt.Errorf("ast.Variable with type map has value which is not a map[string]ast.Variable")
+			return mismatch()
 		}
 
-		result := make(map[string]interface{}, 0)
-		if len(inputMap) == 0 {
-			return result, nil
+		result.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := value.(int)
+		if !ok {
+			return mismatch()
+		}
+		if result.OverflowInt(int64(i)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i, t)
 		}
 
-		for key, value := range inputMap {
-			if convertedValue, err := VariableToInterface(value); err == nil {
-				result[key] = convertedValue
-			} else {
-				return nil, err
+		result.SetInt(int64(i))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, ok := value.(int)
+		if !ok {
+			return mismatch()
+		}
+		if i < 0 || result.OverflowUint(uint64(i)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i, t)
+		}
+
+		result.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		f, ok := value.(float64)
+		if !ok {
+			return mismatch()
+		}
+
+		result.SetFloat(f)
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return mismatch()
+		}
+
+		result.SetBool(b)
+	case reflect.Slice, reflect.Array:
+		list, ok := value.([]ast.Variable)
+		if !ok {
+			return mismatch()
+		}
+
+		if t.Kind() == reflect.Slice {
+			result = reflect.MakeSlice(t, len(list), len(list))
+		} else if len(list) != t.Len() {
+			return reflect.Value{}, fmt.Errorf(
+				"cannot use list of %d elements as %s", len(list), t)
+		}
+
+		for i, element := range list {
+			v, err := valueToReflect(element.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %s", i, err)
 			}
+			result.Index(i).Set(v)
+		}
+	case reflect.Map:
+		vmap, ok := value.(map[string]ast.Variable)
+		if !ok || t.Key().Kind() != reflect.String {
+			return mismatch()
 		}
 
-		return result, nil
+		result = reflect.MakeMapWithSize(t, len(vmap))
+		for k, element := range vmap {
+			v, err := valueToReflect(element.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %q: %s", k, err)
+			}
+			result.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), v)
+		}
+	case reflect.Interface:
+		natural := naturalValue(value)
+		if natural == nil {
+			break
+		}
+		if !reflect.TypeOf(natural).Implements(t) {
+			return mismatch()
+		}
+
+		result.Set(reflect.ValueOf(natural))
+	default:
+		return mismatch()
 	}
 
-	return nil, fmt.Errorf("unknown input type: %s", input.Type)
+	return result, nil
+}
+
+// naturalValue converts the lists and maps of Variables within a value
+// to []interface{} and map[string]interface{}.
+func naturalValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []ast.Variable:
+		result := make([]interface{}, len(v))
+		for i, element := range v {
+			result[i] = naturalValue(element.Value)
+		}
+
+		return result
+	case map[string]ast.Variable:
+		result := make(map[string]interface{}, len(v))
+		for k, element := range v {
+			result[k] = naturalValue(element.Value)
+		}
+
+		return result
+	default:
+		return value
+	}
 }
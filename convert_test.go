@@ -1,6 +1,7 @@
 package stop
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 
@@ -52,8 +53,41 @@ func TestInterfaceToVariable(t *testing.T) {
 			name:  "int",
 			input: 1,
 			expected: ast.Variable{
-				Type:  ast.TString,
-				Value: "1",
+				Type:  ast.TInt,
+				Value: 1,
+			},
+		},
+		{
+			name:  "float",
+			input: 3.5,
+			expected: ast.Variable{
+				Type:  ast.TFloat,
+				Value: 3.5,
+			},
+		},
+		{
+			name:  "bool",
+			input: true,
+			expected: ast.Variable{
+				Type:  ast.TBool,
+				Value: true,
+			},
+		},
+		{
+			name:  "json numbers",
+			input: []interface{}{json.Number("42"), json.Number("3.5")},
+			expected: ast.Variable{
+				Type: ast.TList,
+				Value: []ast.Variable{
+					{
+						Type:  ast.TInt,
+						Value: 42,
+					},
+					{
+						Type:  ast.TFloat,
+						Value: 3.5,
+					},
+				},
 			},
 		},
 		{
@@ -394,3 +428,177 @@ func TestVariableToInterface(t *testing.T) {
 		}
 	}
 }
+
+func TestInterfaceToVariable_error(t *testing.T) {
+	cyclic := []interface{}{"foo", nil}
+	cyclic[1] = cyclic
+
+	inputs := []interface{}{
+		make(chan int),
+		map[int]string{1: "foo"},
+		[]interface{}{"foo", make(chan int)},
+		cyclic,
+	}
+
+	for i, input := range inputs {
+		if _, err := InterfaceToVariable(input); err == nil {
+			t.Fatalf("input %d should error", i)
+		}
+	}
+}
+
+func TestVariableToInterface_error(t *testing.T) {
+	inputs := []ast.Variable{
+		{Type: ast.TString, Value: 42},
+		{Type: ast.TInt, Value: "42"},
+		{Type: ast.TFloat, Value: 42},
+		{Type: ast.TBool, Value: "true"},
+		{Type: ast.TList, Value: []interface{}{}},
+		{Type: ast.TMap, Value: map[string]interface{}{}},
+		{Type: ast.TList, Value: []ast.Variable{{Type: ast.TInt, Value: 1.5}}},
+		{Type: ast.TUnsupported, Value: nil},
+	}
+
+	for _, input := range inputs {
+		if _, err := VariableToInterface(input); err == nil {
+			t.Fatalf("should error: %s", input)
+		}
+	}
+}
+
+func TestInterfaceToVariable_roundTrip(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input interface{}
+	}{
+		{"string", "foo"},
+		{"int", 42},
+		{"negative int", -7},
+		{"float", 3.5},
+		{"bool", true},
+		{"empty list", []interface{}{}},
+		{"empty map", map[string]interface{}{}},
+		{"list of ints", []interface{}{1, 2, 3}},
+		{"mixed list", []interface{}{"a", 1, 2.5, false}},
+		{
+			"nested",
+			map[string]interface{}{
+				"name":  "x",
+				"port":  80,
+				"tls":   true,
+				"load":  0.25,
+				"hosts": []interface{}{"a", "b"},
+				"weights": map[string]interface{}{
+					"a": []interface{}{1, 2.5},
+					"b": map[string]interface{}{"enabled": false},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		variable, err := InterfaceToVariable(tc.input)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+
+		output, err := VariableToInterface(variable)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if !reflect.DeepEqual(output, tc.input) {
+			t.Fatalf("%s:\nExpected: %#v\n     Got: %#v\n", tc.name, tc.input, output)
+		}
+
+		again, err := InterfaceToVariable(output)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if !reflect.DeepEqual(again, variable) {
+			t.Fatalf("%s:\nExpected: %s\n     Got: %s\n", tc.name, variable, again)
+		}
+	}
+}
+
+func TestInterfaceToVariable_json(t *testing.T) {
+	var input interface{}
+	err := json.Unmarshal([]byte(`{"name": "x", "port": 80, "tls": true, "ratio": 0.5, "owner": null}`), &input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := InterfaceToVariable(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ast.Variable{
+		Type: ast.TMap,
+		Value: map[string]ast.Variable{
+			"name":  {Type: ast.TString, Value: "x"},
+			"port":  {Type: ast.TFloat, Value: 80.0},
+			"tls":   {Type: ast.TBool, Value: true},
+			"ratio": {Type: ast.TFloat, Value: 0.5},
+			"owner": {Type: ast.TString, Value: ""},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("\nExpected: %s\n     Got: %s\n", expected, actual)
+	}
+}
+
+func TestCheckResult(t *testing.T) {
+	cases := []struct {
+		Value  interface{}
+		Type   ast.Type
+		Strict bool
+		Result interface{}
+		Output ast.Type
+		Error  bool
+	}{
+		{"foo", ast.TString, true, "foo", ast.TString, false},
+		{42, ast.TString, false, nil, ast.TUnsupported, true},
+		{int64(42), ast.TInt, false, 42, ast.TInt, false},
+		{int64(42), ast.TInt, true, nil, ast.TUnsupported, true},
+		{1.5, ast.Union(ast.TInt, ast.TFloat), true, 1.5, ast.TFloat, false},
+		{"foo", ast.Union(ast.TInt, ast.TFloat), false, nil, ast.TUnsupported, true},
+		{"anything", ast.TAny, true, "anything", ast.TAny, false},
+		{
+			[]string{"a"},
+			ast.List(ast.TString),
+			false,
+			[]ast.Variable{{Type: ast.TString, Value: "a"}},
+			ast.List(ast.TString),
+			false,
+		},
+		{
+			map[string]interface{}{"name": "a", "port": 80},
+			ast.Object(map[string]ast.Type{"name": ast.TString, "port": ast.TInt}),
+			false,
+			map[string]ast.Variable{
+				"name": {Type: ast.TString, Value: "a"},
+				"port": {Type: ast.TInt, Value: 80},
+			},
+			ast.Object(map[string]ast.Type{"name": ast.TString, "port": ast.TInt}),
+			false,
+		},
+		{
+			map[string]interface{}{"name": "a"},
+			ast.Object(map[string]ast.Type{"name": ast.TString, "port": ast.TInt}),
+			false,
+			nil,
+			ast.TUnsupported,
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		result, output, err := checkResult(tc.Value, tc.Type, tc.Strict)
+		if (err != nil) != tc.Error {
+			t.Fatalf("%#v as %s: err: %s", tc.Value, tc.Type, err)
+		}
+		if !reflect.DeepEqual(result, tc.Result) || output != tc.Output {
+			t.Fatalf("%#v as %s: bad: %#v (%s)", tc.Value, tc.Type, result, output)
+		}
+	}
+}
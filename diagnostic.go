@@ -0,0 +1,361 @@
+package stop
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/patdhlk/stop/ast"
+)
+
+// Diagnostic describes an error in a program in a way that can be shown
+// to a user along with the source it refers to, see RenderDiagnostics.
+//
+// Pos and End are the range of the source the error refers to. Pos has
+// a Line of zero if the error isn't tied to a position. End may be the
+// zero Pos if only the start of the range is known.
+type Diagnostic struct {
+	Pos     ast.Pos
+	End     ast.Pos
+	Message string
+
+	// Hints are suggestions on how to fix the error.
+	Hints []string
+
+	// Related is another location involved in the error, if any.
+	Related *Related
+}
+
+// Related is a location that is related to an error, such as the first
+// of two conflicting arguments.
+type Related struct {
+	Pos     ast.Pos
+	End     ast.Pos
+	Message string
+}
+
+// DiagnosticFormat is the format in which RenderDiagnostics writes
+// diagnostics.
+type DiagnosticFormat int
+
+const (
+	// FormatText shows the message and the line of the source the error
+	// refers to, underlining the range of the error.
+	FormatText DiagnosticFormat = iota
+
+	// FormatANSI is FormatText highlighted with ANSI escape codes for
+	// terminals.
+	FormatANSI
+
+	// FormatJSON writes the diagnostics as a JSON array for tools.
+	FormatJSON
+)
+
+// ParseDiagnosticFormat returns the format with the given name, which is
+// one of "text", "ansi" or "json".
+func ParseDiagnosticFormat(name string) (DiagnosticFormat, error) {
+	switch name {
+	case "text":
+		return FormatText, nil
+	case "ansi":
+		return FormatANSI, nil
+	case "json":
+		return FormatJSON, nil
+	default:
+		return 0, fmt.Errorf("unknown diagnostic format: %q", name)
+	}
+}
+
+// Diagnostics returns the diagnostics for an error returned by Parse,
+// the semantic checks or Eval. Each error of a *MultiError becomes a
+// diagnostic of its own. Errors without a known range get a Pos with a
+// Line of zero.
+func Diagnostics(err error) []Diagnostic {
+	if err == nil {
+		return nil
+	}
+
+	var merr *MultiError
+	if errors.As(err, &merr) {
+		var result []Diagnostic
+		for _, err := range merr.Errors {
+			result = append(result, Diagnostics(err)...)
+		}
+
+		return result
+	}
+
+	d := Diagnostic{Message: err.Error()}
+	var perr positionedError
+	if errors.As(err, &perr) {
+		d.Pos, d.End = perr.errorRange()
+	}
+
+	// The position is shown separately
+	if d.Pos.Line > 0 {
+		d.Message = strings.TrimPrefix(d.Message, d.Pos.String()+": ")
+	}
+
+	var parseErr *ParseError
+	var varErr *UnknownVariableError
+	var funcErr *UnknownFunctionError
+	switch {
+	case errors.As(err, &parseErr):
+		d.Related = parseErr.Related
+	case errors.As(err, &varErr):
+		d.Message, d.Hints = stripSuggestions(d.Message, varErr.Suggestions)
+	case errors.As(err, &funcErr):
+		d.Message, d.Hints = stripSuggestions(d.Message, funcErr.Suggestions)
+	}
+
+	return []Diagnostic{d}
+}
+
+// RenderError writes the diagnostics for err, which was returned for the
+// program src, to w in the given format.
+func RenderError(w io.Writer, src string, err error, format DiagnosticFormat) error {
+	return RenderDiagnostics(w, src, Diagnostics(err), format)
+}
+
+// RenderDiagnostics writes the diagnostics for the program src to w in the
+// given format.
+func RenderDiagnostics(w io.Writer, src string, diags []Diagnostic, format DiagnosticFormat) error {
+	r := &diagnosticRenderer{
+		lines: strings.Split(src, "\n"),
+		color: format == FormatANSI,
+	}
+
+	switch format {
+	case FormatText, FormatANSI:
+		var b strings.Builder
+		for i, d := range diags {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+			r.render(&b, d)
+		}
+
+		_, err := io.WriteString(w, b.String())
+		return err
+	case FormatJSON:
+		result := make([]jsonDiagnostic, len(diags))
+		for i, d := range diags {
+			result[i] = r.json(d)
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	default:
+		return fmt.Errorf("unknown diagnostic format: %d", format)
+	}
+}
+
+// ANSI escape codes used by FormatANSI
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[1;31m"
+	ansiBlue  = "\x1b[1;34m"
+	ansiCyan  = "\x1b[1;36m"
+)
+
+// diagnosticRenderer renders diagnostics for the lines of a program.
+type diagnosticRenderer struct {
+	lines []string
+	color bool
+}
+
+// render writes a diagnostic in text format:
+//
+//	error: unknown variable accessed: var.nope
+//	 --> 1:7
+//	  |
+//	1 | foo #{var.nope}
+//	  |       ^^^^^^^^
+//	  = hint: did you mean var.name?
+func (r *diagnosticRenderer) render(b *strings.Builder, d Diagnostic) {
+	fmt.Fprintf(b, "%s %s\n",
+		r.style(ansiRed, "error:"), r.style(ansiBold, d.Message))
+
+	gutter := len(strconv.Itoa(d.Pos.Line))
+	if d.Related != nil && len(strconv.Itoa(d.Related.Pos.Line)) > gutter {
+		gutter = len(strconv.Itoa(d.Related.Pos.Line))
+	}
+
+	r.snippet(b, gutter, d.Pos, d.End, '^', ansiRed)
+	for _, h := range d.Hints {
+		fmt.Fprintf(b, "%s %s %s\n",
+			strings.Repeat(" ", gutter), r.style(ansiBlue, "="),
+			r.style(ansiCyan, "hint:")+" "+h)
+	}
+
+	if rel := d.Related; rel != nil {
+		fmt.Fprintf(b, "%s %s\n", r.style(ansiBlue, "note:"), rel.Message)
+		r.snippet(b, gutter, rel.Pos, rel.End, '-', ansiBlue)
+	}
+}
+
+// snippet writes the location of a range along with its first line,
+// underlining the range with the given character.
+func (r *diagnosticRenderer) snippet(b *strings.Builder, gutter int, pos, end ast.Pos, mark rune, color string) {
+	if pos.Line < 1 || pos.Line > len(r.lines) {
+		return
+	}
+
+	line := strings.TrimSuffix(r.lines[pos.Line-1], "\r")
+	pad := strings.Repeat(" ", gutter)
+	bar := r.style(ansiBlue, "|")
+
+	fmt.Fprintf(b, "%s%s %s\n", pad, r.style(ansiBlue, "-->"), pos)
+	fmt.Fprintf(b, "%s %s\n", pad, bar)
+	fmt.Fprintf(b, "%s %s %s\n",
+		r.style(ansiBlue, fmt.Sprintf("%*d", gutter, pos.Line)), bar, line)
+
+	// Ranges that go beyond the line are underlined up to its end
+	runes := []rune(line)
+	start := clamp(pos.Column-1, 0, len(runes))
+	stop := len(runes)
+	if end.Line == pos.Line {
+		stop = clamp(end.Column-1, start, len(runes))
+	}
+	width := stop - start
+	if width < 1 {
+		width = 1
+	}
+
+	// Keep tabs so that the underline lines up with the source
+	var indent strings.Builder
+	for _, c := range runes[:start] {
+		if c == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+
+	fmt.Fprintf(b, "%s %s %s%s\n", pad, bar, indent.String(),
+		r.style(color, strings.Repeat(string(mark), width)))
+}
+
+// style wraps s in the given ANSI escape code if colors are enabled.
+func (r *diagnosticRenderer) style(code, s string) string {
+	if !r.color {
+		return s
+	}
+
+	return code + s + ansiReset
+}
+
+// resolve fills in the offset of a position from its line and column,
+// which positions built by hand may lack. It returns nil for
+// positions that aren't within the program.
+func (r *diagnosticRenderer) resolve(p ast.Pos) *jsonPos {
+	if p.Line < 1 || p.Line > len(r.lines) {
+		return nil
+	}
+
+	offset := 0
+	for _, l := range r.lines[:p.Line-1] {
+		offset += len(l) + 1
+	}
+
+	line := r.lines[p.Line-1]
+	col := 1
+	for i := range line {
+		if col == p.Column {
+			return &jsonPos{Line: p.Line, Column: p.Column, Offset: offset + i}
+		}
+		col++
+	}
+
+	return &jsonPos{
+		Line:   p.Line,
+		Column: p.Column,
+		Offset: offset + len(line) + p.Column - col,
+	}
+}
+
+// jsonDiagnostic is the JSON representation of a Diagnostic.
+type jsonDiagnostic struct {
+	Message string       `json:"message"`
+	Start   *jsonPos     `json:"start,omitempty"`
+	End     *jsonPos     `json:"end,omitempty"`
+	Hints   []string     `json:"hints,omitempty"`
+	Related *jsonRelated `json:"related,omitempty"`
+}
+
+type jsonRelated struct {
+	Message string   `json:"message"`
+	Start   *jsonPos `json:"start,omitempty"`
+	End     *jsonPos `json:"end,omitempty"`
+}
+
+type jsonPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+func (r *diagnosticRenderer) json(d Diagnostic) jsonDiagnostic {
+	start, end := r.jsonRange(d.Pos, d.End)
+	result := jsonDiagnostic{
+		Message: d.Message,
+		Start:   start,
+		End:     end,
+		Hints:   d.Hints,
+	}
+
+	if d.Related != nil {
+		start, end := r.jsonRange(d.Related.Pos, d.Related.End)
+		result.Related = &jsonRelated{
+			Message: d.Related.Message,
+			Start:   start,
+			End:     end,
+		}
+	}
+
+	return result
+}
+
+// jsonRange returns the JSON representation of a range. Ranges with an
+// unknown end are taken to be empty.
+func (r *diagnosticRenderer) jsonRange(pos, end ast.Pos) (*jsonPos, *jsonPos) {
+	start := r.resolve(pos)
+	if start == nil {
+		return nil, nil
+	}
+
+	if end.Line == 0 {
+		return start, start
+	}
+
+	return start, r.resolve(end)
+}
+
+// stripSuggestions moves the suggestions for an unknown name from the end
+// of the message to a hint.
+func stripSuggestions(msg string, suggestions []string) (string, []string) {
+	suffix := didYouMean(suggestions)
+	if suffix == "" || !strings.HasSuffix(msg, suffix) {
+		return msg, nil
+	}
+
+	// " (did you mean x?)" becomes "did you mean x?"
+	hint := strings.TrimSuffix(strings.TrimPrefix(suffix, " ("), ")")
+	return strings.TrimSuffix(msg, suffix), []string{hint}
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+
+	return v
+}
@@ -0,0 +1,228 @@
+package stop
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/patdhlk/stop/ast"
+)
+
+func TestDiagnostics(t *testing.T) {
+	cases := []struct {
+		Err      error
+		Expected []Diagnostic
+	}{
+		{
+			nil,
+			nil,
+		},
+
+		{
+			errors.New("foo"),
+			[]Diagnostic{{Message: "foo"}},
+		},
+
+		{
+			errors.New("1:3: foo"),
+			[]Diagnostic{{Message: "1:3: foo"}},
+		},
+
+		{
+			&posError{
+				Pos: ast.Pos{Column: 3, Line: 2},
+				End: ast.Pos{Column: 6, Line: 2},
+				Err: errors.New("foo"),
+			},
+			[]Diagnostic{{
+				Pos:     ast.Pos{Column: 3, Line: 2},
+				End:     ast.Pos{Column: 6, Line: 2},
+				Message: "foo",
+			}},
+		},
+
+		{
+			fmt.Errorf("eval: %w", &UnknownVariableError{
+				Pos:         ast.Pos{Column: 3, Line: 1},
+				Name:        "foo",
+				Suggestions: []string{"fob"},
+			}),
+			[]Diagnostic{{
+				Pos:     ast.Pos{Column: 3, Line: 1},
+				Message: "eval: 1:3: unknown variable accessed: foo",
+				Hints:   []string{"did you mean fob?"},
+			}},
+		},
+
+		{
+			&MultiError{Errors: []error{
+				&UnknownVariableError{
+					Pos:         ast.Pos{Column: 3, Line: 1, Offset: 2},
+					End:         ast.Pos{Column: 6, Line: 1, Offset: 5},
+					Name:        "foo",
+					Suggestions: []string{"fob", "foo2"},
+				},
+				&PanicError{
+					Node: &ast.VariableAccess{
+						Name: "bar",
+						Posx: ast.Pos{Column: 9, Line: 1, Offset: 8},
+						Endx: ast.Pos{Column: 12, Line: 1, Offset: 11},
+					},
+					Value: "boom",
+				},
+			}},
+			[]Diagnostic{
+				{
+					Pos:     ast.Pos{Column: 3, Line: 1, Offset: 2},
+					End:     ast.Pos{Column: 6, Line: 1, Offset: 5},
+					Message: "unknown variable accessed: foo",
+					Hints:   []string{"did you mean fob or foo2?"},
+				},
+				{
+					Pos:     ast.Pos{Column: 9, Line: 1, Offset: 8},
+					End:     ast.Pos{Column: 12, Line: 1, Offset: 11},
+					Message: "panic evaluating Variable(bar): boom",
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		actual := Diagnostics(tc.Err)
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Fatalf("Bad: %#v\n\nExpected: %#v\n\nError: %v", actual, tc.Expected, tc.Err)
+		}
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	scope := &ast.BasicScope{
+		VarMap: map[string]ast.Variable{
+			"var.name": ast.Variable{Type: ast.TString, Value: "foo"},
+		},
+	}
+
+	cases := []struct {
+		Input    string
+		Expected string
+	}{
+		{
+			"foo #{var.nam}",
+			`error: unknown variable accessed: var.nam
+ --> 1:7
+  |
+1 | foo #{var.nam}
+  |       ^^^^^^^
+  = hint: did you mean var.name?
+`,
+		},
+
+		{
+			"#{f(a = 1,\tb)}",
+			"error: parse error: positional argument after named argument\n" +
+				" --> 1:12\n" +
+				"  |\n" +
+				"1 | #{f(a = 1,\tb)}\n" +
+				"  |           \t^\n" +
+				"note: the first named argument is here\n" +
+				" --> 1:5\n" +
+				"  |\n" +
+				"1 | #{f(a = 1,\tb)}\n" +
+				"  |     -----\n",
+		},
+
+		{
+			"foo\n#{upper(var.name)} #{var.name",
+			`error: parse error: syntax error
+ --> 2:30
+  |
+2 | #{upper(var.name)} #{var.name
+  |                              ^
+`,
+		},
+
+		{
+			"foo\n#{upper(var.name)} #{var.nope}",
+			`error: unknown function called: upper
+ --> 2:3
+  |
+2 | #{upper(var.name)} #{var.nope}
+  |   ^^^^^^^^^^^^^^^
+
+error: unknown variable accessed: var.nope
+ --> 2:22
+  |
+2 | #{upper(var.name)} #{var.nope}
+  |                      ^^^^^^^^
+  = hint: did you mean var.name?
+`,
+		},
+	}
+
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err == nil {
+			_, err = Eval(node, &EvalConfig{GlobalScope: scope})
+		}
+
+		var b bytes.Buffer
+		if err := RenderError(&b, tc.Input, err, FormatText); err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+		if b.String() != tc.Expected {
+			t.Fatalf("Bad:\n%s\nExpected:\n%s\nInput: %s", b.String(), tc.Expected, tc.Input)
+		}
+	}
+}
+
+func TestRenderError_ansi(t *testing.T) {
+	input := "#{var.nope}"
+	err := &UnknownVariableError{
+		Pos:  ast.Pos{Column: 3, Line: 1, Offset: 2},
+		End:  ast.Pos{Column: 11, Line: 1, Offset: 10},
+		Name: "var.nope",
+	}
+
+	var b bytes.Buffer
+	if err := RenderError(&b, input, err, FormatANSI); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !strings.Contains(b.String(), ansiRed+"^^^^^^^^"+ansiReset) {
+		t.Fatalf("bad: %q", b.String())
+	}
+}
+
+func TestRenderError_json(t *testing.T) {
+	// The offset is filled in for positions that lack it
+	input := "ä\n#{foo}"
+	err := &posError{Pos: ast.Pos{Column: 3, Line: 2}, Err: errors.New("foo")}
+
+	var b bytes.Buffer
+	if err := RenderError(&b, input, err, FormatJSON); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := `[
+  {
+    "message": "foo",
+    "start": {
+      "line": 2,
+      "column": 3,
+      "offset": 5
+    },
+    "end": {
+      "line": 2,
+      "column": 3,
+      "offset": 5
+    }
+  }
+]
+`
+	if b.String() != expected {
+		t.Fatalf("bad: %s", b.String())
+	}
+}
@@ -0,0 +1,281 @@
+package stop
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/patdhlk/stop/ast"
+)
+
+// These errors are returned (wrapped with the position at which the limit
+// was hit) when one of the resource limits configured through ParseConfig or
+// EvalConfig is exceeded. Use errors.Is to tell them apart.
+var (
+	// ErrMaxDepth is returned by ParseWithConfig when the AST is nested
+	// deeper than ParseConfig.MaxDepth.
+	ErrMaxDepth = errors.New("maximum AST depth exceeded")
+
+	// ErrMaxNodes is returned by ParseWithConfig when the AST has more
+	// nodes than ParseConfig.MaxNodes.
+	ErrMaxNodes = errors.New("maximum number of AST nodes exceeded")
+
+	// ErrMaxInterpolationDepth is returned by ParseWithConfig when
+	// interpolations are nested deeper than
+	// ParseConfig.MaxInterpolationDepth.
+	ErrMaxInterpolationDepth = errors.New("maximum interpolation depth exceeded")
+
+	// ErrMaxCalls is returned by Eval when more than EvalConfig.MaxCalls
+	// functions are called.
+	ErrMaxCalls = errors.New("maximum number of function calls exceeded")
+
+	// ErrMaxSteps is returned by Eval when more than EvalConfig.MaxSteps
+	// nodes are evaluated.
+	ErrMaxSteps = errors.New("maximum number of evaluation steps exceeded")
+
+	// ErrMaxOutputSize is returned by Eval when concatenating an output
+	// would produce a string longer than EvalConfig.MaxOutputSize bytes.
+	ErrMaxOutputSize = errors.New("maximum output size exceeded")
+)
+
+// EvalCanceledError is returned by EvalContext when the evaluation was
+// aborted because its context was canceled or its deadline was exceeded.
+// Node is the node that was about to be (or was being) evaluated at that
+// point. Err is the error reported by the context, or the error of the node
+// if it failed because of it, so errors.Is finds the context's error either
+// way.
+type EvalCanceledError struct {
+	Node ast.Node
+	Err  error
+}
+
+func (e *EvalCanceledError) Error() string {
+	return fmt.Sprintf("%s: evaluation aborted at %s: %s", e.Node.Pos(), e.Node, e.Err)
+}
+
+func (e *EvalCanceledError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is returned by Eval when a panic occurred during evaluation,
+// for example in a function callback. Node is the node that was being
+// evaluated, if known, and Func the name of the function if the node is a
+// call. Value is the value passed to panic. Stack is the stack trace of
+// the panic, which is only captured if EvalConfig.Debug is set. It isn't
+// part of the error message.
+type PanicError struct {
+	Node  ast.Node
+	Func  string
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	var msg string
+	switch {
+	case e.Func != "":
+		msg = fmt.Sprintf("panic in function %s: %v", e.Func, e.Value)
+	case e.Node != nil:
+		msg = fmt.Sprintf("panic evaluating %s: %v", e.Node, e.Value)
+	default:
+		msg = fmt.Sprintf("panic during evaluation: %v", e.Value)
+	}
+
+	if e.Node != nil {
+		msg = fmt.Sprintf("%s: %s", e.Node.Pos(), msg)
+	}
+
+	return msg
+}
+
+// Unwrap returns the panic value if it is an error, such as a
+// runtime.Error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// MultiError is returned by the semantic checks when they find more than
+// one error. Errors are in the order in which they were found.
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = "* " + err.Error()
+	}
+
+	return fmt.Sprintf("%d errors occurred:\n\t%s",
+		len(e.Errors), strings.Join(msgs, "\n\t"))
+}
+
+// Unwrap returns the errors so that errors.Is and errors.As look at each
+// of them.
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+// errorList returns nil if there are no errors, the error itself if there
+// is only one and a *MultiError otherwise.
+func errorList(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return &MultiError{Errors: errs}
+	}
+}
+
+// mergeErrors combines the given errors, any of which may be nil or a
+// *MultiError, into the result of errorList.
+func mergeErrors(errs ...error) error {
+	var result []error
+	for _, err := range errs {
+		if m, ok := err.(*MultiError); ok {
+			result = append(result, m.Errors...)
+		} else if err != nil {
+			result = append(result, err)
+		}
+	}
+
+	return errorList(result)
+}
+
+// The error types below describe failures to parse, check or evaluate a
+// program. They all record the range of the source at which the error
+// occurred, from Pos up to just before End, and include Pos in their
+// message. Use errors.As to get at them.
+
+// ParseError is returned by Parse for programs that aren't syntactically
+// valid. Related is another location involved in the error, if any.
+type ParseError struct {
+	Pos     ast.Pos
+	End     ast.Pos
+	Msg     string
+	Related *Related
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: parse error: %s", e.Pos, e.Msg)
+}
+
+// UnknownVariableError is returned when a program accesses a variable
+// that doesn't exist in the scope. Suggestions are the most similar names
+// of variables in the scope, if the scope implements ast.NameLister.
+type UnknownVariableError struct {
+	Pos         ast.Pos
+	End         ast.Pos
+	Name        string
+	Suggestions []string
+}
+
+func (e *UnknownVariableError) Error() string {
+	return fmt.Sprintf("%s: unknown variable accessed: %s%s",
+		e.Pos, e.Name, didYouMean(e.Suggestions))
+}
+
+// UnknownFunctionError is returned when a program calls a function that
+// doesn't exist in the scope. Suggestions are the most similar names of
+// functions in the scope, if the scope implements ast.NameLister.
+type UnknownFunctionError struct {
+	Pos         ast.Pos
+	End         ast.Pos
+	Name        string
+	Suggestions []string
+}
+
+func (e *UnknownFunctionError) Error() string {
+	return fmt.Sprintf("%s: unknown function called: %s%s",
+		e.Pos, e.Name, didYouMean(e.Suggestions))
+}
+
+// TypeMismatchError is returned when a value doesn't have the type it
+// needs to have, such as an argument of a function. What describes the
+// value, i.e. "lower: argument 1".
+type TypeMismatchError struct {
+	Pos      ast.Pos
+	End      ast.Pos
+	What     string
+	Expected ast.Type
+	Actual   ast.Type
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("%s: %s should be %s, got %s",
+		e.Pos, e.What, e.Expected.Printable(), e.Actual.Printable())
+}
+
+// IndexOutOfRangeError is returned when indexing a list with an index
+// beyond its length or a map with a key that doesn't exist. Key is the int
+// or string used as index and Len the number of elements of the list or
+// map.
+type IndexOutOfRangeError struct {
+	Pos  ast.Pos
+	End  ast.Pos
+	Name string
+	Key  interface{}
+	Len  int
+}
+
+func (e *IndexOutOfRangeError) Error() string {
+	if key, ok := e.Key.(string); ok {
+		return fmt.Sprintf("%s: key %q does not exist in map %s", e.Pos, key, e.Name)
+	}
+
+	return fmt.Sprintf("%s: index %v out of range for list %s (length %d)",
+		e.Pos, e.Key, e.Name, e.Len)
+}
+
+// posError is an error in a range of the source that has no type of its
+// own, such as a call with the wrong number of arguments. Like the error
+// types above, its message starts with Pos.
+type posError struct {
+	Pos ast.Pos
+	End ast.Pos
+	Err error
+}
+
+func (e *posError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Err)
+}
+
+func (e *posError) Unwrap() error {
+	return e.Err
+}
+
+// errorAt returns a posError for the range of the node n. The message is
+// formatted like with fmt.Errorf, so errors can be wrapped with %w.
+func errorAt(n ast.Node, format string, args ...interface{}) error {
+	return &posError{Pos: n.Pos(), End: ast.End(n), Err: fmt.Errorf(format, args...)}
+}
+
+// positionedError is implemented by the errors above so that the checkers
+// don't add the position to them once more and diagnostics can show the
+// range they refer to.
+type positionedError interface {
+	error
+	errorRange() (pos, end ast.Pos)
+}
+
+func (e *ParseError) errorRange() (ast.Pos, ast.Pos)           { return e.Pos, e.End }
+func (e *UnknownVariableError) errorRange() (ast.Pos, ast.Pos) { return e.Pos, e.End }
+func (e *UnknownFunctionError) errorRange() (ast.Pos, ast.Pos) { return e.Pos, e.End }
+func (e *TypeMismatchError) errorRange() (ast.Pos, ast.Pos)    { return e.Pos, e.End }
+func (e *IndexOutOfRangeError) errorRange() (ast.Pos, ast.Pos) { return e.Pos, e.End }
+func (e *posError) errorRange() (ast.Pos, ast.Pos)             { return e.Pos, e.End }
+
+func (e *EvalCanceledError) errorRange() (ast.Pos, ast.Pos) {
+	return e.Node.Pos(), ast.End(e.Node)
+}
+
+func (e *PanicError) errorRange() (ast.Pos, ast.Pos) {
+	if e.Node == nil {
+		return ast.Pos{}, ast.Pos{}
+	}
+
+	return e.Node.Pos(), ast.End(e.Node)
+}
@@ -2,8 +2,11 @@ package stop
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"sync"
+	"runtime/debug"
+	"sort"
 
 	"github.com/patdhlk/stop/ast"
 )
@@ -13,10 +16,164 @@ type EvalConfig struct {
 	// GlobalScope is the global scope of execution for evaluation.
 	GlobalScope *ast.BasicScope
 
+	// Scope is a scope of execution that can be any ast.Scope
+	// implementation, such as an *ast.ChainedScope that layers
+	// per-evaluation variables on top of a shared scope. If both are set,
+	// names are looked up in Scope first and then in GlobalScope, so that
+	// per-evaluation entries shadow the global ones.
+	Scope ast.Scope
+
 	// SemanticChecks is a list of additional semantic checks that will be run
 	// on the tree prior to evaluating it. The type checker, identifier checker,
 	// etc. will be run before these automatically.
 	SemanticChecks []SemanticChecker
+
+	// Builtins replaces individual builtin functions, such as the
+	// "__builtin_StringToInt" function used for implicit string to int
+	// conversions, with the given functions.
+	//
+	// DisableBuiltins removes the builtin functions with the given names.
+	// Implicit conversions that would use a disabled builtin are reported
+	// as type errors instead, so for example disabling the three
+	// "__builtin_StringTo*" functions forbids coercing strings to other
+	// types. Disabling "__builtin_IntMath" or "__builtin_FloatMath" makes
+	// arithmetic on the respective type fail.
+	//
+	// The builtins are looked up after GlobalScope and Scope, which are
+	// never modified.
+	Builtins        map[string]ast.Function
+	DisableBuiltins []string
+
+	// The limits below protect against untrusted programs. A value of zero
+	// means that there is no limit.
+	//
+	// MaxCalls is the maximum number of function calls an evaluation may
+	// make, including the builtin calls that arithmetic and implicit type
+	// conversions are turned into. Exceeding it returns ErrMaxCalls.
+	//
+	// MaxSteps is the maximum number of nodes an evaluation may evaluate.
+	// Exceeding it returns ErrMaxSteps.
+	//
+	// MaxOutputSize is the maximum length in bytes of a string built by
+	// concatenating the expressions of an output. Exceeding it returns
+	// ErrMaxOutputSize.
+	MaxCalls      int
+	MaxSteps      int
+	MaxOutputSize int
+
+	// StrictResults disables the conversion of values returned by
+	// function callbacks. By default, Go values of types compatible with
+	// the ReturnType of a function are converted, such as an int64 to an
+	// int or a []string to a list. With StrictResults, callbacks have to
+	// return exactly the Go type used for the ReturnType. Either way, a
+	// value that doesn't match the ReturnType is an error.
+	StrictResults bool
+
+	// Debug captures the stack trace of panics recovered during the
+	// evaluation in PanicError.Stack.
+	Debug bool
+}
+
+// scope returns the scope of execution made up of Scope and GlobalScope.
+func (c *EvalConfig) scope() ast.Scope {
+	if c.GlobalScope == nil {
+		return c.Scope
+	}
+	if c.Scope == nil {
+		return c.GlobalScope
+	}
+
+	return &overlayScope{Scope: c.Scope, Parent: c.GlobalScope}
+}
+
+// overlayScope looks up names in Scope first and falls back to Parent.
+// Unlike an ast.ChainedScope, the top layer can be any scope.
+type overlayScope struct {
+	Scope  ast.Scope
+	Parent ast.Scope
+}
+
+func (s *overlayScope) LookupFunc(n string) (ast.Function, bool) {
+	if f, ok := s.Scope.LookupFunc(n); ok {
+		return f, true
+	}
+
+	return s.Parent.LookupFunc(n)
+}
+
+func (s *overlayScope) LookupVar(n string) (ast.Variable, bool) {
+	if v, ok := s.Scope.LookupVar(n); ok {
+		return v, true
+	}
+
+	return s.Parent.LookupVar(n)
+}
+
+func (s *overlayScope) HasVar(n string) bool {
+	return ast.HasVar(s.Scope, n) || ast.HasVar(s.Parent, n)
+}
+
+func (s *overlayScope) VarNames() []string {
+	return mergeNames(ast.VarNames(s.Scope), ast.VarNames(s.Parent))
+}
+
+func (s *overlayScope) FuncNames() []string {
+	return mergeNames(ast.FuncNames(s.Scope), ast.FuncNames(s.Parent))
+}
+
+// cachedScope caches the variables looked up in a scope. A new one is
+// created for every evaluation, so it isn't safe for concurrent use.
+type cachedScope struct {
+	ast.Scope
+	vars map[string]ast.Variable
+}
+
+func newCachedScope(s ast.Scope) *cachedScope {
+	return &cachedScope{Scope: s, vars: make(map[string]ast.Variable)}
+}
+
+func (s *cachedScope) LookupVar(n string) (ast.Variable, bool) {
+	if v, ok := s.vars[n]; ok {
+		return v, true
+	}
+
+	v, ok := s.Scope.LookupVar(n)
+	if ok {
+		s.vars[n] = v
+	}
+
+	return v, ok
+}
+
+// HasVar looks the variable up, since the type check needs its value
+// right after the identifier check anyway, and this way it is resolved
+// only once.
+func (s *cachedScope) HasVar(n string) bool {
+	_, ok := s.LookupVar(n)
+	return ok
+}
+
+func (s *cachedScope) VarNames() []string {
+	return ast.VarNames(s.Scope)
+}
+
+func (s *cachedScope) FuncNames() []string {
+	return ast.FuncNames(s.Scope)
+}
+
+// mergeNames returns the sorted union of two lists of names.
+func mergeNames(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var names []string
+	for _, n := range append(a, b...) {
+		if !seen[n] {
+			seen[n] = true
+			names = append(names, n)
+		}
+	}
+
+	sort.Strings(names)
+	return names
 }
 
 // SemanticChecker is the type that must be implemented to do a
@@ -43,13 +200,23 @@ type EvaluationResult struct {
 // The error is described out of band in the accompanying error return value.
 var UnsupportedResult = EvaluationResult{Type: TUnsupported, Value: nil}
 
+// Eval evaluates the given AST tree with the given configuration. It is
+// equivalent to calling EvalContext with context.Background().
 func Eval(root ast.Node, config *EvalConfig) (EvaluationResult, error) {
-	output, outputType, err := internalEval(root, config)
+	return EvalContext(context.Background(), root, config)
+}
+
+// EvalContext evaluates the given AST tree with the given configuration.
+// The context is checked between the evaluation of every node and is passed
+// to functions that set a ContextCallback. If the context is done before
+// the evaluation completes, an *EvalCanceledError is returned.
+func EvalContext(ctx context.Context, root ast.Node, config *EvalConfig) (EvaluationResult, error) {
+	output, outputType, err := internalEvalContext(ctx, root, config)
 	if err != nil {
 		return UnsupportedResult, err
 	}
 
-	switch outputType {
+	switch outputType.Kind() {
 	case ast.TList:
 		val, err := VariableToInterface(ast.Variable{
 			Type:  ast.TList,
@@ -83,14 +250,31 @@ func Eval(root ast.Node, config *EvalConfig) (EvaluationResult, error) {
 	}
 }
 
-// Eval evaluates the given AST tree and returns its output value, the type
-// of the output, and any error that occurred.
+// internalEval evaluates the given AST tree and returns its output value,
+// the type of the output, and any error that occurred.
 func internalEval(root ast.Node, config *EvalConfig) (interface{}, ast.Type, error) {
-	// Copy the scope so we can add our builtins
+	return internalEvalContext(context.Background(), root, config)
+}
+
+func internalEvalContext(ctx context.Context, root ast.Node, config *EvalConfig) (_ interface{}, _ ast.Type, err error) {
+	// Layer our builtins below the global scope
 	if config == nil {
 		config = new(EvalConfig)
 	}
-	scope := registerBuiltins(config.GlobalScope)
+
+	// Panics in nodes are recovered by the evalVisitor, this catches the
+	// ones in semantic checks and elsewhere.
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(nil, r, config.Debug)
+		}
+	}()
+
+	// Variables are cached for this evaluation only, so that the checks
+	// and the evaluation see the same values even if the scope resolves
+	// them anew on every lookup, such as an ast.ResolverScope
+	scope := newCachedScope(registerBuiltins(
+		config.scope(), config.Builtins, config.DisableBuiltins))
 	implicitMap := map[ast.Type]map[ast.Type]string{
 		ast.TFloat: {
 			ast.TInt:    "__builtin_FloatToInt",
@@ -114,24 +298,32 @@ func internalEval(root ast.Node, config *EvalConfig) (interface{}, ast.Type, err
 	tv := &TypeCheck{Scope: scope, Implicit: implicitMap}
 	ic := &IdentifierCheck{Scope: scope}
 
-	// Build up the semantic checks for execution
-	checks := make(
-		[]SemanticChecker,
-		len(config.SemanticChecks),
-		len(config.SemanticChecks)+2)
-	copy(checks, config.SemanticChecks)
-	checks = append(checks, ic.Visit)
-	checks = append(checks, tv.Visit)
-
 	// Run the semantic checks
-	for _, check := range checks {
+	for _, check := range config.SemanticChecks {
 		if err := check(root); err != nil {
 			return nil, ast.TUnsupported, err
 		}
 	}
 
+	// The identifier and type checks both run so that all of their errors
+	// are reported at once. Nodes that fail the identifier check, and the
+	// ones depending on them, aren't type checked.
+	failed, identErr := ic.check(root)
+	tv.failed = failed
+	if err := mergeErrors(identErr, tv.Visit(root)); err != nil {
+		return nil, ast.TUnsupported, err
+	}
+
 	// Execute
-	v := &evalVisitor{Scope: scope}
+	v := &evalVisitor{
+		Context:       ctx,
+		Scope:         scope,
+		MaxCalls:      config.MaxCalls,
+		MaxSteps:      config.MaxSteps,
+		MaxOutputSize: config.MaxOutputSize,
+		StrictResults: config.StrictResults,
+		Debug:         config.Debug,
+	}
 	return v.Visit(root)
 }
 
@@ -145,11 +337,19 @@ type EvalNode interface {
 }
 
 type evalVisitor struct {
-	Scope ast.Scope
-	Stack ast.Stack
-
-	err  error
-	lock sync.Mutex
+	Context context.Context
+	Scope   ast.Scope
+	Stack   ast.Stack
+
+	MaxCalls      int
+	MaxSteps      int
+	MaxOutputSize int
+	StrictResults bool
+	Debug         bool
+
+	calls int
+	steps int
+	err   error
 }
 
 func (v *evalVisitor) Visit(root ast.Node) (interface{}, ast.Type, error) {
@@ -167,6 +367,8 @@ func (v *evalVisitor) Visit(root ast.Node) (interface{}, ast.Type, error) {
 
 	// Clear everything else so we aren't just dangling
 	v.Stack.Reset()
+	v.calls = 0
+	v.steps = 0
 	v.err = nil
 
 	t, err := result.Type(v.Scope)
@@ -182,14 +384,32 @@ func (v *evalVisitor) visit(raw ast.Node) ast.Node {
 		return raw
 	}
 
-	en, err := evalNode(raw)
+	// Stop as soon as the context is done, before touching the next node.
+	if err := v.Context.Err(); err != nil {
+		v.err = &EvalCanceledError{Node: raw, Err: err}
+		return raw
+	}
+
+	if err := v.checkLimits(raw); err != nil {
+		v.err = err
+		return raw
+	}
+
+	en, err := v.evalNode(raw)
 	if err != nil {
 		v.err = err
 		return raw
 	}
 
-	out, outType, err := en.Eval(v.Scope, &v.Stack)
+	out, outType, err := v.eval(raw, en)
 	if err != nil {
+		// If the node failed because the context finished while it was
+		// evaluating, report it as such. Other errors are kept as they are
+		// even if the context happens to be done by now.
+		if ctxErr := v.Context.Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+			err = &EvalCanceledError{Node: raw, Err: err}
+		}
+
 		v.err = err
 		return raw
 	}
@@ -197,20 +417,66 @@ func (v *evalVisitor) visit(raw ast.Node) ast.Node {
 	v.Stack.Push(&ast.LiteralNode{
 		Value: out,
 		Typex: outType,
+		Posx:  raw.Pos(),
+		Endx:  ast.End(raw),
 	})
 	return raw
 }
 
-// evalNode is a private function that returns an EvalNode for built-in
-// types as well as any other EvalNode implementations.
-func evalNode(raw ast.Node) (EvalNode, error) {
+// eval evaluates a single node, turning a panic while doing so into a
+// *PanicError.
+func (v *evalVisitor) eval(raw ast.Node, en EvalNode) (out interface{}, outType ast.Type, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(raw, r, v.Debug)
+		}
+	}()
+
+	return en.Eval(v.Scope, &v.Stack)
+}
+
+// newPanicError creates the error for a panic recovered while evaluating
+// the given node, which may be nil if it isn't known.
+func newPanicError(n ast.Node, r interface{}, withStack bool) *PanicError {
+	err := &PanicError{Node: n, Value: r}
+	if c, ok := n.(*ast.Call); ok {
+		err.Func = c.Func
+	}
+	if withStack {
+		err.Stack = debug.Stack()
+	}
+
+	return err
+}
+
+// checkLimits counts the given node against the step and call limits of
+// the evaluation and returns an error if one of them is exceeded.
+func (v *evalVisitor) checkLimits(raw ast.Node) error {
+	v.steps++
+	if v.MaxSteps > 0 && v.steps > v.MaxSteps {
+		return errorAt(raw, "%w (limit %d)", ErrMaxSteps, v.MaxSteps)
+	}
+
+	if _, ok := raw.(*ast.Call); ok {
+		v.calls++
+		if v.MaxCalls > 0 && v.calls > v.MaxCalls {
+			return errorAt(raw, "%w (limit %d)", ErrMaxCalls, v.MaxCalls)
+		}
+	}
+
+	return nil
+}
+
+// evalNode returns an EvalNode for built-in types as well as any other
+// EvalNode implementations.
+func (v *evalVisitor) evalNode(raw ast.Node) (EvalNode, error) {
 	switch n := raw.(type) {
 	case *ast.Index:
 		return &evalIndex{n}, nil
 	case *ast.Call:
-		return &evalCall{n}, nil
+		return &evalCall{n, v.Context, v.StrictResults}, nil
 	case *ast.Output:
-		return &evalOutput{n}, nil
+		return &evalOutput{n, v.MaxOutputSize}, nil
 	case *ast.LiteralNode:
 		return &evalLiteralNode{n}, nil
 	case *ast.VariableAccess:
@@ -225,68 +491,119 @@ func evalNode(raw ast.Node) (EvalNode, error) {
 	}
 }
 
-type evalCall struct{ *ast.Call }
+type evalCall struct {
+	*ast.Call
+	ctx    context.Context
+	strict bool
+}
 
 func (v *evalCall) Eval(s ast.Scope, stack *ast.Stack) (interface{}, ast.Type, error) {
 	// Look up the function in the map
 	function, ok := s.LookupFunc(v.Func)
 	if !ok {
-		return nil, ast.TUnsupported, fmt.Errorf(
-			"unknown function called: %s", v.Func)
+		return nil, ast.TUnsupported, unknownFunction(s, v.Call, v.Func)
+	}
+
+	// Named arguments are bound to positional ones by the type checker
+	if len(v.NamedArgs) > 0 {
+		return nil, ast.TUnsupported, errorAt(v,
+			"%s: named arguments must be bound before evaluation", v.Func)
 	}
 
 	// The arguments are on the stack in reverse order, so pop them off.
 	args := make([]interface{}, len(v.Args))
+	types := make([]ast.Type, len(v.Args))
 	for i, _ := range v.Args {
 		node := stack.Pop().(*ast.LiteralNode)
 		args[len(v.Args)-1-i] = node.Value
+		types[len(v.Args)-1-i] = node.Typex
 	}
 
-	// Call the function
-	result, err := function.Callback(args)
-	if err != nil {
-		return nil, ast.TUnsupported, fmt.Errorf("%s: %s", v.Func, err)
+	// Resolve overloads with the types of the actual values
+	if len(function.Overloads) > 0 {
+		function, ok = function.Resolve(types)
+		if !ok {
+			return nil, ast.TUnsupported, errorAt(v,
+				"%s: no signature matches the arguments", v.Func)
+		}
 	}
 
-	return result, function.ReturnType, nil
-}
-
-type evalIndex struct{ *ast.Index }
-
-func (v *evalIndex) Eval(scope ast.Scope, stack *ast.Stack) (interface{}, ast.Type, error) {
-	evalVarAccess, err := evalNode(v.Target)
+	returnType, err := function.ResultType(types)
 	if err != nil {
-		return nil, ast.TUnsupported, err
+		return nil, ast.TUnsupported, errorAt(v, "%s: %w", v.Func, err)
 	}
-	target, targetType, err := evalVarAccess.Eval(scope, stack)
 
-	evalKey, err := evalNode(v.Key)
+	// Call the function
+	var result interface{}
+	if function.ContextCallback != nil {
+		result, err = function.ContextCallback(v.ctx, args)
+	} else {
+		result, err = function.Callback(args)
+	}
 	if err != nil {
-		return nil, ast.TUnsupported, err
+		return nil, ast.TUnsupported, errorAt(v, "%s: %w", v.Func, err)
 	}
 
-	key, keyType, err := evalKey.Eval(scope, stack)
+	// Don't trust the callback to return what it says it does, evaluating
+	// the nodes using its result would panic otherwise.
+	result, returnType, err = checkResult(result, returnType, v.strict)
 	if err != nil {
-		return nil, ast.TUnsupported, err
+		if tme, ok := err.(*TypeMismatchError); ok {
+			tme.Pos, tme.End = v.Pos(), v.End()
+			tme.What = fmt.Sprintf("%s: %s", v.Func, tme.What)
+			return nil, ast.TUnsupported, tme
+		}
+
+		return nil, ast.TUnsupported, errorAt(v, "%s: %w", v.Func, err)
 	}
 
-	variableName := v.Index.Target.(*ast.VariableAccess).Name
+	return result, returnType, nil
+}
+
+type evalIndex struct {
+	*ast.Index
+}
+
+func (v *evalIndex) Eval(scope ast.Scope, stack *ast.Stack) (interface{}, ast.Type, error) {
+	// The target and key are on the stack in reverse order
+	keyNode := stack.Pop().(*ast.LiteralNode)
+	targetNode := stack.Pop().(*ast.LiteralNode)
+	key, keyType := keyNode.Value, keyNode.Typex
+	target, targetType := targetNode.Value, targetNode.Typex
+
+	variableAccess, ok := v.Target.(*ast.VariableAccess)
+	if !ok {
+		return nil, ast.TUnsupported, errorAt(v, "target of an index must be a variable, is %T", v.Target)
+	}
+	variableName := variableAccess.Name
 
-	switch targetType {
+	switch targetType.Kind() {
 	case ast.TList:
 		if keyType != ast.TInt {
-			return nil, ast.TUnsupported, fmt.Errorf("key for indexing list %q must be an int, is %s", variableName, keyType)
+			return nil, ast.TUnsupported, &TypeMismatchError{
+				Pos:      v.Key.Pos(),
+				End:      ast.End(v.Key),
+				What:     fmt.Sprintf("key for indexing %s", variableName),
+				Expected: ast.TInt,
+				Actual:   keyType,
+			}
 		}
 
 		return v.evalListIndex(variableName, target, key)
 	case ast.TMap:
 		if keyType != ast.TString {
-			return nil, ast.TUnsupported, fmt.Errorf("key for indexing map %q must be a string, is %s", variableName, keyType)
+			return nil, ast.TUnsupported, &TypeMismatchError{
+				Pos:      v.Key.Pos(),
+				End:      ast.End(v.Key),
+				What:     fmt.Sprintf("key for indexing %s", variableName),
+				Expected: ast.TString,
+				Actual:   keyType,
+			}
 		}
 
 		return v.evalMapIndex(variableName, target, key)
 	default:
-		return nil, ast.TUnsupported, fmt.Errorf("target %q for indexing must be ast.TList or ast.TMap, is %s", variableName, targetType)
+		return nil, ast.TUnsupported, errorAt(v, "target %q for indexing must be ast.TList or ast.TMap, is %s", variableName, targetType)
 	}
 }
 
@@ -295,20 +612,22 @@ func (v *evalIndex) evalListIndex(variableName string, target interface{}, key i
 	// is a list and key is an int
 	list, ok := target.([]ast.Variable)
 	if !ok {
-		return nil, ast.TUnsupported, fmt.Errorf("cannot cast target to []Variable")
+		return nil, ast.TUnsupported, errorAt(v, "cannot cast target to []Variable")
 	}
 
 	keyInt, ok := key.(int)
 	if !ok {
-		return nil, ast.TUnsupported, fmt.Errorf("cannot cast key to int")
-	}
-
-	if len(list) == 0 {
-		return nil, ast.TUnsupported, fmt.Errorf("list is empty")
+		return nil, ast.TUnsupported, errorAt(v, "cannot cast key to int")
 	}
 
 	if keyInt < 0 || len(list) < keyInt+1 {
-		return nil, ast.TUnsupported, fmt.Errorf("index %d out of range for list %s (max %d)", keyInt, variableName, len(list))
+		return nil, ast.TUnsupported, &IndexOutOfRangeError{
+			Pos:  v.Pos(),
+			End:  v.End(),
+			Name: variableName,
+			Key:  keyInt,
+			Len:  len(list),
+		}
 	}
 
 	returnVal := list[keyInt].Value
@@ -322,27 +641,32 @@ func (v *evalIndex) evalMapIndex(variableName string, target interface{}, key in
 	// is a map and key is a string
 	vmap, ok := target.(map[string]ast.Variable)
 	if !ok {
-		return nil, ast.TUnsupported, fmt.Errorf("cannot cast target to map[string]Variable")
+		return nil, ast.TUnsupported, errorAt(v, "cannot cast target to map[string]Variable")
 	}
 
 	keyString, ok := key.(string)
 	if !ok {
-		return nil, ast.TUnsupported, fmt.Errorf("cannot cast key to string")
-	}
-
-	if len(vmap) == 0 {
-		return nil, ast.TUnsupported, fmt.Errorf("map is empty")
+		return nil, ast.TUnsupported, errorAt(v, "cannot cast key to string")
 	}
 
 	value, ok := vmap[keyString]
 	if !ok {
-		return nil, ast.TUnsupported, fmt.Errorf("key %q does not exist in map %s", keyString, variableName)
+		return nil, ast.TUnsupported, &IndexOutOfRangeError{
+			Pos:  v.Pos(),
+			End:  v.End(),
+			Name: variableName,
+			Key:  keyString,
+			Len:  len(vmap),
+		}
 	}
 
 	return value.Value, value.Type, nil
 }
 
-type evalOutput struct{ *ast.Output }
+type evalOutput struct {
+	*ast.Output
+	maxSize int
+}
 
 func (v *evalOutput) Eval(s ast.Scope, stack *ast.Stack) (interface{}, ast.Type, error) {
 	// The expressions should all be on the stack in reverse
@@ -353,15 +677,26 @@ func (v *evalOutput) Eval(s ast.Scope, stack *ast.Stack) (interface{}, ast.Type,
 	}
 
 	// Special case the single list and map
-	if len(nodes) == 1 && nodes[0].Typex == ast.TList {
-		return nodes[0].Value, ast.TList, nil
+	if len(nodes) == 1 && nodes[0].Typex.Kind() == ast.TList {
+		return nodes[0].Value, nodes[0].Typex, nil
+	}
+	if len(nodes) == 1 && nodes[0].Typex.Kind() == ast.TMap {
+		return nodes[0].Value, nodes[0].Typex, nil
+	}
+
+	// Otherwise concatenate the strings, making sure the result will fit
+	// within our size limit before building it.
+	size := 0
+	for _, n := range nodes {
+		size += len(n.Value.(string))
 	}
-	if len(nodes) == 1 && nodes[0].Typex == ast.TMap {
-		return nodes[0].Value, ast.TMap, nil
+	if v.maxSize > 0 && size > v.maxSize {
+		return nil, ast.TUnsupported, errorAt(v,
+			"%w (%d bytes, limit %d)", ErrMaxOutputSize, size, v.maxSize)
 	}
 
-	// Otherwise concatenate the strings
 	var buf bytes.Buffer
+	buf.Grow(size)
 	for i := len(nodes) - 1; i >= 0; i-- {
 		buf.WriteString(nodes[i].Value.(string))
 	}
@@ -381,8 +716,7 @@ func (v *evalVariableAccess) Eval(scope ast.Scope, _ *ast.Stack) (interface{}, a
 	// Look up the variable in the map
 	variable, ok := scope.LookupVar(v.Name)
 	if !ok {
-		return nil, ast.TUnsupported, fmt.Errorf(
-			"unknown variable accessed: %s", v.Name)
+		return nil, ast.TUnsupported, unknownVariable(scope, v.VariableAccess, v.Name)
 	}
 
 	return variable.Value, variable.Type, nil
@@ -1,9 +1,16 @@
 package stop
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/patdhlk/stop/ast"
 )
@@ -103,6 +110,61 @@ func TestEval(t *testing.T) {
 			"World Hello",
 			TString,
 		},
+		{
+			"#{var.alist[var.index - 1]}",
+			&ast.BasicScope{
+				VarMap: map[string]ast.Variable{
+					"var.alist": ast.Variable{
+						Type: ast.TList,
+						Value: []ast.Variable{
+							ast.Variable{
+								Type:  ast.TString,
+								Value: "Hello",
+							},
+							ast.Variable{
+								Type:  ast.TString,
+								Value: "World",
+							},
+						},
+					},
+					"var.index": ast.Variable{
+						Type:  ast.TInt,
+						Value: 2,
+					},
+				},
+			},
+			false,
+			"World",
+			TString,
+		},
+		{
+			`#{var.amap[lower("FOO")]}`,
+			&ast.BasicScope{
+				VarMap: map[string]ast.Variable{
+					"var.amap": ast.Variable{
+						Type: ast.TMap,
+						Value: map[string]ast.Variable{
+							"foo": ast.Variable{
+								Type:  ast.TString,
+								Value: "bar",
+							},
+						},
+					},
+				},
+				FuncMap: map[string]ast.Function{
+					"lower": ast.Function{
+						ArgTypes:   []ast.Type{ast.TString},
+						ReturnType: ast.TString,
+						Callback: func(args []interface{}) (interface{}, error) {
+							return strings.ToLower(args[0].(string)), nil
+						},
+					},
+				},
+			},
+			false,
+			"bar",
+			TString,
+		},
 		{
 			"#{var.alist} #{var.alist}",
 			&ast.BasicScope{
@@ -309,6 +371,26 @@ func TestEval(t *testing.T) {
 			},
 			TList,
 		},
+
+		{
+			"#{var.alist}",
+			&ast.BasicScope{
+				VarMap: map[string]ast.Variable{
+					"var.alist": ast.Variable{
+						Type: ast.List(ast.TInt),
+						Value: []ast.Variable{
+							ast.Variable{
+								Type:  ast.TInt,
+								Value: 1,
+							},
+						},
+					},
+				},
+			},
+			false,
+			[]interface{}{1},
+			TList,
+		},
 	}
 
 	for _, tc := range cases {
@@ -330,6 +412,427 @@ func TestEval(t *testing.T) {
 	}
 }
 
+func TestEvalContext_canceled(t *testing.T) {
+	node, err := Parse("foo #{bar}")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = EvalContext(ctx, node, &EvalConfig{
+		GlobalScope: &ast.BasicScope{
+			VarMap: map[string]ast.Variable{
+				"bar": ast.Variable{
+					Type:  ast.TString,
+					Value: "baz",
+				},
+			},
+		},
+	})
+
+	var cerr *EvalCanceledError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("bad err: %#v", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("bad err: %s", err)
+	}
+	if cerr.Node == nil {
+		t.Fatal("should name the node")
+	}
+}
+
+func TestEvalContext_callback(t *testing.T) {
+	node, err := Parse("foo #{slow()}")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = EvalContext(ctx, node, &EvalConfig{
+		GlobalScope: &ast.BasicScope{
+			FuncMap: map[string]ast.Function{
+				"slow": ast.Function{
+					ReturnType: ast.TString,
+					ContextCallback: func(ctx context.Context, args []interface{}) (interface{}, error) {
+						<-ctx.Done()
+						return nil, ctx.Err()
+					},
+				},
+			},
+		},
+	})
+
+	var cerr *EvalCanceledError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("bad err: %#v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("bad err: %s", err)
+	}
+	if c, ok := cerr.Node.(*ast.Call); !ok || c.Func != "slow" {
+		t.Fatalf("bad node: %#v", cerr.Node)
+	}
+}
+
+func TestEvalContext_callbackError(t *testing.T) {
+	node, err := Parse("foo #{fail()}")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The context is done when the callback returns, but the callback
+	// failed for another reason.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	expected := errors.New("boom")
+
+	_, err = EvalContext(ctx, node, &EvalConfig{
+		GlobalScope: &ast.BasicScope{
+			FuncMap: map[string]ast.Function{
+				"fail": ast.Function{
+					ReturnType: ast.TString,
+					Callback: func(args []interface{}) (interface{}, error) {
+						cancel()
+						return nil, expected
+					},
+				},
+			},
+		},
+	})
+
+	var cerr *EvalCanceledError
+	if errors.As(err, &cerr) {
+		t.Fatalf("bad err: %#v", err)
+	}
+	if !errors.Is(err, expected) {
+		t.Fatalf("bad err: %s", err)
+	}
+}
+
+func TestEval_limits(t *testing.T) {
+	scope := &ast.BasicScope{
+		FuncMap: map[string]ast.Function{
+			"rep": ast.Function{
+				ArgTypes:   []ast.Type{ast.TString, ast.TInt},
+				ReturnType: ast.TString,
+				Callback: func(args []interface{}) (interface{}, error) {
+					return strings.Repeat(args[0].(string), args[1].(int)), nil
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		Input  string
+		Config *EvalConfig
+		Error  error
+	}{
+		{
+			`#{rep("a", 2)}#{rep("b", 2)}`,
+			&EvalConfig{GlobalScope: scope, MaxCalls: 2},
+			nil,
+		},
+
+		{
+			`#{rep("a", 2)}#{rep("b", 2)}`,
+			&EvalConfig{GlobalScope: scope, MaxCalls: 1},
+			ErrMaxCalls,
+		},
+
+		{
+			`#{rep("a", 2)}#{rep("b", 2)}`,
+			&EvalConfig{GlobalScope: scope, MaxSteps: 7},
+			nil,
+		},
+
+		{
+			`#{rep("a", 2)}#{rep("b", 2)}`,
+			&EvalConfig{GlobalScope: scope, MaxSteps: 6},
+			ErrMaxSteps,
+		},
+
+		{
+			`#{rep("a", 2)}#{rep("b", 2)}`,
+			&EvalConfig{GlobalScope: scope, MaxOutputSize: 4},
+			nil,
+		},
+
+		{
+			`#{rep("a", 2)}#{rep("b", 2)}`,
+			&EvalConfig{GlobalScope: scope, MaxOutputSize: 3},
+			ErrMaxOutputSize,
+		},
+	}
+
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+
+		_, err = Eval(node, tc.Config)
+		if tc.Error == nil && err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+		if tc.Error != nil && !errors.Is(err, tc.Error) {
+			t.Fatalf("Bad error: %v\nExpected: %s\n\nInput: %s", err, tc.Error, tc.Input)
+		}
+
+		// The errors have the range of the node that exceeded the limit
+		if tc.Error != nil {
+			diags := Diagnostics(err)
+			if len(diags) != 1 || diags[0].Pos.Line != 1 || diags[0].End.Line != 1 ||
+				!strings.HasPrefix(diags[0].Message, tc.Error.Error()) {
+				t.Fatalf("Bad diagnostics: %#v\n\nInput: %s", diags, tc.Input)
+			}
+		}
+	}
+}
+
+func TestEval_chainedScope(t *testing.T) {
+	global := &ast.BasicScope{
+		VarMap: map[string]ast.Variable{
+			"var.name": ast.Variable{
+				Type:  ast.TString,
+				Value: "global",
+			},
+			"var.env": ast.Variable{
+				Type:  ast.TString,
+				Value: "prod",
+			},
+		},
+	}
+
+	node, err := Parse("#{var.name}-#{var.env}")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	scope := ast.WithVariables(global, map[string]ast.Variable{
+		"var.name": ast.Variable{
+			Type:  ast.TString,
+			Value: "request",
+		},
+	})
+	result, err := Eval(node, &EvalConfig{Scope: scope})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.Value != "request-prod" {
+		t.Fatalf("bad: %#v", result.Value)
+	}
+
+	if len(global.VarMap) != 2 || global.FuncMap != nil {
+		t.Fatalf("global scope should not be modified: %#v", global)
+	}
+}
+
+func TestEval_scopeAndGlobalScope(t *testing.T) {
+	global := &ast.BasicScope{
+		VarMap: map[string]ast.Variable{
+			"var.name": ast.Variable{
+				Type:  ast.TString,
+				Value: "global",
+			},
+			"var.env": ast.Variable{
+				Type:  ast.TString,
+				Value: "prod",
+			},
+		},
+	}
+	scope := ast.WithVariables(nil, map[string]ast.Variable{
+		"var.name": ast.Variable{
+			Type:  ast.TString,
+			Value: "scope",
+		},
+	})
+
+	node, err := Parse("#{var.name}-#{var.env}")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Scope shadows GlobalScope, which has the names Scope lacks
+	result, err := Eval(node, &EvalConfig{GlobalScope: global, Scope: scope})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.Value != "scope-prod" {
+		t.Fatalf("bad: %#v", result.Value)
+	}
+}
+
+func TestEval_resolverCachePerEval(t *testing.T) {
+	calls := 0
+	resolver := &ast.ResolverScope{
+		Resolvers: map[string]ast.Resolver{
+			"env.": ast.ResolverFunc(func(n string) (ast.Variable, bool) {
+				calls++
+				return ast.Variable{Type: ast.TInt, Value: calls}, true
+			}),
+		},
+	}
+	scope := ast.WithVariables(resolver, nil)
+
+	node, err := Parse("#{env.N}-#{env.N}")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, expected := range []string{"1-1", "2-2"} {
+		result, err := Eval(node, &EvalConfig{Scope: scope})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if result.Value != expected {
+			t.Fatalf("bad: %#v, expected %#v", result.Value, expected)
+		}
+	}
+}
+
+func TestEval_resolverConcurrent(t *testing.T) {
+	// Every lookup gets a value of a different type than the one before,
+	// so evaluations would fail if their checks and their evaluation saw
+	// different lookups
+	var calls int64
+	resolver := &ast.ResolverScope{
+		Resolvers: map[string]ast.Resolver{
+			"env.": ast.ResolverFunc(func(n string) (ast.Variable, bool) {
+				if atomic.AddInt64(&calls, 1)%2 == 0 {
+					return ast.Variable{Type: ast.TInt, Value: 1}, true
+				}
+
+				return ast.Variable{Type: ast.TString, Value: "a"}, true
+			}),
+		},
+	}
+
+	// The type check changes the tree, so every evaluation gets its own
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				node, err := Parse("#{env.x}#{env.x}")
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				result, err := Eval(node, &EvalConfig{Scope: resolver})
+				if err == nil && result.Value != "11" && result.Value != "aa" {
+					err = fmt.Errorf("bad: %#v", result.Value)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestEval_builtinsDoNotModifyScope(t *testing.T) {
+	scope := &ast.BasicScope{
+		VarMap: map[string]ast.Variable{
+			"var.foo": ast.Variable{
+				Type:  ast.TInt,
+				Value: 42,
+			},
+		},
+		FuncMap: map[string]ast.Function{},
+	}
+
+	node, err := Parse("#{var.foo + 1}")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	result, err := Eval(node, &EvalConfig{GlobalScope: scope})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.Value != "43" {
+		t.Fatalf("bad: %#v", result.Value)
+	}
+	if len(scope.FuncMap) != 0 {
+		t.Fatalf("scope should not be modified: %#v", scope.FuncMap)
+	}
+}
+
+func TestEval_builtins(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Builtins map[string]ast.Function
+		Disable  []string
+		Error    bool
+		Result   interface{}
+	}{
+		{
+			`#{"1" + 1}`,
+			nil,
+			nil,
+			false,
+			"2",
+		},
+
+		{
+			`#{"1" + 1}`,
+			nil,
+			[]string{"__builtin_StringToInt"},
+			true,
+			nil,
+		},
+
+		{
+			`#{1 + 1}`,
+			map[string]ast.Function{
+				"__builtin_IntToString": ast.Function{
+					ArgTypes:   []ast.Type{ast.TInt},
+					ReturnType: ast.TString,
+					Callback: func(args []interface{}) (interface{}, error) {
+						return fmt.Sprintf("<%d>", args[0].(int)), nil
+					},
+				},
+			},
+			nil,
+			false,
+			"<2>",
+		},
+	}
+
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+
+		result, err := Eval(node, &EvalConfig{
+			Builtins:        tc.Builtins,
+			DisableBuiltins: tc.Disable,
+		})
+		if err != nil != tc.Error {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+		if !reflect.DeepEqual(result.Value, tc.Result) {
+			t.Fatalf("Bad: %#v\n\nInput: %s", result.Value, tc.Input)
+		}
+	}
+}
+
 func TestEvalInternal(t *testing.T) {
 	cases := []struct {
 		Input      string
@@ -1181,3 +1684,423 @@ func TestEvalInternal(t *testing.T) {
 		}
 	}
 }
+
+func TestEval_overloads(t *testing.T) {
+	scope := &ast.BasicScope{
+		FuncMap: map[string]ast.Function{
+			"max": ast.Function{
+				Overloads: []ast.Function{
+					{
+						ArgTypes:     []ast.Type{ast.TInt},
+						Variadic:     true,
+						VariadicType: ast.TInt,
+						ReturnType:   ast.TInt,
+						Callback: func(args []interface{}) (interface{}, error) {
+							max := args[0].(int)
+							for _, a := range args[1:] {
+								if a.(int) > max {
+									max = a.(int)
+								}
+							}
+							return max, nil
+						},
+					},
+					{
+						ArgTypes:     []ast.Type{ast.TFloat},
+						Variadic:     true,
+						VariadicType: ast.TFloat,
+						ReturnType:   ast.TFloat,
+						Callback: func(args []interface{}) (interface{}, error) {
+							max := args[0].(float64)
+							for _, a := range args[1:] {
+								if a.(float64) > max {
+									max = a.(float64)
+								}
+							}
+							return max, nil
+						},
+					},
+				},
+			},
+			"identity": ast.Function{
+				ArgTypes: []ast.Type{ast.Union(ast.TInt, ast.TFloat)},
+				ReturnTypeFunc: func(args []ast.Type) (ast.Type, error) {
+					return args[0], nil
+				},
+				Callback: func(args []interface{}) (interface{}, error) {
+					return args[0], nil
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		Input  string
+		Result interface{}
+	}{
+		{`#{max(1, 3, 2)}`, "3"},
+		{`#{max(1.5, 0.5)}`, "1.5"},
+		{`#{identity(42)}`, "42"},
+		{`#{identity(max(1.5, 2.5))}`, "2.5"},
+		{`#{identity(max(1, 2)) + 1}`, "3"},
+		{`#{max(1, 2.5)}`, "2.5"},
+		{`#{max(2.5, 3)}`, "3"},
+	}
+
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+
+		out, outType, err := internalEval(node, &EvalConfig{GlobalScope: scope})
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+		if out != tc.Result || outType != ast.TString {
+			t.Fatalf("Bad: %#v (%s)\n\nInput: %s", out, outType, tc.Input)
+		}
+	}
+}
+
+func TestEval_namedArgs(t *testing.T) {
+	scope := &ast.BasicScope{
+		FuncMap: map[string]ast.Function{
+			"format": ast.Function{
+				ArgTypes:   []ast.Type{ast.TString, ast.TInt, ast.TString},
+				ArgNames:   []string{"value", "width", "pad"},
+				ReturnType: ast.TString,
+				Defaults: []ast.Variable{
+					{Type: ast.TInt, Value: 0},
+					{Type: ast.TString, Value: " "},
+				},
+				Callback: func(args []interface{}) (interface{}, error) {
+					value := args[0].(string)
+					for len(value) < args[1].(int) {
+						value = args[2].(string) + value
+					}
+					return value, nil
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		Input  string
+		Result interface{}
+		Error  string
+	}{
+		{`#{format("a")}`, "a", ""},
+		{`#{format("a", 3)}`, "  a", ""},
+		{`#{format("a", width = 3)}`, "  a", ""},
+		{`#{format("a", pad = "0", width = 3)}`, "00a", ""},
+		{`#{format(width = 2, value = "a")}`, " a", ""},
+		{`#{format("a", width = "3")}`, "  a", ""},
+		{`#{format(width = 3)}`, nil, `missing required argument "value"`},
+		{`#{format("a", size = 3)}`, nil, `unknown argument "size"`},
+		{`#{format("a", 3, width = 3)}`, nil, `argument "width" is given more than once`},
+		{`#{format("a", width = 3, width = 4)}`, nil, `argument "width" is given more than once`},
+		{`#{format("a", 1, "0", 2)}`, nil, "expected at most 3 arguments, got 4"},
+	}
+
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+
+		result, err := Eval(node, &EvalConfig{GlobalScope: scope})
+		if (err != nil) != (tc.Error != "") {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+		if err != nil {
+			if !strings.Contains(err.Error(), tc.Error) {
+				t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+			}
+			continue
+		}
+		if result.Value != tc.Result {
+			t.Fatalf("Bad: %#v\n\nInput: %s", result.Value, tc.Input)
+		}
+	}
+}
+
+func TestEval_callbackResults(t *testing.T) {
+	returning := func(ret ast.Type, value interface{}) ast.Function {
+		return ast.Function{
+			ReturnType: ret,
+			Callback: func([]interface{}) (interface{}, error) {
+				return value, nil
+			},
+		}
+	}
+
+	scope := &ast.BasicScope{
+		FuncMap: map[string]ast.Function{
+			"badString": returning(ast.TString, 42),
+			"int64":     returning(ast.TInt, int64(42)),
+			"float32":   returning(ast.TFloat, float32(1.5)),
+			"strings":   returning(ast.List(ast.TString), []string{"a", "b"}),
+			"badList":   returning(ast.List(ast.TString), []int{1, 2}),
+			"nil":       returning(ast.TString, nil),
+		},
+	}
+
+	cases := []struct {
+		Input  string
+		Strict bool
+		Result interface{}
+		Error  string
+	}{
+		{`#{badString()}`, false, nil, "1:3: badString: return value should be type string, got type int"},
+		{`#{int64()}`, false, "42", ""},
+		{`#{int64()}`, true, nil, "int64: returned unsupported Go type int64, expected type int"},
+		{`#{float32()}`, false, "1.5", ""},
+		{`#{strings()}`, false, []interface{}{"a", "b"}, ""},
+		{`#{strings()}`, true, nil, "returned unsupported Go type []string"},
+		{`#{badList()}`, false, nil, "return value should be type list(string), got type list"},
+		{`#{nil()}`, false, "", ""},
+	}
+
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+
+		result, err := Eval(node, &EvalConfig{
+			GlobalScope:   scope,
+			StrictResults: tc.Strict,
+		})
+		if (err != nil) != (tc.Error != "") {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+		if err != nil {
+			if !strings.Contains(err.Error(), tc.Error) {
+				t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(result.Value, tc.Result) {
+			t.Fatalf("Bad: %#v\n\nInput: %s", result.Value, tc.Input)
+		}
+	}
+}
+
+func TestEval_panic(t *testing.T) {
+	scope := &ast.BasicScope{
+		FuncMap: map[string]ast.Function{
+			"boom": ast.Function{
+				ReturnType: ast.TString,
+				Callback: func([]interface{}) (interface{}, error) {
+					panic("boom")
+				},
+			},
+			"index": ast.Function{
+				ArgTypes:   []ast.Type{ast.TInt},
+				ReturnType: ast.TString,
+				Callback: func(args []interface{}) (interface{}, error) {
+					return []string{"a"}[args[0].(int)], nil
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		Input  string
+		Debug  bool
+		Checks []SemanticChecker
+		Error  string
+	}{
+		{`foo #{boom()}`, false, nil, "1:7: panic in function boom: boom"},
+		{`#{index(2)}`, false, nil, "1:3: panic in function index: runtime error: index out of range"},
+		{`#{boom()}`, true, nil, "1:3: panic in function boom: boom"},
+		{
+			`foo`,
+			false,
+			[]SemanticChecker{func(ast.Node) error { panic("check") }},
+			"panic during evaluation: check",
+		},
+	}
+
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+
+		_, err = Eval(node, &EvalConfig{
+			GlobalScope:    scope,
+			Debug:          tc.Debug,
+			SemanticChecks: tc.Checks,
+		})
+		var perr *PanicError
+		if !errors.As(err, &perr) {
+			t.Fatalf("Error: %#v\n\nInput: %s", err, tc.Input)
+		}
+		if !strings.Contains(err.Error(), tc.Error) {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+		if (len(perr.Stack) > 0) != tc.Debug {
+			t.Fatalf("Stack: %s\n\nInput: %s", perr.Stack, tc.Input)
+		}
+		if tc.Debug && !strings.Contains(string(perr.Stack), "runtime/debug.Stack") {
+			t.Fatalf("Stack: %s\n\nInput: %s", perr.Stack, tc.Input)
+		}
+		if strings.Contains(err.Error(), "\n") {
+			t.Fatalf("Error should not contain the stack: %s\n\nInput: %s", err, tc.Input)
+		}
+	}
+}
+
+func TestEval_errorTypes(t *testing.T) {
+	scope := &ast.BasicScope{
+		VarMap: map[string]ast.Variable{
+			"var.list": ast.Variable{
+				Type: ast.List(ast.TString),
+				Value: []ast.Variable{
+					{Type: ast.TString, Value: "a"},
+				},
+			},
+			"var.map": ast.Variable{
+				Type:  ast.Map(ast.TString),
+				Value: map[string]ast.Variable{},
+			},
+			"var.server": ast.Variable{
+				Type: ast.Object(map[string]ast.Type{"name": ast.TString}),
+				Value: map[string]ast.Variable{
+					"name": {Type: ast.TString, Value: "web"},
+				},
+			},
+		},
+		FuncMap: map[string]ast.Function{
+			"upper": ast.Function{
+				ArgTypes:   []ast.Type{ast.TString},
+				ReturnType: ast.TString,
+				Callback: func(args []interface{}) (interface{}, error) {
+					return strings.ToUpper(args[0].(string)), nil
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		Input string
+		Error error
+	}{
+		{
+			`foo #{var.nope}`,
+			&UnknownVariableError{
+				Pos:  ast.Pos{Column: 7, Line: 1, Offset: 6},
+				End:  ast.Pos{Column: 15, Line: 1, Offset: 14},
+				Name: "var.nope",
+			},
+		},
+		{
+			`#{nope()}`,
+			&UnknownFunctionError{
+				Pos:  ast.Pos{Column: 3, Line: 1, Offset: 2},
+				End:  ast.Pos{Column: 9, Line: 1, Offset: 8},
+				Name: "nope",
+			},
+		},
+		{
+			`#{upper(var.list)}`,
+			&TypeMismatchError{
+				Pos:      ast.Pos{Column: 9, Line: 1, Offset: 8},
+				End:      ast.Pos{Column: 17, Line: 1, Offset: 16},
+				What:     "upper: argument 1",
+				Expected: ast.TString,
+				Actual:   ast.List(ast.TString),
+			},
+		},
+		{
+			`#{var.list[1]}`,
+			&IndexOutOfRangeError{
+				Pos:  ast.Pos{Column: 3, Line: 1, Offset: 2},
+				End:  ast.Pos{Column: 14, Line: 1, Offset: 13},
+				Name: "var.list",
+				Key:  1,
+				Len:  1,
+			},
+		},
+		{
+			`#{var.map["foo"]}`,
+			&IndexOutOfRangeError{
+				Pos:  ast.Pos{Column: 3, Line: 1, Offset: 2},
+				End:  ast.Pos{Column: 17, Line: 1, Offset: 16},
+				Name: "var.map",
+				Key:  "foo",
+				Len:  0,
+			},
+		},
+		{
+			`#{var.server["host"]}`,
+			&IndexOutOfRangeError{
+				Pos:  ast.Pos{Column: 3, Line: 1, Offset: 2},
+				End:  ast.Pos{Column: 21, Line: 1, Offset: 20},
+				Name: "var.server",
+				Key:  "host",
+				Len:  1,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+
+		_, err = Eval(node, &EvalConfig{GlobalScope: scope})
+		target := reflect.New(reflect.TypeOf(tc.Error))
+		if !errors.As(err, target.Interface()) {
+			t.Fatalf("Error: %#v\n\nInput: %s", err, tc.Input)
+		}
+		if actual := target.Elem().Interface(); !reflect.DeepEqual(actual, tc.Error) {
+			t.Fatalf("Bad: %#v\n\nInput: %s", actual, tc.Input)
+		}
+	}
+}
+
+func TestEval_checkErrors(t *testing.T) {
+	scope := &ast.BasicScope{
+		VarMap: map[string]ast.Variable{
+			"var.list": ast.Variable{
+				Type:  ast.List(ast.TString),
+				Value: []ast.Variable{},
+			},
+		},
+		FuncMap: map[string]ast.Function{
+			"upper": ast.Function{
+				ArgTypes:   []ast.Type{ast.TString},
+				ReturnType: ast.TString,
+				Callback: func(args []interface{}) (interface{}, error) {
+					return strings.ToUpper(args[0].(string)), nil
+				},
+			},
+		},
+	}
+
+	// The identifier and the type error are both reported, but not the
+	// type errors of the calls depending on the unknown variable.
+	node, err := Parse("#{upper(upper(var.nope))} #{upper(var.list)}")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, err = Eval(node, &EvalConfig{GlobalScope: scope})
+	var merr *MultiError
+	if !errors.As(err, &merr) || len(merr.Errors) != 2 {
+		t.Fatalf("bad: %v", err)
+	}
+
+	var verr *UnknownVariableError
+	if !errors.As(merr.Errors[0], &verr) || verr.Name != "var.nope" {
+		t.Fatalf("bad: %v", merr.Errors[0])
+	}
+	var terr *TypeMismatchError
+	if !errors.As(merr.Errors[1], &terr) || terr.Pos.Column != 35 {
+		t.Fatalf("bad: %v", merr.Errors[1])
+	}
+}
@@ -0,0 +1,42 @@
+package stop_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/patdhlk/stop"
+	"github.com/patdhlk/stop/ast"
+)
+
+func Example_resolver() {
+	input := "#{env.USER} on #{env.HOST}"
+
+	tree, err := stop.Parse(input)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Stand-in for os.LookupEnv to keep the output stable.
+	environ := map[string]string{"USER": "admin", "HOST": "web-1"}
+	env := ast.ResolverFunc(func(name string) (ast.Variable, bool) {
+		v, ok := environ[name]
+		return ast.Variable{Type: ast.TString, Value: v}, ok
+	})
+
+	config := &stop.EvalConfig{
+		Scope: &ast.ResolverScope{
+			Resolvers: map[string]ast.Resolver{"env.": env},
+		},
+	}
+
+	result, err := stop.Eval(tree, config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Type: %s\n", result.Type)
+	fmt.Printf("Value: %s\n", result.Value)
+	// Output:
+	// Type: TString
+	// Value: admin on web-1
+}
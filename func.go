@@ -0,0 +1,147 @@
+package stop
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/patdhlk/stop/ast"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Func is like NewFunction but panics if the function can't be converted.
+// It simplifies registering functions in scope literals:
+//
+//	FuncMap: map[string]ast.Function{
+//		"upper": stop.Func(strings.ToUpper),
+//	}
+func Func(fn interface{}) ast.Function {
+	f, err := NewFunction(fn)
+	if err != nil {
+		panic(err)
+	}
+
+	return f
+}
+
+// NewFunction creates an ast.Function from a plain Go function. The
+// argument and return types are derived from the signature of fn:
+//
+//	string kinds         TString
+//	int and uint kinds   TInt
+//	float kinds          TFloat
+//	bool                 TBool
+//	slices and arrays    List(T), or TList for []interface{}
+//	map[string]T         Map(T), or TMap for map[string]interface{}
+//	interface{}          TAny (arguments only)
+//
+// If the first parameter of fn is a context.Context, it receives the
+// context of the evaluation. A variadic fn results in a variadic function.
+// fn must return a single value, optionally followed by an error.
+//
+// Arguments are converted to the Go types fn expects when it is called,
+// and the result is converted back. Values that can't be converted, such
+// as an int that overflows an int8 parameter, result in an error rather
+// than a panic.
+func NewFunction(fn interface{}) (ast.Function, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return ast.Function{}, fmt.Errorf("expected a function, got %T", fn)
+	}
+	t := v.Type()
+
+	var result ast.Function
+
+	// Determine the argument types
+	withContext := t.NumIn() > 0 && t.In(0) == contextType
+	var params []reflect.Type
+	for i := 0; i < t.NumIn(); i++ {
+		if i == 0 && withContext {
+			continue
+		}
+
+		pt := t.In(i)
+		variadic := t.IsVariadic() && i == t.NumIn()-1
+		if variadic {
+			pt = pt.Elem()
+		}
+
+		at, err := reflectType(pt)
+		if err != nil {
+			return ast.Function{}, fmt.Errorf("%s: argument %d: %s", t, i+1, err)
+		}
+
+		params = append(params, pt)
+		if variadic {
+			result.Variadic = true
+			result.VariadicType = at
+		} else {
+			result.ArgTypes = append(result.ArgTypes, at)
+		}
+	}
+
+	// Determine the return type
+	switch {
+	case t.NumOut() == 1:
+	case t.NumOut() == 2 && t.Out(1) == errorType:
+	default:
+		return ast.Function{}, fmt.Errorf(
+			"%s: must return a single value, optionally followed by an error", t)
+	}
+
+	rt, err := reflectType(t.Out(0))
+	if err != nil {
+		return ast.Function{}, fmt.Errorf("%s: return value: %s", t, err)
+	}
+	if rt == ast.TAny {
+		return ast.Function{}, fmt.Errorf("%s: return value: must have a concrete type", t)
+	}
+	result.ReturnType = rt
+
+	call := func(ctx context.Context, args []interface{}) (interface{}, error) {
+		in := make([]reflect.Value, 0, len(args)+1)
+		if withContext {
+			in = append(in, reflect.ValueOf(&ctx).Elem())
+		}
+
+		for i, arg := range args {
+			pt := params[len(params)-1]
+			if i < len(params) {
+				pt = params[i]
+			}
+
+			av, err := valueToReflect(arg, pt)
+			if err != nil {
+				return nil, fmt.Errorf("argument %d: %s", i+1, err)
+			}
+
+			in = append(in, av)
+		}
+
+		out := v.Call(in)
+		if len(out) == 2 && !out[1].IsNil() {
+			return nil, out[1].Interface().(error)
+		}
+
+		variable, err := reflectToVariable(out[0])
+		if err != nil {
+			return nil, fmt.Errorf("return value: %s", err)
+		}
+
+		return variable.Value, nil
+	}
+
+	if withContext {
+		result.ContextCallback = call
+	} else {
+		result.Callback = func(args []interface{}) (interface{}, error) {
+			return call(context.Background(), args)
+		}
+	}
+
+	return result, nil
+}
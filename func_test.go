@@ -0,0 +1,236 @@
+package stop
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/patdhlk/stop/ast"
+)
+
+func TestNewFunction_signature(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Fn       interface{}
+		Error    bool
+		Expected ast.Function
+	}{
+		{
+			"string",
+			strings.ToUpper,
+			false,
+			ast.Function{
+				ArgTypes:   []ast.Type{ast.TString},
+				ReturnType: ast.TString,
+			},
+		},
+
+		{
+			"float",
+			math.Pow,
+			false,
+			ast.Function{
+				ArgTypes:   []ast.Type{ast.TFloat, ast.TFloat},
+				ReturnType: ast.TFloat,
+			},
+		},
+
+		{
+			"trailing error",
+			strconv.Atoi,
+			false,
+			ast.Function{
+				ArgTypes:   []ast.Type{ast.TString},
+				ReturnType: ast.TInt,
+			},
+		},
+
+		{
+			"variadic",
+			func(sep string, parts ...string) string { return "" },
+			false,
+			ast.Function{
+				ArgTypes:     []ast.Type{ast.TString},
+				ReturnType:   ast.TString,
+				Variadic:     true,
+				VariadicType: ast.TString,
+			},
+		},
+
+		{
+			"collections",
+			func(l []int, m map[string][]bool, a interface{}) []string { return nil },
+			false,
+			ast.Function{
+				ArgTypes:   []ast.Type{ast.List(ast.TInt), ast.Map(ast.List(ast.TBool)), ast.TAny},
+				ReturnType: ast.List(ast.TString),
+			},
+		},
+
+		{
+			"untyped collections",
+			func(l []interface{}, m map[string]interface{}) map[string]interface{} { return nil },
+			false,
+			ast.Function{
+				ArgTypes:   []ast.Type{ast.TList, ast.TMap},
+				ReturnType: ast.TMap,
+			},
+		},
+
+		{
+			"context",
+			func(ctx context.Context, b bool) (uint8, error) { return 0, nil },
+			false,
+			ast.Function{
+				ArgTypes:   []ast.Type{ast.TBool},
+				ReturnType: ast.TInt,
+			},
+		},
+
+		{
+			"not a function",
+			"foo",
+			true,
+			ast.Function{},
+		},
+
+		{
+			"no return value",
+			func(string) {},
+			true,
+			ast.Function{},
+		},
+
+		{
+			"two return values",
+			func() (string, string) { return "", "" },
+			true,
+			ast.Function{},
+		},
+
+		{
+			"interface return value",
+			func() interface{} { return nil },
+			true,
+			ast.Function{},
+		},
+
+		{
+			"unsupported argument",
+			func(chan int) string { return "" },
+			true,
+			ast.Function{},
+		},
+
+		{
+			"unsupported map key",
+			func(map[int]string) string { return "" },
+			true,
+			ast.Function{},
+		},
+	}
+
+	for _, tc := range cases {
+		actual, err := NewFunction(tc.Fn)
+		if err != nil != tc.Error {
+			t.Fatalf("%s: err: %v", tc.Name, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		if actual.Callback == nil && actual.ContextCallback == nil {
+			t.Fatalf("%s: no callback", tc.Name)
+		}
+		actual.Callback = nil
+		actual.ContextCallback = nil
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Fatalf("%s:\n     Bad: %#v\nExpected: %#v", tc.Name, actual, tc.Expected)
+		}
+	}
+}
+
+func TestNewFunction_call(t *testing.T) {
+	type key struct{}
+
+	scope := &ast.BasicScope{
+		VarMap: map[string]ast.Variable{
+			"var.list": ast.Variable{
+				Type: ast.TList,
+				Value: []ast.Variable{
+					{Type: ast.TInt, Value: 1},
+					{Type: ast.TInt, Value: 2},
+				},
+			},
+			"var.big": ast.Variable{
+				Type:  ast.TInt,
+				Value: 300,
+			},
+		},
+		FuncMap: map[string]ast.Function{
+			"upper": Func(strings.ToUpper),
+			"atoi":  Func(strconv.Atoi),
+			"join":  Func(func(sep string, p ...string) string { return strings.Join(p, sep) }),
+			"sum": Func(func(l []int8) int8 {
+				var r int8
+				for _, v := range l {
+					r += v
+				}
+				return r
+			}),
+			"byte":  Func(func(b uint8) uint8 { return b }),
+			"split": Func(strings.Split),
+			"ctx": Func(func(ctx context.Context) string {
+				return ctx.Value(key{}).(string)
+			}),
+			"fail": Func(func() (string, error) { return "", errors.New("nope") }),
+		},
+	}
+
+	cases := []struct {
+		Input  string
+		Error  bool
+		Result interface{}
+	}{
+		{`#{upper("foo")}`, false, "FOO"},
+		{`#{atoi("42") + 1}`, false, "43"},
+		{`#{atoi("foo")}`, true, nil},
+		{`#{join(", ", "a", "b", "c")}`, false, "a, b, c"},
+		{`#{join(", ")}`, false, ""},
+		{`#{sum(var.list)}`, false, "3"},
+		{`#{byte(var.big)}`, true, nil},
+		{`#{split("a,b", ",")}`, false, []interface{}{"a", "b"}},
+		{`#{ctx()}`, false, "from context"},
+		{`#{fail()}`, true, nil},
+	}
+
+	ctx := context.WithValue(context.Background(), key{}, "from context")
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+
+		result, err := EvalContext(ctx, node, &EvalConfig{GlobalScope: scope})
+		if err != nil != tc.Error {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+		if !reflect.DeepEqual(result.Value, tc.Result) {
+			t.Fatalf("Bad: %#v\n\nInput: %s", result.Value, tc.Input)
+		}
+	}
+}
+
+func TestFunc_panic(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("should panic")
+		}
+	}()
+
+	Func(func() {})
+}
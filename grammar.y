@@ -11,24 +11,47 @@ import (
     "github.com/patdhlk/stop/ast"
 )
 
+// callArgs are the arguments of a function call while it is being parsed.
+type callArgs struct {
+    positional []ast.Node
+    named      []*ast.NamedArg
+}
+
+// span is a node along with the range of the source it was parsed from,
+// which for an interpolation includes the #{ and } around it and for an
+// expression the parentheses around it. The node keeps its own range.
+type span struct {
+    node     ast.Node
+    pos, end ast.Pos
+}
+
+// nodeSpan returns the span of a node that covers just the node.
+func nodeSpan(n ast.Node) span {
+    return span{node: n, pos: n.Pos(), end: ast.End(n)}
+}
+
 %}
 
 %union {
     node     ast.Node
     nodeList []ast.Node
-    str      string
+    span     span
+    callArgs callArgs
+    namedArg *ast.NamedArg
     token    *parserToken
 }
 
-%token  <str> PROGRAM_BRACKET_LEFT PROGRAM_BRACKET_RIGHT
-%token  <str> PROGRAM_STRING_START PROGRAM_STRING_END
-%token  <str> PAREN_LEFT PAREN_RIGHT COMMA
-%token  <str> SQUARE_BRACKET_LEFT SQUARE_BRACKET_RIGHT
+%token  <token> PROGRAM_BRACKET_LEFT PROGRAM_BRACKET_RIGHT
+%token  <token> PROGRAM_STRING_START PROGRAM_STRING_END
+%token  <token> PAREN_LEFT PAREN_RIGHT COMMA EQUAL
+%token  <token> SQUARE_BRACKET_LEFT SQUARE_BRACKET_RIGHT
 
 %token <token> ARITH_OP IDENTIFIER INTEGER FLOAT BOOL STRING
 
-%type <node> expr interpolation literal literalModeTop literalModeValue
-%type <nodeList> args
+%type <node> literal
+%type <span> expr interpolation literalModeTop literalModeValue
+%type <callArgs> args
+%type <namedArg> namedArg
 
 %left ARITH_OP
 
@@ -36,15 +59,16 @@ import (
 
 top:
     {
-        parserResult = &ast.LiteralNode{
+        parserResult = parserLimits.add(&ast.LiteralNode{
             Value: "",
             Typex:  ast.TString,
             Posx:  ast.Pos{Column: 1, Line: 1},
-        }
+            Endx:  ast.Pos{Column: 1, Line: 1},
+        }, 1)
     }
 |   literalModeTop
 	{
-        parserResult = $1
+        parserResult = $1.node
 
         // We want to make sure that the top value is always an Output
         // so that the return value is always a string, list of map from an
@@ -54,12 +78,13 @@ top:
         // because functionally the AST is the same, but we do that because
         // it makes for an easy literal check later (to check if a string
         // has any interpolations).
-        if _, ok := $1.(*ast.Output); !ok {
-            if n, ok := $1.(*ast.LiteralNode); !ok || n.Typex != ast.TString {
-                parserResult = &ast.Output{
-                    Exprs: []ast.Node{$1},
-                    Posx:  $1.Pos(),
-                }
+        if _, ok := $1.node.(*ast.Output); !ok {
+            if n, ok := $1.node.(*ast.LiteralNode); !ok || n.Typex != ast.TString {
+                parserResult = parserLimits.add(&ast.Output{
+                    Exprs: []ast.Node{$1.node},
+                    Posx:  $1.pos,
+                    Endx:  $1.end,
+                }, 1)
             }
         }
 	}
@@ -71,23 +96,31 @@ literalModeTop:
     }
 |   literalModeTop literalModeValue
     {
+        // Adding to an Output replaces it, so there's no new node
         var result []ast.Node
-        if c, ok := $1.(*ast.Output); ok {
-            result = append(c.Exprs, $2)
+        count := 0
+        if c, ok := $1.node.(*ast.Output); ok {
+            result = append(c.Exprs, $2.node)
         } else {
-            result = []ast.Node{$1, $2}
+            result = []ast.Node{$1.node, $2.node}
+            count = 1
         }
 
-        $$ = &ast.Output{
-            Exprs: result,
-            Posx:  result[0].Pos(),
+        $$ = span{
+            node: parserLimits.add(&ast.Output{
+                Exprs: result,
+                Posx:  $1.pos,
+                Endx:  $2.end,
+            }, count),
+            pos: $1.pos,
+            end: $2.end,
         }
     }
 
 literalModeValue:
 	literal
 	{
-        $$ = $1
+        $$ = span{node: $1, pos: $1.Pos(), end: ast.End($1)}
 	}
 |   interpolation
     {
@@ -97,13 +130,13 @@ literalModeValue:
 interpolation:
     PROGRAM_BRACKET_LEFT expr PROGRAM_BRACKET_RIGHT
     {
-        $$ = $2
+        $$ = span{node: $2.node, pos: $1.Pos, end: $3.End}
     }
 
 expr:
     PAREN_LEFT expr PAREN_RIGHT
     {
-        $$ = $2
+        $$ = span{node: $2.node, pos: $1.Pos, end: $3.End}
     }
 |   literalModeTop
     {
@@ -111,27 +144,30 @@ expr:
     }
 |   INTEGER
     {
-        $$ = &ast.LiteralNode{
+        $$ = nodeSpan(parserLimits.add(&ast.LiteralNode{
             Value: $1.Value.(int),
             Typex:  ast.TInt,
             Posx:  $1.Pos,
-        }
+            Endx:  $1.End,
+        }, 1))
     }
 |   FLOAT
     {
-        $$ = &ast.LiteralNode{
+        $$ = nodeSpan(parserLimits.add(&ast.LiteralNode{
             Value: $1.Value.(float64),
             Typex:  ast.TFloat,
             Posx:  $1.Pos,
-        }
+            Endx:  $1.End,
+        }, 1))
     }
  |   BOOL
     {
-        $$ = &ast.LiteralNode{
+        $$ = nodeSpan(parserLimits.add(&ast.LiteralNode{
             Value: $1.Value.(bool),
             Typex: ast.TBool,
             Posx: $1.Pos,
-        }
+            Endx: $1.End,
+        }, 1))
     }
 |   ARITH_OP expr
     {
@@ -141,68 +177,126 @@ expr:
         // Go scanner/parser.
         if $1.Value.(ast.ArithmeticOp) != ast.ArithmeticOpSub {
             if parserErr == nil {
-                parserErr = fmt.Errorf("Invalid unary operation: %v", $1.Value)
+                parserErr = &ParseError{
+                    Pos: $1.Pos,
+                    End: $1.End,
+                    Msg: fmt.Sprintf("Invalid unary operation: %v", $1.Value),
+                }
             }
         }
 
-        $$ = &ast.Arithmetic{
+        $$ = nodeSpan(parserLimits.add(&ast.Arithmetic{
             Op:    $1.Value.(ast.ArithmeticOp),
             Exprs: []ast.Node{
-                &ast.LiteralNode{Value: 0, Typex: ast.TInt},
-                $2,
+                parserLimits.add(&ast.LiteralNode{
+                    Value: 0,
+                    Typex: ast.TInt,
+                    Posx:  $1.Pos,
+                    Endx:  $1.End,
+                }, 1),
+                $2.node,
             },
-            Posx:  $2.Pos(),
-        }
+            Posx:  $1.Pos,
+            Endx:  $2.end,
+        }, 1))
     }
 |   expr ARITH_OP expr
     {
-        $$ = &ast.Arithmetic{
+        $$ = nodeSpan(parserLimits.add(&ast.Arithmetic{
             Op:    $2.Value.(ast.ArithmeticOp),
-            Exprs: []ast.Node{$1, $3},
-            Posx:  $1.Pos(),
-        }
+            Exprs: []ast.Node{$1.node, $3.node},
+            Posx:  $1.pos,
+            Endx:  $3.end,
+        }, 1))
     }
 |   IDENTIFIER
     {
-        $$ = &ast.VariableAccess{Name: $1.Value.(string), Posx: $1.Pos}
+        $$ = nodeSpan(parserLimits.add(&ast.VariableAccess{
+            Name: $1.Value.(string),
+            Posx: $1.Pos,
+            Endx: $1.End,
+        }, 1))
     }
 |   IDENTIFIER PAREN_LEFT args PAREN_RIGHT
     {
-        $$ = &ast.Call{Func: $1.Value.(string), Args: $3, Posx: $1.Pos}
+        $$ = nodeSpan(parserLimits.add(&ast.Call{
+            Func:      $1.Value.(string),
+            Args:      $3.positional,
+            NamedArgs: $3.named,
+            Posx:      $1.Pos,
+            Endx:      $4.End,
+        }, 1))
     }
 |   IDENTIFIER SQUARE_BRACKET_LEFT expr SQUARE_BRACKET_RIGHT
     {
-        $$ = &ast.Index{
-                Target: &ast.VariableAccess{
+        $$ = nodeSpan(parserLimits.add(&ast.Index{
+                Target: parserLimits.add(&ast.VariableAccess{
                     Name: $1.Value.(string),
                     Posx: $1.Pos,
-                },
-                Key: $3,
+                    Endx: $1.End,
+                }, 1),
+                Key: $3.node,
                 Posx: $1.Pos,
-            }
+                Endx: $4.End,
+            }, 1))
     }
 
 args:
 	{
-		$$ = nil
+		$$ = callArgs{}
 	}
 |	args COMMA expr
 	{
-		$$ = append($1, $3)
+		if len($1.named) > 0 && parserErr == nil {
+			parserErr = &ParseError{
+				Pos: $3.pos,
+				End: $3.end,
+				Msg: "positional argument after named argument",
+				Related: &Related{
+					Pos:     $1.named[0].Pos(),
+					End:     $1.named[0].End(),
+					Message: "the first named argument is here",
+				},
+			}
+		}
+
+		$$ = $1
+		$$.positional = append($$.positional, $3.node)
+	}
+|	args COMMA namedArg
+	{
+		$$ = $1
+		$$.named = append($$.named, $3)
 	}
 |	expr
 	{
-		$$ = append($$, $1)
+		$$ = callArgs{positional: []ast.Node{$1.node}}
+	}
+|	namedArg
+	{
+		$$ = callArgs{named: []*ast.NamedArg{$1}}
+	}
+
+namedArg:
+	IDENTIFIER EQUAL expr
+	{
+		$$ = &ast.NamedArg{
+			Name:  $1.Value.(string),
+			Value: $3.node,
+			Posx:  $1.Pos,
+			Endx:  $3.end,
+		}
 	}
 
 literal:
     STRING
     {
-        $$ = &ast.LiteralNode{
+        $$ = parserLimits.add(&ast.LiteralNode{
             Value: $1.Value.(string),
             Typex:  ast.TString,
             Posx:  $1.Pos,
-        }
+            Endx:  $1.End,
+        }, 1)
     }
 
 %%
@@ -3,6 +3,7 @@ package stop
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"strconv"
 	"unicode"
 	"unicode/utf8"
@@ -21,13 +22,26 @@ type parserLex struct {
 	Err   error
 	Input string
 
+	// MaxInterpolationDepth, if non-zero, is the maximum depth to which
+	// interpolations may be nested.
+	MaxInterpolationDepth int
+
+	// limits, if set, stops the lexer once the tree built by the parser
+	// exceeds them.
+	limits *limitCheck
+
 	mode               parserMode
 	interpolationDepth int
 	pos                int
 	width              int
-	col, line          int
-	lastLine           int
-	astPos             *ast.Pos
+
+	// start is the offset of the token being lexed, or -1 if none of it
+	// has been read yet.
+	start int
+
+	// lines are the offsets at which the lines of the input start. It is
+	// built on first use by posAt.
+	lines []int
 }
 
 // parserToken is the token yielded to the parser. The value can be
@@ -36,6 +50,7 @@ type parserLex struct {
 type parserToken struct {
 	Value interface{}
 	Pos   ast.Pos
+	End   ast.Pos
 }
 
 // parserMode keeps track of what mode we're in for the parser. We have
@@ -59,15 +74,36 @@ func (x *parserLex) Lex(yylval *parserSymType) int {
 		x.mode = parserModeLiteral
 	}
 
-	// Defer an update to set the proper column/line we read the next token.
-	defer func() {
-		if yylval.token != nil && yylval.token.Pos.Column == 0 {
-			yylval.token.Pos = *x.astPos
+	x.start = -1
+	yylval.token = nil
+
+	var result int
+	if x.limits != nil && x.limits.err != nil {
+		result = lexEOF
+	} else {
+		result = x.lex(yylval)
+	}
+
+	// Every token carries its range, even those without a value
+	if x.start < 0 {
+		x.start = x.pos
+	}
+	if yylval.token == nil {
+		yylval.token = &parserToken{}
+	}
+	yylval.token.Pos = x.posAt(x.start)
+	yylval.token.End = x.posAt(x.pos)
+
+	// Every one of these tokens becomes at least one node, so counting
+	// them enforces MaxNodes before the parser gets to build the nodes.
+	switch result {
+	case ARITH_OP, IDENTIFIER, INTEGER, FLOAT, BOOL, STRING:
+		if x.limits.token(yylval.token.Pos) {
+			return lexEOF
 		}
-	}()
+	}
 
-	x.astPos = nil
-	return x.lex(yylval)
+	return result
 }
 
 func (x *parserLex) lex(yylval *parserSymType) int {
@@ -93,6 +129,15 @@ func (x *parserLex) lexModeLiteral(yylval *parserSymType) int {
 		if c == '#' && x.peek() == '{' {
 			x.next()
 			x.interpolationDepth++
+			if x.MaxInterpolationDepth > 0 && x.interpolationDepth > x.MaxInterpolationDepth {
+				x.Err = &posError{
+					Pos: x.posAt(x.start),
+					End: x.posAt(x.pos),
+					Err: fmt.Errorf("%w (limit %d)", ErrMaxInterpolationDepth, x.MaxInterpolationDepth),
+				}
+				return lexEOF
+			}
+
 			x.mode = parserModeInterpolation
 			return PROGRAM_BRACKET_LEFT
 		}
@@ -110,6 +155,7 @@ func (x *parserLex) lexModeLiteral(yylval *parserSymType) int {
 			// If the string is empty, just skip it. We're still in
 			// an interpolation so we do this to avoid empty nodes.
 			if yylval.token.Value.(string) == "" {
+				x.start = -1
 				return x.lex(yylval)
 			}
 		}
@@ -127,6 +173,7 @@ func (x *parserLex) lexModeInterpolation(yylval *parserSymType) int {
 
 		// Ignore all whitespace
 		if unicode.IsSpace(c) {
+			x.start = -1
 			continue
 		}
 
@@ -140,7 +187,8 @@ func (x *parserLex) lexModeInterpolation(yylval *parserSymType) int {
 				x.mode = parserModeLiteral
 
 				// If the string is empty and we're starting an interpolation,
-				// then just skip it to avoid empty string AST nodes
+				// then just skip it to avoid empty string AST nodes. The
+				// quote becomes part of the range of the interpolation.
 				if yylval.token.Value.(string) == "" {
 					return x.lex(yylval)
 				}
@@ -161,6 +209,15 @@ func (x *parserLex) lexModeInterpolation(yylval *parserSymType) int {
 			// literal mode and reduce our interpolation depth.
 			x.interpolationDepth--
 			x.mode = parserModeLiteral
+
+			// A quote right after an interpolation within a string ends
+			// the string, which would leave an empty string to skip. Make
+			// it part of the range of the interpolation instead.
+			if x.interpolationDepth > 0 && x.peek() == '"' {
+				x.next()
+				x.mode = parserModeInterpolation
+			}
+
 			return PROGRAM_BRACKET_RIGHT
 		case '(':
 			return PAREN_LEFT
@@ -172,6 +229,8 @@ func (x *parserLex) lexModeInterpolation(yylval *parserSymType) int {
 			return SQUARE_BRACKET_RIGHT
 		case ',':
 			return COMMA
+		case '=':
+			return EQUAL
 		case '+':
 			yylval.token = &parserToken{Value: ast.ArithmeticOpAdd}
 			return ARITH_OP
@@ -367,51 +426,67 @@ func (x *parserLex) next() rune {
 		return lexEOF
 	}
 
+	if x.start < 0 {
+		x.start = x.pos
+	}
+
 	r, w := utf8.DecodeRuneInString(x.Input[x.pos:])
 	x.width = w
 	x.pos += x.width
-
-	if x.line == 0 {
-		x.line = 1
-		x.col = 1
-	} else {
-		x.col += 1
-	}
-
-	if r == '\n' {
-		x.lastLine = x.col
-		x.line += 1
-		x.col = 1
-	}
-
-	if x.astPos == nil {
-		x.astPos = &ast.Pos{Column: x.col, Line: x.line}
-	}
-
 	return r
 }
 
-// peek returns but does not consume the next rune in the input
+// peek returns but does not consume the next rune in the input. It
+// keeps the width of the last rune so that it can still be backed up,
+// even if peek hit the end of the input.
 func (x *parserLex) peek() rune {
+	width := x.width
 	r := x.next()
 	x.backup()
+	x.width = width
 	return r
 }
 
 // backup steps back one rune. Can only be called once per next.
 func (x *parserLex) backup() {
 	x.pos -= x.width
-	x.col -= 1
+}
 
-	// If we are at column 0, we're backing up across a line boundary
-	// so we need to be careful to get the proper value.
-	if x.col == 0 {
-		x.col = x.lastLine
-		x.line -= 1
+// posAt returns the position of the given byte offset in the input.
+// Columns count runes, not bytes.
+func (x *parserLex) posAt(offset int) ast.Pos {
+	if x.lines == nil {
+		x.lines = []int{0}
+		for i := 0; i < len(x.Input); i++ {
+			if x.Input[i] == '\n' {
+				x.lines = append(x.lines, i+1)
+			}
+		}
+	}
+
+	line := sort.Search(len(x.lines), func(i int) bool {
+		return x.lines[i] > offset
+	}) - 1
+
+	return ast.Pos{
+		Column: utf8.RuneCountInString(x.Input[x.lines[line]:offset]) + 1,
+		Line:   line + 1,
+		Offset: offset,
 	}
 }
 
-// The parser calls this method on a parse error.
+// The parser calls this method on a parse error. Only the first error is
+// kept since anything after it is usually a consequence of it. The error
+// covers the token being lexed, which is where the parser gave up.
 func (x *parserLex) Error(s string) {
-	x.Err = fmt.Errorf("parse error: %s", s)
+	if x.Err != nil {
+		return
+	}
+
+	start := x.start
+	if start < 0 {
+		start = x.pos
+	}
+
+	x.Err = &ParseError{Pos: x.posAt(start), End: x.posAt(x.pos), Msg: s}
 }
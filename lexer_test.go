@@ -3,6 +3,8 @@ package stop
 import (
 	"reflect"
 	"testing"
+
+	"github.com/patdhlk/stop/ast"
 )
 
 func TestLex(t *testing.T) {
@@ -56,6 +58,15 @@ func TestLex(t *testing.T) {
 				PROGRAM_BRACKET_RIGHT, lexEOF},
 		},
 
+		{
+			"#{bar(baz, width = 10)}",
+			[]int{PROGRAM_BRACKET_LEFT,
+				IDENTIFIER, PAREN_LEFT,
+				IDENTIFIER, COMMA, IDENTIFIER, EQUAL, INTEGER,
+				PAREN_RIGHT,
+				PROGRAM_BRACKET_RIGHT, lexEOF},
+		},
+
 		{
 			"#{bar(42)}",
 			[]int{PROGRAM_BRACKET_LEFT,
@@ -152,3 +163,27 @@ func TestLex(t *testing.T) {
 		}
 	}
 }
+
+func TestLex_ranges(t *testing.T) {
+	// Columns count runes while offsets count bytes
+	l := &parserLex{Input: `ä #{"b#{c}"}`}
+	expected := []parserToken{
+		{Value: "ä ", Pos: ast.Pos{Column: 1, Line: 1}, End: ast.Pos{Column: 3, Line: 1, Offset: 3}},
+		{Pos: ast.Pos{Column: 3, Line: 1, Offset: 3}, End: ast.Pos{Column: 5, Line: 1, Offset: 5}},
+		{Value: "b", Pos: ast.Pos{Column: 5, Line: 1, Offset: 5}, End: ast.Pos{Column: 7, Line: 1, Offset: 7}},
+		{Pos: ast.Pos{Column: 7, Line: 1, Offset: 7}, End: ast.Pos{Column: 9, Line: 1, Offset: 9}},
+		{Value: "c", Pos: ast.Pos{Column: 9, Line: 1, Offset: 9}, End: ast.Pos{Column: 10, Line: 1, Offset: 10}},
+		{Pos: ast.Pos{Column: 10, Line: 1, Offset: 10}, End: ast.Pos{Column: 12, Line: 1, Offset: 12}},
+		{Pos: ast.Pos{Column: 12, Line: 1, Offset: 12}, End: ast.Pos{Column: 13, Line: 1, Offset: 13}},
+	}
+
+	for _, e := range expected {
+		var sym parserSymType
+		if l.Lex(&sym) == lexEOF {
+			t.Fatalf("unexpected EOF, expected %#v", e)
+		}
+		if !reflect.DeepEqual(*sym.token, e) {
+			t.Fatalf("Bad: %#v\n\nExpected: %#v", *sym.token, e)
+		}
+	}
+}
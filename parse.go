@@ -1,6 +1,7 @@
 package stop
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/patdhlk/stop/ast"
@@ -9,9 +10,39 @@ import (
 var parserLock sync.Mutex
 var parserResult ast.Node
 var parserErr error
+var parserLimits *limitCheck
+
+// ParseConfig is the configuration for parsing. The limits protect against
+// untrusted programs. A value of zero means that there is no limit. They are
+// enforced while the program is parsed, so the parser stops reading the
+// input as soon as one is exceeded.
+type ParseConfig struct {
+	// MaxDepth is the maximum depth of the resulting AST, where the root
+	// node has depth 1. Exceeding it returns ErrMaxDepth.
+	MaxDepth int
+
+	// MaxNodes is the maximum number of nodes in the resulting AST.
+	// Exceeding it returns ErrMaxNodes.
+	MaxNodes int
+
+	// MaxInterpolationDepth is the maximum depth to which interpolations
+	// may be nested, i.e. "#{"#{"#{1}"}"}" has a depth of 3. Exceeding it
+	// returns ErrMaxInterpolationDepth.
+	MaxInterpolationDepth int
+}
 
 // Parse parses the given program and returns an executable AST tree.
 func Parse(v string) (ast.Node, error) {
+	return ParseWithConfig(v, nil)
+}
+
+// ParseWithConfig parses the given program with the given configuration
+// and returns an executable AST tree.
+func ParseWithConfig(v string, config *ParseConfig) (ast.Node, error) {
+	if config == nil {
+		config = new(ParseConfig)
+	}
+
 	// Unfortunately due to the way that goyacc generated parsers are
 	// formatted, we can only do a single parse at a time without a lot
 	// of extra work. In the future we can remove this limitation.
@@ -22,12 +53,27 @@ func Parse(v string) (ast.Node, error) {
 	parserErr = nil
 	parserResult = nil
 
+	// The limits are checked by the grammar as the tree is built, and the
+	// lexer stops reading the input once they are exceeded.
+	parserLimits = &limitCheck{MaxDepth: config.MaxDepth, MaxNodes: config.MaxNodes}
+	defer func() { parserLimits = nil }()
+
 	// Create the lexer
-	lex := &parserLex{Input: v}
+	lex := &parserLex{
+		Input:                 v,
+		MaxInterpolationDepth: config.MaxInterpolationDepth,
+		limits:                parserLimits,
+	}
 
 	// Parse!
 	parserParse(lex)
 
+	// If we exceeded a limit, return that. Stopping the lexer early
+	// usually results in a syntax error as well.
+	if parserLimits.err != nil {
+		return nil, parserLimits.err
+	}
+
 	// If we have a lex error, return that
 	if lex.Err != nil {
 		return nil, lex.Err
@@ -40,3 +86,75 @@ func Parse(v string) (ast.Node, error) {
 
 	return parserResult, nil
 }
+
+// limitCheck verifies that the AST built by the parser stays within the
+// size limits of a ParseConfig. The parser reports every node it builds
+// to it, children before their parents.
+type limitCheck struct {
+	MaxDepth int
+	MaxNodes int
+
+	nodes  int
+	tokens int
+	err    error
+
+	// heights are the heights of the nodes built so far, which is the
+	// depth of the tree they are the root of.
+	heights map[ast.Node]int
+}
+
+// token records a token that becomes a node at pos and returns true if
+// there are more of them than MaxNodes.
+func (c *limitCheck) token(pos ast.Pos) bool {
+	if c == nil || c.MaxNodes <= 0 {
+		return false
+	}
+
+	c.tokens++
+	if c.tokens > c.MaxNodes && c.err == nil {
+		c.err = &posError{Pos: pos, Err: fmt.Errorf("%w (limit %d)", ErrMaxNodes, c.MaxNodes)}
+	}
+
+	return c.err != nil
+}
+
+// add records the node n built by the parser and returns it. count is
+// the number of nodes that are new, which is zero for an Output that
+// replaces one with fewer expressions.
+func (c *limitCheck) add(n ast.Node, count int) ast.Node {
+	if c == nil || c.err != nil || (c.MaxDepth <= 0 && c.MaxNodes <= 0) {
+		return n
+	}
+
+	c.nodes += count
+	if c.MaxNodes > 0 && c.nodes > c.MaxNodes {
+		c.err = errorAt(n, "%w (limit %d)", ErrMaxNodes, c.MaxNodes)
+		return n
+	}
+
+	if c.MaxDepth > 0 {
+		if c.heights == nil {
+			c.heights = make(map[ast.Node]int)
+		}
+
+		// The tree is at least as deep as any of its subtrees
+		height := 1
+		ast.Inspect(n, func(cur *ast.Cursor) error {
+			if cur.Node() == n {
+				return nil
+			}
+
+			if h := c.heights[cur.Node()] + 1; h > height {
+				height = h
+			}
+			return ast.SkipChildren
+		}, nil)
+
+		c.heights[n] = height
+		if height > c.MaxDepth {
+			c.err = errorAt(n, "%w (limit %d)", ErrMaxDepth, c.MaxDepth)
+		}
+	}
+
+	return n
+}
@@ -1,7 +1,9 @@
 package stop
 
 import (
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/patdhlk/stop/ast"
@@ -20,6 +22,7 @@ func TestParse(t *testing.T) {
 				Value: "",
 				Typex: ast.TString,
 				Posx:  ast.Pos{Column: 1, Line: 1},
+				Endx:  ast.Pos{Column: 1, Line: 1},
 			},
 		},
 
@@ -30,6 +33,7 @@ func TestParse(t *testing.T) {
 				Value: "foo",
 				Typex: ast.TString,
 				Posx:  ast.Pos{Column: 1, Line: 1},
+				Endx:  ast.Pos{Column: 4, Line: 1, Offset: 3},
 			},
 		},
 
@@ -40,6 +44,7 @@ func TestParse(t *testing.T) {
 				Value: "#{var.foo}",
 				Typex: ast.TString,
 				Posx:  ast.Pos{Column: 1, Line: 1},
+				Endx:  ast.Pos{Column: 12, Line: 1, Offset: 11},
 			},
 		},
 
@@ -48,15 +53,18 @@ func TestParse(t *testing.T) {
 			false,
 			&ast.Output{
 				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 15, Line: 1, Offset: 14},
 				Exprs: []ast.Node{
 					&ast.LiteralNode{
 						Value: "foo ",
 						Typex: ast.TString,
 						Posx:  ast.Pos{Column: 1, Line: 1},
+						Endx:  ast.Pos{Column: 5, Line: 1, Offset: 4},
 					},
 					&ast.VariableAccess{
 						Name: "var.bar",
-						Posx: ast.Pos{Column: 7, Line: 1},
+						Posx: ast.Pos{Column: 7, Line: 1, Offset: 6},
+						Endx: ast.Pos{Column: 14, Line: 1, Offset: 13},
 					},
 				},
 			},
@@ -67,20 +75,46 @@ func TestParse(t *testing.T) {
 			false,
 			&ast.Output{
 				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 19, Line: 1, Offset: 18},
 				Exprs: []ast.Node{
 					&ast.LiteralNode{
 						Value: "foo ",
 						Typex: ast.TString,
 						Posx:  ast.Pos{Column: 1, Line: 1},
+						Endx:  ast.Pos{Column: 5, Line: 1, Offset: 4},
 					},
 					&ast.VariableAccess{
 						Name: "var.bar",
-						Posx: ast.Pos{Column: 7, Line: 1},
+						Posx: ast.Pos{Column: 7, Line: 1, Offset: 6},
+						Endx: ast.Pos{Column: 14, Line: 1, Offset: 13},
 					},
 					&ast.LiteralNode{
 						Value: " baz",
 						Typex: ast.TString,
-						Posx:  ast.Pos{Column: 15, Line: 1},
+						Posx:  ast.Pos{Column: 15, Line: 1, Offset: 14},
+						Endx:  ast.Pos{Column: 19, Line: 1, Offset: 18},
+					},
+				},
+			},
+		},
+
+		{
+			"foo\n#{bar}",
+			false,
+			&ast.Output{
+				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 7, Line: 2, Offset: 10},
+				Exprs: []ast.Node{
+					&ast.LiteralNode{
+						Value: "foo\n",
+						Typex: ast.TString,
+						Posx:  ast.Pos{Column: 1, Line: 1},
+						Endx:  ast.Pos{Column: 1, Line: 2, Offset: 4},
+					},
+					&ast.VariableAccess{
+						Name: "bar",
+						Posx: ast.Pos{Column: 3, Line: 2, Offset: 6},
+						Endx: ast.Pos{Column: 6, Line: 2, Offset: 9},
 					},
 				},
 			},
@@ -91,16 +125,19 @@ func TestParse(t *testing.T) {
 			false,
 			&ast.Output{
 				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 13, Line: 1, Offset: 12},
 				Exprs: []ast.Node{
 					&ast.LiteralNode{
 						Value: "foo ",
 						Typex: ast.TString,
 						Posx:  ast.Pos{Column: 1, Line: 1},
+						Endx:  ast.Pos{Column: 5, Line: 1, Offset: 4},
 					},
 					&ast.LiteralNode{
 						Value: "bar",
 						Typex: ast.TString,
-						Posx:  ast.Pos{Column: 7, Line: 1},
+						Posx:  ast.Pos{Column: 7, Line: 1, Offset: 6},
+						Endx:  ast.Pos{Column: 12, Line: 1, Offset: 11},
 					},
 				},
 			},
@@ -117,16 +154,19 @@ func TestParse(t *testing.T) {
 			false,
 			&ast.Output{
 				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 10, Line: 1, Offset: 9},
 				Exprs: []ast.Node{
 					&ast.LiteralNode{
 						Value: "foo ",
 						Typex: ast.TString,
 						Posx:  ast.Pos{Column: 1, Line: 1},
+						Endx:  ast.Pos{Column: 5, Line: 1, Offset: 4},
 					},
 					&ast.LiteralNode{
 						Value: 42,
 						Typex: ast.TInt,
-						Posx:  ast.Pos{Column: 7, Line: 1},
+						Posx:  ast.Pos{Column: 7, Line: 1, Offset: 6},
+						Endx:  ast.Pos{Column: 9, Line: 1, Offset: 8},
 					},
 				},
 			},
@@ -137,16 +177,19 @@ func TestParse(t *testing.T) {
 			false,
 			&ast.Output{
 				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 15, Line: 1, Offset: 14},
 				Exprs: []ast.Node{
 					&ast.LiteralNode{
 						Value: "foo ",
 						Typex: ast.TString,
 						Posx:  ast.Pos{Column: 1, Line: 1},
+						Endx:  ast.Pos{Column: 5, Line: 1, Offset: 4},
 					},
 					&ast.LiteralNode{
 						Value: 3.14159,
 						Typex: ast.TFloat,
-						Posx:  ast.Pos{Column: 7, Line: 1},
+						Posx:  ast.Pos{Column: 7, Line: 1, Offset: 6},
+						Endx:  ast.Pos{Column: 14, Line: 1, Offset: 13},
 					},
 				},
 			},
@@ -157,16 +200,19 @@ func TestParse(t *testing.T) {
 			false,
 			&ast.Output{
 				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 12, Line: 1, Offset: 11},
 				Exprs: []ast.Node{
 					&ast.LiteralNode{
 						Value: "foo ",
 						Typex: ast.TString,
 						Posx:  ast.Pos{Column: 1, Line: 1},
+						Endx:  ast.Pos{Column: 5, Line: 1, Offset: 4},
 					},
 					&ast.LiteralNode{
 						Value: true,
 						Typex: ast.TBool,
-						Posx:  ast.Pos{Column: 7, Line: 1},
+						Posx:  ast.Pos{Column: 7, Line: 1, Offset: 6},
+						Endx:  ast.Pos{Column: 11, Line: 1, Offset: 10},
 					},
 				},
 			},
@@ -177,11 +223,13 @@ func TestParse(t *testing.T) {
 			false,
 			&ast.Output{
 				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 12, Line: 1, Offset: 11},
 				Exprs: []ast.Node{
 					&ast.LiteralNode{
 						Value: "foo ",
 						Typex: ast.TString,
 						Posx:  ast.Pos{Column: 1, Line: 1},
+						Endx:  ast.Pos{Column: 5, Line: 1, Offset: 4},
 					},
 					&ast.Arithmetic{
 						Op: ast.ArithmeticOpAdd,
@@ -189,15 +237,18 @@ func TestParse(t *testing.T) {
 							&ast.LiteralNode{
 								Value: 42,
 								Typex: ast.TInt,
-								Posx:  ast.Pos{Column: 7, Line: 1},
+								Posx:  ast.Pos{Column: 7, Line: 1, Offset: 6},
+								Endx:  ast.Pos{Column: 9, Line: 1, Offset: 8},
 							},
 							&ast.LiteralNode{
 								Value: 1,
 								Typex: ast.TInt,
-								Posx:  ast.Pos{Column: 10, Line: 1},
+								Posx:  ast.Pos{Column: 10, Line: 1, Offset: 9},
+								Endx:  ast.Pos{Column: 11, Line: 1, Offset: 10},
 							},
 						},
-						Posx: ast.Pos{Column: 7, Line: 1},
+						Posx: ast.Pos{Column: 7, Line: 1, Offset: 6},
+						Endx: ast.Pos{Column: 11, Line: 1, Offset: 10},
 					},
 				},
 			},
@@ -208,31 +259,109 @@ func TestParse(t *testing.T) {
 			false,
 			&ast.Output{
 				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 21, Line: 1, Offset: 20},
 				Exprs: []ast.Node{
 					&ast.LiteralNode{
 						Value: "foo ",
 						Typex: ast.TString,
 						Posx:  ast.Pos{Column: 1, Line: 1},
+						Endx:  ast.Pos{Column: 5, Line: 1, Offset: 4},
 					},
 					&ast.Arithmetic{
 						Op: ast.ArithmeticOpMul,
 						Exprs: []ast.Node{
 							&ast.VariableAccess{
 								Name: "var.bar",
-								Posx: ast.Pos{Column: 7, Line: 1},
+								Posx: ast.Pos{Column: 7, Line: 1, Offset: 6},
+								Endx: ast.Pos{Column: 14, Line: 1, Offset: 13},
 							},
 							&ast.LiteralNode{
 								Value: 1,
 								Typex: ast.TInt,
-								Posx:  ast.Pos{Column: 15, Line: 1},
+								Posx:  ast.Pos{Column: 15, Line: 1, Offset: 14},
+								Endx:  ast.Pos{Column: 16, Line: 1, Offset: 15},
 							},
 						},
-						Posx: ast.Pos{Column: 7, Line: 1},
+						Posx: ast.Pos{Column: 7, Line: 1, Offset: 6},
+						Endx: ast.Pos{Column: 16, Line: 1, Offset: 15},
 					},
 					&ast.LiteralNode{
 						Value: " baz",
 						Typex: ast.TString,
-						Posx:  ast.Pos{Column: 17, Line: 1},
+						Posx:  ast.Pos{Column: 17, Line: 1, Offset: 16},
+						Endx:  ast.Pos{Column: 21, Line: 1, Offset: 20},
+					},
+				},
+			},
+		},
+
+		{
+			"#{( foo )}",
+			false,
+			&ast.Output{
+				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 11, Line: 1, Offset: 10},
+				Exprs: []ast.Node{
+					&ast.VariableAccess{
+						Name: "foo",
+						Posx: ast.Pos{Column: 5, Line: 1, Offset: 4},
+						Endx: ast.Pos{Column: 8, Line: 1, Offset: 7},
+					},
+				},
+			},
+		},
+
+		{
+			"#{(1 + 2) * -3}",
+			false,
+			&ast.Output{
+				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 16, Line: 1, Offset: 15},
+				Exprs: []ast.Node{
+					&ast.Arithmetic{
+						Op: ast.ArithmeticOpMul,
+						Exprs: []ast.Node{
+							&ast.Arithmetic{
+								Op: ast.ArithmeticOpAdd,
+								Exprs: []ast.Node{
+									&ast.LiteralNode{
+										Value: 1,
+										Typex: ast.TInt,
+										Posx:  ast.Pos{Column: 4, Line: 1, Offset: 3},
+										Endx:  ast.Pos{Column: 5, Line: 1, Offset: 4},
+									},
+									&ast.LiteralNode{
+										Value: 2,
+										Typex: ast.TInt,
+										Posx:  ast.Pos{Column: 8, Line: 1, Offset: 7},
+										Endx:  ast.Pos{Column: 9, Line: 1, Offset: 8},
+									},
+								},
+								Posx: ast.Pos{Column: 4, Line: 1, Offset: 3},
+								Endx: ast.Pos{Column: 9, Line: 1, Offset: 8},
+							},
+							&ast.Arithmetic{
+								Op: ast.ArithmeticOpSub,
+								Exprs: []ast.Node{
+									&ast.LiteralNode{
+										Value: 0,
+										Typex: ast.TInt,
+										Posx:  ast.Pos{Column: 13, Line: 1, Offset: 12},
+										Endx:  ast.Pos{Column: 14, Line: 1, Offset: 13},
+									},
+									&ast.LiteralNode{
+										Value: 3,
+										Typex: ast.TInt,
+										Posx:  ast.Pos{Column: 14, Line: 1, Offset: 13},
+										Endx:  ast.Pos{Column: 15, Line: 1, Offset: 14},
+									},
+								},
+								Posx: ast.Pos{Column: 13, Line: 1, Offset: 12},
+								Endx: ast.Pos{Column: 15, Line: 1, Offset: 14},
+							},
+						},
+						Posx: ast.Pos{Column: 3, Line: 1, Offset: 2},
+						Endx: ast.Pos{Column: 15, Line: 1, Offset: 14},
 					},
 				},
 			},
@@ -242,12 +371,14 @@ func TestParse(t *testing.T) {
 			"#{foo()}",
 			false,
 			&ast.Output{
-				Posx: ast.Pos{Column: 3, Line: 1},
+				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 9, Line: 1, Offset: 8},
 				Exprs: []ast.Node{
 					&ast.Call{
 						Func: "foo",
 						Args: nil,
-						Posx: ast.Pos{Column: 3, Line: 1},
+						Posx: ast.Pos{Column: 3, Line: 1, Offset: 2},
+						Endx: ast.Pos{Column: 8, Line: 1, Offset: 7},
 					},
 				},
 			},
@@ -257,15 +388,18 @@ func TestParse(t *testing.T) {
 			"#{foo(bar)}",
 			false,
 			&ast.Output{
-				Posx: ast.Pos{Column: 3, Line: 1},
+				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 12, Line: 1, Offset: 11},
 				Exprs: []ast.Node{
 					&ast.Call{
 						Func: "foo",
-						Posx: ast.Pos{Column: 3, Line: 1},
+						Posx: ast.Pos{Column: 3, Line: 1, Offset: 2},
+						Endx: ast.Pos{Column: 11, Line: 1, Offset: 10},
 						Args: []ast.Node{
 							&ast.VariableAccess{
 								Name: "bar",
-								Posx: ast.Pos{Column: 7, Line: 1},
+								Posx: ast.Pos{Column: 7, Line: 1, Offset: 6},
+								Endx: ast.Pos{Column: 10, Line: 1, Offset: 9},
 							},
 						},
 					},
@@ -273,23 +407,69 @@ func TestParse(t *testing.T) {
 			},
 		},
 
+		{
+			"#{foo(bar, width = 10)}",
+			false,
+			&ast.Output{
+				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 24, Line: 1, Offset: 23},
+				Exprs: []ast.Node{
+					&ast.Call{
+						Func: "foo",
+						Posx: ast.Pos{Column: 3, Line: 1, Offset: 2},
+						Endx: ast.Pos{Column: 23, Line: 1, Offset: 22},
+						Args: []ast.Node{
+							&ast.VariableAccess{
+								Name: "bar",
+								Posx: ast.Pos{Column: 7, Line: 1, Offset: 6},
+								Endx: ast.Pos{Column: 10, Line: 1, Offset: 9},
+							},
+						},
+						NamedArgs: []*ast.NamedArg{
+							&ast.NamedArg{
+								Name: "width",
+								Value: &ast.LiteralNode{
+									Value: 10,
+									Typex: ast.TInt,
+									Posx:  ast.Pos{Column: 20, Line: 1, Offset: 19},
+									Endx:  ast.Pos{Column: 22, Line: 1, Offset: 21},
+								},
+								Posx: ast.Pos{Column: 12, Line: 1, Offset: 11},
+								Endx: ast.Pos{Column: 22, Line: 1, Offset: 21},
+							},
+						},
+					},
+				},
+			},
+		},
+
+		{
+			"#{foo(width = 10, bar)}",
+			true,
+			nil,
+		},
+
 		{
 			"#{foo(bar, baz)}",
 			false,
 			&ast.Output{
-				Posx: ast.Pos{Column: 3, Line: 1},
+				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 17, Line: 1, Offset: 16},
 				Exprs: []ast.Node{
 					&ast.Call{
 						Func: "foo",
-						Posx: ast.Pos{Column: 3, Line: 1},
+						Posx: ast.Pos{Column: 3, Line: 1, Offset: 2},
+						Endx: ast.Pos{Column: 16, Line: 1, Offset: 15},
 						Args: []ast.Node{
 							&ast.VariableAccess{
 								Name: "bar",
-								Posx: ast.Pos{Column: 7, Line: 1},
+								Posx: ast.Pos{Column: 7, Line: 1, Offset: 6},
+								Endx: ast.Pos{Column: 10, Line: 1, Offset: 9},
 							},
 							&ast.VariableAccess{
 								Name: "baz",
-								Posx: ast.Pos{Column: 11, Line: 1},
+								Posx: ast.Pos{Column: 12, Line: 1, Offset: 11},
+								Endx: ast.Pos{Column: 15, Line: 1, Offset: 14},
 							},
 						},
 					},
@@ -301,19 +481,23 @@ func TestParse(t *testing.T) {
 			"#{foo(bar(baz))}",
 			false,
 			&ast.Output{
-				Posx: ast.Pos{Column: 3, Line: 1},
+				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 17, Line: 1, Offset: 16},
 				Exprs: []ast.Node{
 					&ast.Call{
 						Func: "foo",
-						Posx: ast.Pos{Column: 3, Line: 1},
+						Posx: ast.Pos{Column: 3, Line: 1, Offset: 2},
+						Endx: ast.Pos{Column: 16, Line: 1, Offset: 15},
 						Args: []ast.Node{
 							&ast.Call{
 								Func: "bar",
-								Posx: ast.Pos{Column: 7, Line: 1},
+								Posx: ast.Pos{Column: 7, Line: 1, Offset: 6},
+								Endx: ast.Pos{Column: 15, Line: 1, Offset: 14},
 								Args: []ast.Node{
 									&ast.VariableAccess{
 										Name: "baz",
-										Posx: ast.Pos{Column: 11, Line: 1},
+										Posx: ast.Pos{Column: 11, Line: 1, Offset: 10},
+										Endx: ast.Pos{Column: 14, Line: 1, Offset: 13},
 									},
 								},
 							},
@@ -328,23 +512,28 @@ func TestParse(t *testing.T) {
 			false,
 			&ast.Output{
 				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 20, Line: 1, Offset: 19},
 				Exprs: []ast.Node{
 					&ast.LiteralNode{
 						Value: "foo ",
 						Typex: ast.TString,
 						Posx:  ast.Pos{Column: 1, Line: 1},
+						Endx:  ast.Pos{Column: 5, Line: 1, Offset: 4},
 					},
 					&ast.Output{
-						Posx: ast.Pos{Column: 7, Line: 1},
+						Posx: ast.Pos{Column: 7, Line: 1, Offset: 6},
+						Endx: ast.Pos{Column: 19, Line: 1, Offset: 18},
 						Exprs: []ast.Node{
 							&ast.LiteralNode{
 								Value: "bar ",
 								Typex: ast.TString,
-								Posx:  ast.Pos{Column: 7, Line: 1},
+								Posx:  ast.Pos{Column: 7, Line: 1, Offset: 6},
+								Endx:  ast.Pos{Column: 12, Line: 1, Offset: 11},
 							},
 							&ast.VariableAccess{
 								Name: "baz",
-								Posx: ast.Pos{Column: 14, Line: 1},
+								Posx: ast.Pos{Column: 14, Line: 1, Offset: 13},
+								Endx: ast.Pos{Column: 17, Line: 1, Offset: 16},
 							},
 						},
 					},
@@ -356,18 +545,22 @@ func TestParse(t *testing.T) {
 			"#{foo[1]}",
 			false,
 			&ast.Output{
-				Posx: ast.Pos{Column: 3, Line: 1},
+				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 10, Line: 1, Offset: 9},
 				Exprs: []ast.Node{
 					&ast.Index{
-						Posx: ast.Pos{Column: 3, Line: 1},
+						Posx: ast.Pos{Column: 3, Line: 1, Offset: 2},
+						Endx: ast.Pos{Column: 9, Line: 1, Offset: 8},
 						Target: &ast.VariableAccess{
 							Name: "foo",
-							Posx: ast.Pos{Column: 3, Line: 1},
+							Posx: ast.Pos{Column: 3, Line: 1, Offset: 2},
+							Endx: ast.Pos{Column: 6, Line: 1, Offset: 5},
 						},
 						Key: &ast.LiteralNode{
 							Value: 1,
 							Typex: ast.TInt,
-							Posx:  ast.Pos{Column: 7, Line: 1},
+							Posx:  ast.Pos{Column: 7, Line: 1, Offset: 6},
+							Endx:  ast.Pos{Column: 8, Line: 1, Offset: 7},
 						},
 					},
 				},
@@ -378,41 +571,48 @@ func TestParse(t *testing.T) {
 			"#{foo[1]} - #{bar[0]}",
 			false,
 			&ast.Output{
-				Posx: ast.Pos{Column: 3, Line: 1},
+				Posx: ast.Pos{Column: 1, Line: 1},
+				Endx: ast.Pos{Column: 22, Line: 1, Offset: 21},
 				Exprs: []ast.Node{
 					&ast.Index{
-						Posx: ast.Pos{Column: 3, Line: 1},
+						Posx: ast.Pos{Column: 3, Line: 1, Offset: 2},
+						Endx: ast.Pos{Column: 9, Line: 1, Offset: 8},
 						Target: &ast.VariableAccess{
 							Name: "foo",
-							Posx: ast.Pos{Column: 3, Line: 1},
+							Posx: ast.Pos{Column: 3, Line: 1, Offset: 2},
+							Endx: ast.Pos{Column: 6, Line: 1, Offset: 5},
 						},
 						Key: &ast.LiteralNode{
 							Value: 1,
 							Typex: ast.TInt,
-							Posx:  ast.Pos{Column: 7, Line: 1},
+							Posx:  ast.Pos{Column: 7, Line: 1, Offset: 6},
+							Endx:  ast.Pos{Column: 8, Line: 1, Offset: 7},
 						},
 					},
 					&ast.LiteralNode{
 						Value: " - ",
 						Typex: ast.TString,
-						Posx:  ast.Pos{Column: 10, Line: 1},
+						Posx:  ast.Pos{Column: 10, Line: 1, Offset: 9},
+						Endx:  ast.Pos{Column: 13, Line: 1, Offset: 12},
 					},
 					&ast.Index{
-						Posx: ast.Pos{Column: 15, Line: 1},
+						Posx: ast.Pos{Column: 15, Line: 1, Offset: 14},
+						Endx: ast.Pos{Column: 21, Line: 1, Offset: 20},
 						Target: &ast.VariableAccess{
 							Name: "bar",
-							Posx: ast.Pos{Column: 15, Line: 1},
+							Posx: ast.Pos{Column: 15, Line: 1, Offset: 14},
+							Endx: ast.Pos{Column: 18, Line: 1, Offset: 17},
 						},
 						Key: &ast.LiteralNode{
 							Value: 0,
 							Typex: ast.TInt,
-							Posx:  ast.Pos{Column: 19, Line: 1},
+							Posx:  ast.Pos{Column: 19, Line: 1, Offset: 18},
+							Endx:  ast.Pos{Column: 20, Line: 1, Offset: 19},
 						},
 					},
 				},
 			},
 		},
-
 		{
 			"#{foo[1][2]}",
 			true,
@@ -452,5 +652,150 @@ func TestParse(t *testing.T) {
 		if !reflect.DeepEqual(actual, tc.Result) {
 			t.Fatalf("\nBad : %#v\nHave: %#v\n\nInput: %s", tc.Result, actual, tc.Input)
 		}
+		if err != nil {
+			continue
+		}
+
+		// The tree must survive a round trip through JSON
+		data, err := ast.EncodeJSON(actual)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+		decoded, err := ast.DecodeJSON(data)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+		if !reflect.DeepEqual(decoded, actual) {
+			t.Fatalf("\nBad JSON: %s\n\nInput: %s", data, tc.Input)
+		}
+	}
+}
+
+func TestParseWithConfig_limits(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Config *ParseConfig
+		Error  error
+	}{
+		{
+			`#{foo(bar(baz(1)))}`,
+			&ParseConfig{MaxDepth: 5},
+			nil,
+		},
+
+		{
+			`#{foo(bar(baz(1)))}`,
+			&ParseConfig{MaxDepth: 4},
+			ErrMaxDepth,
+		},
+
+		{
+			`#{foo(1, 2, 3)}`,
+			&ParseConfig{MaxNodes: 5},
+			nil,
+		},
+
+		{
+			`#{foo(1, 2, 3)}`,
+			&ParseConfig{MaxNodes: 4},
+			ErrMaxNodes,
+		},
+
+		{
+			`#{"#{"#{1}"}"}`,
+			&ParseConfig{MaxInterpolationDepth: 3},
+			nil,
+		},
+
+		{
+			`#{"#{"#{1}"}"}`,
+			&ParseConfig{MaxInterpolationDepth: 2},
+			ErrMaxInterpolationDepth,
+		},
+	}
+
+	for _, tc := range cases {
+		_, err := ParseWithConfig(tc.Input, tc.Config)
+		if tc.Error == nil && err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+		if tc.Error != nil && !errors.Is(err, tc.Error) {
+			t.Fatalf("Bad error: %v\nExpected: %s\n\nInput: %s", err, tc.Error, tc.Input)
+		}
+	}
+}
+
+func TestParseWithConfig_limitsStopEarly(t *testing.T) {
+	// The limits are enforced while parsing, so they are hit before the
+	// syntax error at the end of the input is found.
+	cases := []struct {
+		Input  string
+		Config *ParseConfig
+		Error  error
+	}{
+		{
+			"#{foo(" + strings.Repeat("1, ", 100000) + "1)))}",
+			&ParseConfig{MaxNodes: 10},
+			ErrMaxNodes,
+		},
+
+		{
+			"#{-" + strings.Repeat("-", 100000) + "1)}",
+			&ParseConfig{MaxNodes: 10},
+			ErrMaxNodes,
+		},
+
+		{
+			"#{1" + strings.Repeat(" - 1", 100000) + ")}",
+			&ParseConfig{MaxDepth: 10},
+			ErrMaxDepth,
+		},
+	}
+
+	for _, tc := range cases {
+		_, err := ParseWithConfig(tc.Input, tc.Config)
+		if !errors.Is(err, tc.Error) {
+			t.Fatalf("Bad error: %v\nExpected: %s", err, tc.Error)
+		}
+	}
+}
+
+func TestParse_error(t *testing.T) {
+	cases := []struct {
+		Input string
+		Pos   ast.Pos
+		End   ast.Pos
+	}{
+		{
+			"#{foo(}",
+			ast.Pos{Column: 7, Line: 1, Offset: 6},
+			ast.Pos{Column: 8, Line: 1, Offset: 7},
+		},
+		{
+			"foo\n#{bar(a = 1, b)}",
+			ast.Pos{Column: 14, Line: 2, Offset: 17},
+			ast.Pos{Column: 15, Line: 2, Offset: 18},
+		},
+		{
+			"#{*1}",
+			ast.Pos{Column: 3, Line: 1, Offset: 2},
+			ast.Pos{Column: 4, Line: 1, Offset: 3},
+		},
+		{
+			"#{\"foo}",
+			ast.Pos{Column: 3, Line: 1, Offset: 2},
+			ast.Pos{Column: 8, Line: 1, Offset: 7},
+		},
+	}
+
+	for _, tc := range cases {
+		_, err := Parse(tc.Input)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Fatalf("Error: %#v\n\nInput: %s", err, tc.Input)
+		}
+		if perr.Pos != tc.Pos || perr.End != tc.End {
+			t.Fatalf("Bad: %#v-%#v (%s)\n\nInput: %s", perr.Pos, perr.End, err, tc.Input)
+		}
 	}
 }
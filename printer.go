@@ -0,0 +1,291 @@
+package stop
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/patdhlk/stop/ast"
+)
+
+// Print returns the canonical source of the program n, which parses back
+// to the same tree (apart from positions) if n was returned by Parse.
+//
+// Literal text is escaped so that it isn't mistaken for an interpolation,
+// binary operators are surrounded by spaces and parentheses are only added
+// where they are needed. Note that all operators have the same precedence
+// and group to the left, so 1 + 2 * 3 is (1 + 2) * 3.
+//
+// Trees that can't be written as a program, such as those with custom
+// nodes, names that aren't identifiers or an Index whose target isn't a
+// variable, return an error.
+func Print(n ast.Node) (string, error) {
+	var p printer
+	p.top(n)
+	if p.err != nil {
+		return "", p.err
+	}
+
+	return p.b.String(), nil
+}
+
+// printer writes the source of a tree. Only the first error is kept.
+type printer struct {
+	b   strings.Builder
+	err error
+}
+
+// top writes the root of a program, which is in literal mode.
+func (p *printer) top(n ast.Node) {
+	if out, ok := n.(*ast.Output); ok {
+		p.parts(out.Exprs, false)
+		return
+	}
+
+	p.parts([]ast.Node{n}, false)
+}
+
+// parts writes the expressions of an Output, string literals as text and
+// everything else as interpolations. A string literal that follows another
+// one is written as an interpolation as well, since adjacent text would be
+// parsed as a single literal.
+func (p *printer) parts(exprs []ast.Node, quoted bool) {
+	afterText := false
+	for i, expr := range exprs {
+		lit, ok := expr.(*ast.LiteralNode)
+		if !ok || lit.Typex != ast.TString || afterText {
+			p.b.WriteString("#{")
+			p.expr(expr)
+			p.b.WriteString("}")
+			afterText = false
+			continue
+		}
+		afterText = true
+
+		s, ok := lit.Value.(string)
+		if !ok {
+			p.fail(expr, "string literal has value of type %T", lit.Value)
+			return
+		}
+
+		// A # at the end of the text would start the interpolation that
+		// follows it
+		next := i+1 < len(exprs)
+		p.text(s, quoted, next)
+	}
+}
+
+// text writes literal text. Within quoted strings, quotes, backslashes and
+// newlines are escaped as well.
+func (p *printer) text(s string, quoted, interpolationNext bool) {
+	for i, c := range s {
+		switch {
+		case c == '#':
+			// ## is a # of its own, so any # that is followed by a { or #
+			// has to be doubled.
+			var next byte
+			if i+1 < len(s) {
+				next = s[i+1]
+			} else if interpolationNext {
+				next = '{'
+			}
+
+			if next == '{' || next == '#' {
+				p.b.WriteString("##")
+				continue
+			}
+		case quoted && c == '"':
+			p.b.WriteString(`\"`)
+			continue
+		case quoted && c == '\\':
+			p.b.WriteString(`\\`)
+			continue
+		case quoted && c == '\n':
+			p.b.WriteString(`\n`)
+			continue
+		}
+
+		p.b.WriteRune(c)
+	}
+}
+
+// expr writes an expression within an interpolation.
+func (p *printer) expr(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.LiteralNode:
+		p.literal(n)
+	case *ast.Output:
+		p.b.WriteByte('"')
+		p.parts(n.Exprs, true)
+		p.b.WriteByte('"')
+	case *ast.VariableAccess:
+		p.identifier(n, n.Name)
+	case *ast.Index:
+		target, ok := n.Target.(*ast.VariableAccess)
+		if !ok {
+			p.fail(n, "target of index must be a variable, is %T", n.Target)
+			return
+		}
+
+		p.identifier(target, target.Name)
+		p.b.WriteByte('[')
+		p.expr(n.Key)
+		p.b.WriteByte(']')
+	case *ast.Call:
+		p.call(n)
+	case *ast.Arithmetic:
+		p.arithmetic(n)
+	default:
+		p.fail(n, "can't print node of type %T", n)
+	}
+}
+
+func (p *printer) literal(n *ast.LiteralNode) {
+	switch v := n.Value.(type) {
+	case string:
+		p.b.WriteByte('"')
+		p.text(v, true, false)
+		p.b.WriteByte('"')
+	case int:
+		p.b.WriteString(strconv.Itoa(v))
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			p.fail(n, "can't print float %v", v)
+			return
+		}
+
+		// Floats need a period to not be read as ints
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+
+		p.b.WriteString(s)
+	case bool:
+		p.b.WriteString(strconv.FormatBool(v))
+	default:
+		p.fail(n, "can't print literal of type %T", n.Value)
+	}
+}
+
+func (p *printer) call(n *ast.Call) {
+	p.identifier(n, n.Func)
+	p.b.WriteByte('(')
+	for i, arg := range n.Args {
+		if i > 0 {
+			p.b.WriteString(", ")
+		}
+		p.expr(arg)
+	}
+	for i, arg := range n.NamedArgs {
+		if i > 0 || len(n.Args) > 0 {
+			p.b.WriteString(", ")
+		}
+		p.identifier(n, arg.Name)
+		p.b.WriteString(" = ")
+		p.expr(arg.Value)
+	}
+	p.b.WriteByte(')')
+}
+
+// arithmeticOps are the operators of the arithmetic operations.
+var arithmeticOps = map[ast.ArithmeticOp]string{
+	ast.ArithmeticOpAdd: "+",
+	ast.ArithmeticOpSub: "-",
+	ast.ArithmeticOpMul: "*",
+	ast.ArithmeticOpDiv: "/",
+	ast.ArithmeticOpMod: "%",
+}
+
+// arithmetic writes an arithmetic operation. Since all operators have the
+// same precedence and group to the left, only operands to the right of an
+// operator that are operations themselves need parentheses. A negation,
+// which the parser turns into a subtraction from 0, is written as such.
+func (p *printer) arithmetic(n *ast.Arithmetic) {
+	op, ok := arithmeticOps[n.Op]
+	if !ok || len(n.Exprs) == 0 {
+		p.fail(n, "invalid arithmetic operation")
+		return
+	}
+
+	if isNegation(n) {
+		p.b.WriteString("-")
+		p.operand(n.Exprs[1])
+		return
+	}
+
+	p.expr(n.Exprs[0])
+	for _, expr := range n.Exprs[1:] {
+		p.b.WriteString(" " + op + " ")
+		p.operand(expr)
+	}
+}
+
+// operand writes an operand to the right of an operator.
+func (p *printer) operand(n ast.Node) {
+	if a, ok := n.(*ast.Arithmetic); ok && !isNegation(a) {
+		p.b.WriteByte('(')
+		p.expr(n)
+		p.b.WriteByte(')')
+		return
+	}
+
+	p.expr(n)
+}
+
+// isNegation returns true if n subtracts from the literal 0, which is
+// what the parser turns -x into.
+func isNegation(n *ast.Arithmetic) bool {
+	if n.Op != ast.ArithmeticOpSub || len(n.Exprs) != 2 {
+		return false
+	}
+
+	lit, ok := n.Exprs[0].(*ast.LiteralNode)
+	return ok && lit.Typex == ast.TInt && lit.Value == 0
+}
+
+// identifier writes the name of a variable, function or argument.
+func (p *printer) identifier(n ast.Node, name string) {
+	if !isIdentifier(name) {
+		p.fail(n, "%q is not a valid identifier", name)
+		return
+	}
+
+	p.b.WriteString(name)
+}
+
+// isIdentifier returns true if the lexer reads s as a single identifier.
+func isIdentifier(s string) bool {
+	if s == "" || s == "true" || s == "false" {
+		return false
+	}
+
+	var last rune
+	for i, c := range s {
+		switch {
+		case unicode.IsLetter(c), c == '_', c == '.':
+		case unicode.IsNumber(c), c == '-':
+			// These would start a number or an operator
+			if i == 0 {
+				return false
+			}
+		case c == '*':
+			if last != '.' {
+				return false
+			}
+		default:
+			return false
+		}
+
+		last = c
+	}
+
+	return true
+}
+
+func (p *printer) fail(n ast.Node, format string, args ...interface{}) {
+	if p.err == nil {
+		p.err = errorAt(n, format, args...)
+	}
+}
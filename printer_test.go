@@ -0,0 +1,157 @@
+package stop
+
+import (
+	"math"
+	"testing"
+
+	"github.com/patdhlk/stop/ast"
+)
+
+func TestPrint(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Output string
+	}{
+		{"", ""},
+		{"foo", "foo"},
+		{`foo "bar" \n`, `foo "bar" \n`},
+		{"##{var.foo}", "##{var.foo}"},
+		{"a # b ## c", "a # b # c"},
+		{"a ####b", "a ###b"},
+		{"#", "#"},
+		{"##", "#"},
+		{"a#", "a#"},
+		{"#{x}#", "#{x}#"},
+		{"#{x}##", "#{x}#"},
+		{`a#{"b"}`, `a#{"b"}`},
+		{`#{"a"}#{"b"}`, `a#{"b"}`},
+		{`#{"a" }x`, `a#{"x"}`},
+		{`#{"a"}#{"b"}#{"c"}`, `a#{"b"}c`},
+		{`#{f("a#{"b"}")}`, `#{f("a#{"b"}")}`},
+		{"foo ####{bar}", "foo ####{bar}"},
+		{"foo #{var.bar} baz", "foo #{var.bar} baz"},
+		{"foo ###{var.bar}", "foo ###{var.bar}"},
+		{`#{  lower( var.foo )  }`, `#{lower(var.foo)}`},
+		{`#{"foo \"bar\" \\ \n ##{"}`, `foo "bar" \ ` + "\n" + ` ##{`},
+		{`#{foo("a \"b\" \\ \n ##{x}")}`, `#{foo("a \"b\" \\ \n ##{x}")}`},
+		{`#{foo("a#")}`, `#{foo("a#")}`},
+		{`#{foo("a###{b}")}`, `#{foo("a###{b}")}`},
+		{`foo #{"bar #{baz}"}`, `foo #{"bar #{baz}"}`},
+		{`#{"#{a}#{b}"}`, `#{a}#{b}`},
+		{`#{f("#{a}#{b}")}`, `#{f("#{a}#{b}")}`},
+		{"#{42} #{3.5} #{true} #{false}", "#{42} #{3.5} #{true} #{false}"},
+		{"#{1+2}", "#{1 + 2}"},
+		{"#{1 + 2 * 3}", "#{1 + 2 * 3}"},
+		{"#{1 + (2 * 3)}", "#{1 + (2 * 3)}"},
+		{"#{(1 + 2) * 3}", "#{1 + 2 * 3}"},
+		{"#{((a))}", "#{a}"},
+		{"#{-5}", "#{-5}"},
+		{"#{-(a + b)}", "#{-(a + b)}"},
+		{"#{-a * b}", "#{-a * b}"},
+		{"#{a - -b}", "#{a - -b}"},
+		{"#{a % (b / -c)}", "#{a % (b / -c)}"},
+		{"#{foo(bar, width=10)}", "#{foo(bar, width = 10)}"},
+		{"#{foo(width = 10, pad = 1 + 2)}", "#{foo(width = 10, pad = 1 + 2)}"},
+		{"#{foo()}", "#{foo()}"},
+		{"#{foo[1]} - #{bar[\"x\"]}", `#{foo[1]} - #{bar["x"]}`},
+		{"#{var.foo.*.id}", "#{var.foo.*.id}"},
+		{"#{foo(bar(1), baz[a + 1])}", "#{foo(bar(1), baz[a + 1])}"},
+		{"a\n#{b}\nc", "a\n#{b}\nc"},
+	}
+
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+
+		actual, err := Print(node)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+		if actual != tc.Output {
+			t.Fatalf("Bad: %q\n\nExpected: %q\n\nInput: %s", actual, tc.Output, tc.Input)
+		}
+
+		// The printed program must parse back to the same tree
+		reparsed, err := Parse(actual)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s\n\nPrinted: %s", err, tc.Input, actual)
+		}
+		if !ast.Equal(reparsed, node, &ast.EqualOptions{IgnorePositions: true}) {
+			t.Fatalf("Bad: %#v\n\nExpected: %#v\n\nInput: %s", reparsed, node, tc.Input)
+		}
+	}
+}
+
+func TestPrint_nodes(t *testing.T) {
+	cases := []struct {
+		Node   ast.Node
+		Output string
+		Error  bool
+	}{
+		{
+			&ast.LiteralNode{Value: 3.0, Typex: ast.TFloat},
+			"#{3.0}",
+			false,
+		},
+		{
+			&ast.LiteralNode{Value: math.Inf(1), Typex: ast.TFloat},
+			"",
+			true,
+		},
+		{
+			&ast.Output{Exprs: []ast.Node{
+				&ast.LiteralNode{Value: "a#", Typex: ast.TString},
+				&ast.VariableAccess{Name: "b"},
+			}},
+			"a###{b}",
+			false,
+		},
+		{
+			&ast.Arithmetic{
+				Op: ast.ArithmeticOpAdd,
+				Exprs: []ast.Node{
+					&ast.VariableAccess{Name: "a"},
+					&ast.VariableAccess{Name: "b"},
+					&ast.VariableAccess{Name: "c"},
+				},
+			},
+			"#{a + b + c}",
+			false,
+		},
+		{
+			&ast.VariableAccess{Name: "foo bar"},
+			"",
+			true,
+		},
+		{
+			&ast.VariableAccess{Name: "true"},
+			"",
+			true,
+		},
+		{
+			&ast.Call{Func: "-foo"},
+			"",
+			true,
+		},
+		{
+			&ast.Index{
+				Target: &ast.LiteralNode{Value: "foo", Typex: ast.TString},
+				Key:    &ast.LiteralNode{Value: 1, Typex: ast.TInt},
+			},
+			"",
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		actual, err := Print(tc.Node)
+		if (err != nil) != tc.Error {
+			t.Fatalf("Error: %v\n\nNode: %#v", err, tc.Node)
+		}
+		if actual != tc.Output {
+			t.Fatalf("Bad: %q\n\nExpected: %q", actual, tc.Output)
+		}
+	}
+}
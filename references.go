@@ -0,0 +1,146 @@
+package stop
+
+import (
+	"unicode/utf8"
+
+	"github.com/patdhlk/stop/ast"
+)
+
+// ReferenceKind is the kind of name a Reference refers to.
+type ReferenceKind int
+
+const (
+	// RefVariable is a reference to a variable.
+	RefVariable ReferenceKind = iota
+
+	// RefFunction is a reference to a function by a call.
+	RefFunction
+)
+
+func (k ReferenceKind) String() string {
+	switch k {
+	case RefVariable:
+		return "variable"
+	case RefFunction:
+		return "function"
+	default:
+		return "unknown"
+	}
+}
+
+// Reference is a use of a variable or function in a program.
+type Reference struct {
+	Kind ReferenceKind
+	Name string
+
+	// Path are the keys of the indexes into a variable, i.e. ["web", 0]
+	// for the access of servers["web"][0]. It ends before the first key
+	// that isn't a literal, since its value is only known when the
+	// program is evaluated.
+	Path []interface{}
+
+	// Pos and End are the range of the reference: the name of a function,
+	// or a variable along with the indexes in its Path.
+	Pos ast.Pos
+	End ast.Pos
+}
+
+// References returns the variables and functions that the program n
+// refers to, in the order they appear in the source, without evaluating
+// it. Every use is returned, so names may appear more than once. This
+// includes references within index keys and the arguments of calls.
+//
+// References should be called before the program is type checked, which
+// adds calls to builtin functions for conversions and arithmetic.
+func References(n ast.Node) []Reference {
+	var result []Reference
+
+	// Variables and indexes that are part of a reference already found
+	seen := make(map[ast.Node]bool)
+
+	ast.Inspect(n, func(c *ast.Cursor) error {
+		if seen[c.Node()] {
+			return nil
+		}
+
+		switch n := c.Node().(type) {
+		case *ast.Call:
+			// Parentheses around a call aren't part of its range, so the
+			// call starts at the name of the function
+			result = append(result, Reference{
+				Kind: RefFunction,
+				Name: n.Func,
+				Pos:  n.Pos(),
+				End:  nameEnd(n.Pos(), n.Func),
+			})
+		case *ast.Index:
+			if ref, ok := indexReference(n, seen); ok {
+				result = append(result, ref)
+			}
+		case *ast.VariableAccess:
+			result = append(result, Reference{
+				Kind: RefVariable,
+				Name: n.Name,
+				Pos:  n.Pos(),
+				End:  ast.End(n),
+			})
+		}
+
+		return nil
+	}, nil)
+
+	return result
+}
+
+// indexReference returns the reference to the variable at the bottom of a
+// chain of indexes, marking the indexes and the variable as seen. It
+// returns false if the chain doesn't end in a variable.
+func indexReference(n *ast.Index, seen map[ast.Node]bool) (Reference, bool) {
+	// Collect the chain from the outermost index inwards
+	var chain []*ast.Index
+	var target ast.Node = n
+	for {
+		index, ok := target.(*ast.Index)
+		if !ok {
+			break
+		}
+
+		chain = append(chain, index)
+		seen[index] = true
+		target = index.Target
+	}
+
+	v, ok := target.(*ast.VariableAccess)
+	if !ok {
+		return Reference{}, false
+	}
+
+	ref := Reference{
+		Kind: RefVariable,
+		Name: v.Name,
+		Pos:  v.Pos(),
+		End:  ast.End(v),
+	}
+	seen[v] = true
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		lit, ok := chain[i].Key.(*ast.LiteralNode)
+		if !ok {
+			break
+		}
+
+		ref.Path = append(ref.Path, lit.Value)
+		ref.End = ast.End(chain[i])
+	}
+
+	return ref, true
+}
+
+// nameEnd returns the end of the identifier name that starts at pos.
+func nameEnd(pos ast.Pos, name string) ast.Pos {
+	return ast.Pos{
+		Column: pos.Column + utf8.RuneCountInString(name),
+		Line:   pos.Line,
+		Offset: pos.Offset + len(name),
+	}
+}
@@ -0,0 +1,101 @@
+package stop
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/patdhlk/stop/ast"
+)
+
+func TestReferences(t *testing.T) {
+	p := func(col int) ast.Pos {
+		return ast.Pos{Column: col, Line: 1, Offset: col - 1}
+	}
+
+	cases := []struct {
+		Input  string
+		Result []Reference
+	}{
+		{
+			"foo",
+			nil,
+		},
+
+		{
+			`foo #{upper(var.list[var.i])} #{var.map["a"]} #{len(var.list)}`,
+			[]Reference{
+				{Kind: RefFunction, Name: "upper", Pos: p(7), End: p(12)},
+				{Kind: RefVariable, Name: "var.list", Pos: p(13), End: p(21)},
+				{Kind: RefVariable, Name: "var.i", Pos: p(22), End: p(27)},
+				{Kind: RefVariable, Name: "var.map", Path: []interface{}{"a"}, Pos: p(33), End: p(45)},
+				{Kind: RefFunction, Name: "len", Pos: p(49), End: p(52)},
+				{Kind: RefVariable, Name: "var.list", Pos: p(53), End: p(61)},
+			},
+		},
+
+		{
+			`#{"#{var.a}" + -var.b[0]}`,
+			[]Reference{
+				{Kind: RefVariable, Name: "var.a", Pos: p(6), End: p(11)},
+				{Kind: RefVariable, Name: "var.b", Path: []interface{}{0}, Pos: p(17), End: p(25)},
+			},
+		},
+
+		{
+			`#{join(",", var.list, sep = var.sep)}`,
+			[]Reference{
+				{Kind: RefFunction, Name: "join", Pos: p(3), End: p(7)},
+				{Kind: RefVariable, Name: "var.list", Pos: p(13), End: p(21)},
+				{Kind: RefVariable, Name: "var.sep", Pos: p(29), End: p(36)},
+			},
+		},
+
+		{
+			`#{(lower(x))} #{( x )} #{((var.a) + 1)}`,
+			[]Reference{
+				{Kind: RefFunction, Name: "lower", Pos: p(4), End: p(9)},
+				{Kind: RefVariable, Name: "x", Pos: p(10), End: p(11)},
+				{Kind: RefVariable, Name: "x", Pos: p(19), End: p(20)},
+				{Kind: RefVariable, Name: "var.a", Pos: p(28), End: p(33)},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+
+		actual := References(node)
+		if !reflect.DeepEqual(actual, tc.Result) {
+			t.Fatalf("Bad: %#v\n\nExpected: %#v\n\nInput: %s", actual, tc.Result, tc.Input)
+		}
+	}
+}
+
+func TestReferences_indexChain(t *testing.T) {
+	// servers["web"][var.i][0], which can't be parsed but built
+	servers := &ast.VariableAccess{Name: "servers"}
+	i := &ast.VariableAccess{Name: "var.i"}
+	node := &ast.Index{
+		Target: &ast.Index{
+			Target: &ast.Index{
+				Target: servers,
+				Key:    &ast.LiteralNode{Value: "web", Typex: ast.TString},
+			},
+			Key: i,
+		},
+		Key: &ast.LiteralNode{Value: 0, Typex: ast.TInt},
+	}
+
+	expected := []Reference{
+		{Kind: RefVariable, Name: "servers", Path: []interface{}{"web"}},
+		{Kind: RefVariable, Name: "var.i"},
+	}
+
+	actual := References(node)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("Bad: %#v", actual)
+	}
+}
@@ -0,0 +1,263 @@
+package stop
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/patdhlk/stop/ast"
+)
+
+// StructScope is an ast.Scope that exposes the fields of a Go struct as
+// variables. Field names are taken from the "stop" struct tag, falling back
+// to the name of the field; fields tagged with "-" and unexported fields are
+// skipped. Fields of embedded structs without a tag are promoted.
+//
+// Nested structs and maps with string keys are traversed with dots, so
+// with the prefix "config." the variable "config.server.port" is the field
+// tagged "port" within the field tagged "server". Values are converted when
+// they are looked up, keeping their types: integers become TInt, floats
+// TFloat, bools TBool, slices TList and structs and maps TMap.
+//
+// Functions and variables that don't start with Prefix or aren't found in
+// the struct are looked up in Parent.
+type StructScope struct {
+	Parent ast.Scope
+
+	// Prefix is the prefix of all variable names in the struct, such
+	// as "config.". It may be empty.
+	Prefix string
+
+	// Value is the struct or a pointer to it. It is read on every lookup
+	// and never copied, so changes to it are visible immediately.
+	Value interface{}
+}
+
+func (s *StructScope) LookupFunc(n string) (ast.Function, bool) {
+	if s == nil || s.Parent == nil {
+		return ast.Function{}, false
+	}
+
+	return s.Parent.LookupFunc(n)
+}
+
+func (s *StructScope) LookupVar(n string) (ast.Variable, bool) {
+	if s == nil {
+		return ast.Variable{}, false
+	}
+
+	if result, ok := s.variable(n); ok {
+		return result, true
+	}
+
+	if s.Parent == nil {
+		return ast.Variable{}, false
+	}
+
+	return s.Parent.LookupVar(n)
+}
+
+// HasVar implements ast.VarExister. Like LookupVar, it only reports the
+// variables whose values can be converted.
+func (s *StructScope) HasVar(n string) bool {
+	if s == nil {
+		return false
+	}
+
+	if _, ok := s.variable(n); ok {
+		return true
+	}
+
+	return ast.HasVar(s.Parent, n)
+}
+
+// VarNames implements ast.NameLister. It returns the names of all fields
+// and map entries, including nested ones, along with the names of the
+// parent scope.
+func (s *StructScope) VarNames() []string {
+	if s == nil {
+		return nil
+	}
+
+	var names []string
+	var walk func(prefix string, v reflect.Value, depth int)
+	walk = func(prefix string, v reflect.Value, depth int) {
+		// Guard against cycles through pointers
+		if depth > maxStructScopeDepth {
+			return
+		}
+
+		v = indirect(v)
+		switch v.Kind() {
+		case reflect.Struct:
+			eachStructField(v, func(name string, field reflect.Value) bool {
+				// Nil fields can't be looked up
+				if !indirect(field).IsValid() {
+					return true
+				}
+
+				names = append(names, prefix+name)
+				walk(prefix+name+".", field, depth+1)
+				return true
+			})
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return
+			}
+
+			for _, k := range v.MapKeys() {
+				// Nil entries can't be looked up either
+				entry := v.MapIndex(k)
+				if !indirect(entry).IsValid() {
+					continue
+				}
+
+				names = append(names, prefix+k.String())
+				walk(prefix+k.String()+".", entry, depth+1)
+			}
+		}
+	}
+	walk(s.Prefix, reflect.ValueOf(s.Value), 0)
+
+	// Names in the struct shadow those of the parent
+	seen := make(map[string]bool, len(names))
+	for _, n := range names {
+		seen[n] = true
+	}
+	for _, n := range ast.VarNames(s.Parent) {
+		if !seen[n] {
+			names = append(names, n)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// FuncNames implements ast.NameLister by returning the names of the
+// parent.
+func (s *StructScope) FuncNames() []string {
+	if s == nil {
+		return nil
+	}
+
+	return ast.FuncNames(s.Parent)
+}
+
+// maxStructScopeDepth is the depth up to which VarNames lists the nested
+// fields of a StructScope.
+const maxStructScopeDepth = 8
+
+// variable looks up the variable with the given name and converts its
+// value. It returns false if there's no such variable or the value can't
+// be converted.
+func (s *StructScope) variable(n string) (ast.Variable, bool) {
+	v, ok := s.lookup(n)
+	if !ok {
+		return ast.Variable{}, false
+	}
+
+	result, err := reflectToVariable(v)
+	return result, err == nil
+}
+
+// lookup finds the value for the variable with the given name.
+func (s *StructScope) lookup(n string) (reflect.Value, bool) {
+	if !strings.HasPrefix(n, s.Prefix) {
+		return reflect.Value{}, false
+	}
+
+	v := reflect.ValueOf(s.Value)
+	for _, part := range strings.Split(n[len(s.Prefix):], ".") {
+		v = indirect(v)
+		switch v.Kind() {
+		case reflect.Struct:
+			v = structField(v, part)
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}, false
+			}
+
+			v = v.MapIndex(reflect.ValueOf(part).Convert(v.Type().Key()))
+		default:
+			return reflect.Value{}, false
+		}
+
+		if !v.IsValid() {
+			return reflect.Value{}, false
+		}
+	}
+
+	v = indirect(v)
+	return v, v.IsValid()
+}
+
+// indirect follows pointers and interfaces to the value they point to.
+// The result is the zero Value if a nil pointer or interface is found.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+
+		v = v.Elem()
+	}
+
+	return v
+}
+
+// structField returns the field of the struct with the given STOP name,
+// or the zero Value if there isn't one.
+func structField(v reflect.Value, name string) reflect.Value {
+	var result reflect.Value
+	eachStructField(v, func(n string, field reflect.Value) bool {
+		if n == name {
+			result = field
+			return false
+		}
+
+		return true
+	})
+
+	return result
+}
+
+// eachStructField calls cb with the STOP name and value of every field
+// of the struct that is exposed to STOP, until cb returns false. It
+// returns false if cb did.
+func eachStructField(v reflect.Value, cb func(string, reflect.Value) bool) bool {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("stop")
+		if tag == "-" {
+			continue
+		}
+
+		// Promote the fields of embedded structs that have no tag
+		if f.Anonymous && tag == "" {
+			if ev := indirect(v.Field(i)); ev.Kind() == reflect.Struct {
+				if !eachStructField(ev, cb) {
+					return false
+				}
+
+				continue
+			}
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := tag
+		if name == "" {
+			name = f.Name
+		}
+
+		if !cb(name, v.Field(i)) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,288 @@
+package stop
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/patdhlk/stop/ast"
+)
+
+type testServerConfig struct {
+	Host string  `stop:"host"`
+	Port int     `stop:"port"`
+	TLS  *bool   `stop:"tls"`
+	Load float64 `stop:"load"`
+}
+
+type testMeta struct {
+	Owner string `stop:"owner"`
+}
+
+type testConfig struct {
+	testMeta
+
+	Name    string                      `stop:"name"`
+	Server  testServerConfig            `stop:"server"`
+	Servers []testServerConfig          `stop:"servers"`
+	Tags    []string                    `stop:"tags"`
+	Labels  map[string]string           `stop:"labels"`
+	Limits  map[string]testServerConfig `stop:"limits"`
+	Secret  string                      `stop:"-"`
+	Debug   bool
+	private string
+}
+
+func TestStructScope_impl(t *testing.T) {
+	var _ ast.Scope = new(StructScope)
+	var _ ast.VarExister = new(StructScope)
+}
+
+func TestStructScopeLookupVar(t *testing.T) {
+	tls := true
+	config := &testConfig{
+		testMeta: testMeta{Owner: "ops"},
+		Name:     "web",
+		Server:   testServerConfig{Host: "localhost", Port: 80, TLS: &tls, Load: 0.5},
+		Servers: []testServerConfig{
+			{Host: "a", Port: 1},
+		},
+		Tags:    []string{"a", "b"},
+		Labels:  map[string]string{"env": "prod"},
+		Limits:  map[string]testServerConfig{"x": {Port: 8080}},
+		Secret:  "hunter2",
+		Debug:   true,
+		private: "private",
+	}
+
+	scope := &StructScope{
+		Parent: &ast.BasicScope{
+			VarMap: map[string]ast.Variable{
+				"var.foo": ast.Variable{Type: ast.TString, Value: "bar"},
+			},
+		},
+		Prefix: "config.",
+		Value:  config,
+	}
+
+	cases := []struct {
+		Name     string
+		Ok       bool
+		Expected ast.Variable
+	}{
+		{"config.name", true, ast.Variable{Type: ast.TString, Value: "web"}},
+		{"config.owner", true, ast.Variable{Type: ast.TString, Value: "ops"}},
+		{"config.server.port", true, ast.Variable{Type: ast.TInt, Value: 80}},
+		{"config.server.tls", true, ast.Variable{Type: ast.TBool, Value: true}},
+		{"config.server.load", true, ast.Variable{Type: ast.TFloat, Value: 0.5}},
+		{"config.Debug", true, ast.Variable{Type: ast.TBool, Value: true}},
+		{"config.labels.env", true, ast.Variable{Type: ast.TString, Value: "prod"}},
+		{"config.limits.x.port", true, ast.Variable{Type: ast.TInt, Value: 8080}},
+		{
+			"config.tags",
+			true,
+			ast.Variable{
+				Type: ast.TList,
+				Value: []ast.Variable{
+					{Type: ast.TString, Value: "a"},
+					{Type: ast.TString, Value: "b"},
+				},
+			},
+		},
+		{
+			"config.servers",
+			true,
+			ast.Variable{
+				Type: ast.TList,
+				Value: []ast.Variable{
+					{
+						Type: ast.TMap,
+						Value: map[string]ast.Variable{
+							"host": {Type: ast.TString, Value: "a"},
+							"port": {Type: ast.TInt, Value: 1},
+							"load": {Type: ast.TFloat, Value: 0.0},
+						},
+					},
+				},
+			},
+		},
+		{"var.foo", true, ast.Variable{Type: ast.TString, Value: "bar"}},
+		{"config.Secret", false, ast.Variable{}},
+		{"config.secret", false, ast.Variable{}},
+		{"config.private", false, ast.Variable{}},
+		{"config.name.foo", false, ast.Variable{}},
+		{"config.labels.nope", false, ast.Variable{}},
+		{"name", false, ast.Variable{}},
+	}
+
+	for _, tc := range cases {
+		actual, ok := scope.LookupVar(tc.Name)
+		if ok != tc.Ok {
+			t.Fatalf("%s: expected found to be %t", tc.Name, tc.Ok)
+		}
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Fatalf("%s:\n     Bad: %#v\nExpected: %#v", tc.Name, actual, tc.Expected)
+		}
+		if ast.HasVar(scope, tc.Name) != tc.Ok {
+			t.Fatalf("%s: HasVar should be %t", tc.Name, tc.Ok)
+		}
+	}
+
+	// Lookups are not cached
+	config.Server.Port = 443
+	if v, _ := scope.LookupVar("config.server.port"); v.Value != 443 {
+		t.Fatalf("bad: %#v", v)
+	}
+}
+
+type testInnerValues struct {
+	Value ast.Variable `stop:"value"`
+	Name  string       `stop:"name"`
+}
+
+// testEmbedded is embedded to promote the fields of the struct it holds,
+// which can be read but not turned into interfaces.
+type testEmbedded interface{}
+
+func TestStructScope_unconvertible(t *testing.T) {
+	config := &struct {
+		testEmbedded
+
+		Events chan string `stop:"events"`
+		Hook   func()      `stop:"hook"`
+	}{
+		testEmbedded: testInnerValues{
+			Value: ast.Variable{Type: ast.TString, Value: "foo"},
+			Name:  "bar",
+		},
+	}
+
+	scope := &StructScope{Value: config}
+	for _, n := range []string{"events", "hook", "value"} {
+		if v, ok := scope.LookupVar(n); ok {
+			t.Fatalf("%s: should not be found: %#v", n, v)
+		}
+		if ast.HasVar(scope, n) {
+			t.Fatalf("%s: HasVar should be false", n)
+		}
+	}
+
+	if v, ok := scope.LookupVar("name"); !ok || v.Value != "bar" {
+		t.Fatalf("bad: %#v", v)
+	}
+}
+
+type testNode struct {
+	Name string    `stop:"name"`
+	Next *testNode `stop:"next"`
+	Peer *testNode `stop:"peer"`
+}
+
+func TestStructScope_cycle(t *testing.T) {
+	leaf := &testNode{Name: "leaf"}
+	n := &testNode{Name: "root", Next: leaf, Peer: leaf}
+	self := map[string]interface{}{"name": "self"}
+	self["self"] = self
+
+	scope := &StructScope{
+		Value: map[string]interface{}{
+			"n":    n,
+			"self": self,
+		},
+	}
+
+	// The same value may be reached more than once
+	if v, ok := scope.LookupVar("n"); !ok || len(v.Value.(map[string]ast.Variable)) != 3 {
+		t.Fatalf("bad: %#v", v)
+	}
+
+	// Values that contain themselves can't be converted, but the fields
+	// within them can
+	n.Next = n
+	for _, name := range []string{"n", "n.next", "self", "self.self"} {
+		if v, ok := scope.LookupVar(name); ok {
+			t.Fatalf("%s: should not be found: %#v", name, v)
+		}
+	}
+	if v, ok := scope.LookupVar("n.next.next.name"); !ok || v.Value != "root" {
+		t.Fatalf("bad: %#v", v)
+	}
+	if v, ok := scope.LookupVar("self.self.name"); !ok || v.Value != "self" {
+		t.Fatalf("bad: %#v", v)
+	}
+}
+
+func TestStructScope_eval(t *testing.T) {
+	config := testConfig{
+		Name:   "web",
+		Server: testServerConfig{Port: 80},
+		Tags:   []string{"a", "b"},
+	}
+
+	node, err := Parse("#{config.name}:#{config.server.port + 1}/#{config.tags[1]}")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	result, err := Eval(node, &EvalConfig{
+		Scope: &StructScope{Prefix: "config.", Value: config},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result.Value != "web:81/b" {
+		t.Fatalf("bad: %#v", result.Value)
+	}
+}
+
+func TestStructScopeVarNames(t *testing.T) {
+	scope := &StructScope{
+		Parent: &ast.BasicScope{
+			VarMap: map[string]ast.Variable{
+				"var.foo":     ast.Variable{Type: ast.TString, Value: "bar"},
+				"config.name": ast.Variable{Type: ast.TString, Value: "shadowed"},
+			},
+		},
+		Prefix: "config.",
+		Value: &testConfig{
+			Server: testServerConfig{Host: "localhost"},
+			Labels: map[string]string{"env": "prod"},
+			Secret: "hunter2",
+		},
+	}
+
+	expected := []string{
+		"config.Debug",
+		"config.labels",
+		"config.labels.env",
+		"config.limits",
+		"config.name",
+		"config.owner",
+		"config.server",
+		"config.server.host",
+		"config.server.load",
+		"config.server.port",
+		"config.servers",
+		"config.tags",
+		"var.foo",
+	}
+	if actual := scope.VarNames(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// Nil map entries can't be looked up, so they aren't listed
+	scope = &StructScope{
+		Value: map[string]interface{}{
+			"a": nil,
+			"b": "x",
+			"c": (*int)(nil),
+		},
+	}
+	for _, n := range []string{"a", "c"} {
+		if _, ok := scope.LookupVar(n); ok {
+			t.Fatalf("%s should not be found", n)
+		}
+	}
+	if actual := scope.VarNames(); !reflect.DeepEqual(actual, []string{"b"}) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
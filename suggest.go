@@ -0,0 +1,116 @@
+package stop
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/patdhlk/stop/ast"
+)
+
+// maxSuggestions is the maximum number of names suggested for an unknown
+// name.
+const maxSuggestions = 3
+
+// unknownVariable returns the error for the node n accessing the unknown
+// variable with the given name, suggesting similar names from the scope.
+func unknownVariable(s ast.Scope, n ast.Node, name string) *UnknownVariableError {
+	return &UnknownVariableError{
+		Pos:         n.Pos(),
+		End:         ast.End(n),
+		Name:        name,
+		Suggestions: suggest(name, ast.VarNames(s)),
+	}
+}
+
+// unknownFunction returns the error for the node n calling the unknown
+// function with the given name, suggesting similar names from the scope.
+func unknownFunction(s ast.Scope, n ast.Node, name string) *UnknownFunctionError {
+	return &UnknownFunctionError{
+		Pos:         n.Pos(),
+		End:         ast.End(n),
+		Name:        name,
+		Suggestions: suggest(name, ast.FuncNames(s)),
+	}
+}
+
+// suggest returns the candidates closest to name by edit distance, closest
+// first. Candidates that differ in more than a third of the characters
+// of name are too different to be meant and are left out.
+func suggest(name string, candidates []string) []string {
+	max := len([]rune(name)) / 3
+	if max < 1 {
+		max = 1
+	}
+
+	type match struct {
+		name     string
+		distance int
+	}
+	var matches []match
+	for _, c := range candidates {
+		if d := editDistance(name, c); d <= max {
+			matches = append(matches, match{c, d})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+
+		return matches[i].name < matches[j].name
+	})
+	if len(matches) > maxSuggestions {
+		matches = matches[:maxSuggestions]
+	}
+
+	var result []string
+	for _, m := range matches {
+		result = append(result, m.name)
+	}
+
+	return result
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+
+	// Only the previous row of the matrix is needed to compute the next
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(br)]
+}
+
+// didYouMean formats suggestions for an error message, i.e.
+// " (did you mean var.port or var.sort?)". It is empty if there are none.
+func didYouMean(suggestions []string) string {
+	switch len(suggestions) {
+	case 0:
+		return ""
+	case 1:
+		return " (did you mean " + suggestions[0] + "?)"
+	default:
+		last := len(suggestions) - 1
+		return " (did you mean " + strings.Join(suggestions[:last], ", ") +
+			" or " + suggestions[last] + "?)"
+	}
+}
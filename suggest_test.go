@@ -0,0 +1,107 @@
+package stop
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/patdhlk/stop/ast"
+)
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		A, B   string
+		Result int
+	}{
+		{"", "", 0},
+		{"foo", "", 3},
+		{"", "foo", 3},
+		{"foo", "foo", 0},
+		{"var.prot", "var.port", 2},
+		{"kitten", "sitting", 3},
+		{"größe", "grösse", 2},
+	}
+
+	for _, tc := range cases {
+		if actual := editDistance(tc.A, tc.B); actual != tc.Result {
+			t.Fatalf("%q, %q: bad: %d", tc.A, tc.B, actual)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"var.port", "var.sort", "var.host", "var.name", "var.ports"}
+	cases := []struct {
+		Name   string
+		Result []string
+	}{
+		{"var.prot", []string{"var.port"}},
+		{"var.pot", []string{"var.port", "var.host", "var.ports"}},
+		{"var.nmae", []string{"var.name"}},
+		{"foo", nil},
+	}
+
+	for _, tc := range cases {
+		if actual := suggest(tc.Name, candidates); !reflect.DeepEqual(actual, tc.Result) {
+			t.Fatalf("%s: bad: %#v", tc.Name, actual)
+		}
+	}
+}
+
+func TestIdentifierCheck_suggestions(t *testing.T) {
+	scope := &ast.BasicScope{
+		VarMap: map[string]ast.Variable{
+			"var.port": ast.Variable{Type: ast.TInt, Value: 80},
+			"var.host": ast.Variable{Type: ast.TString, Value: "localhost"},
+		},
+		FuncMap: map[string]ast.Function{
+			"lower": ast.Function{ReturnType: ast.TString},
+			"upper": ast.Function{ReturnType: ast.TString},
+		},
+	}
+
+	cases := []struct {
+		Input string
+		Scope ast.Scope
+		Error string
+	}{
+		{
+			"#{var.prot}",
+			scope,
+			"1:3: unknown variable accessed: var.prot (did you mean var.port?)",
+		},
+		{
+			"#{lowr()}",
+			scope,
+			"1:3: unknown function called: lowr (did you mean lower?)",
+		},
+		{
+			"#{var.xyz}",
+			scope,
+			"1:3: unknown variable accessed: var.xyz",
+		},
+		{
+			"#{var.prot}",
+			&ast.ResolverScope{Parent: scope},
+			"1:3: unknown variable accessed: var.prot (did you mean var.port?)",
+		},
+		{
+			// Scopes that can't list their names give no suggestions
+			"#{var.prot}",
+			struct{ ast.Scope }{scope},
+			"1:3: unknown variable accessed: var.prot",
+		},
+	}
+
+	for _, tc := range cases {
+		node, err := Parse(tc.Input)
+		if err != nil {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+
+		visitor := &IdentifierCheck{Scope: tc.Scope}
+		err = visitor.Visit(node)
+		if err == nil || err.Error() != tc.Error {
+			t.Fatalf("Error: %s\n\nInput: %s", err, tc.Input)
+		}
+	}
+}
@@ -1,21 +1,45 @@
-//line grammar.y:5
+// Code generated by goyacc -p parser -o y.go grammar.y. DO NOT EDIT.
+
+//line grammar.y:6
 package stop
 
 import __yyfmt__ "fmt"
 
-//line grammar.y:7
+//line grammar.y:6
+
 import (
 	"fmt"
 
 	"github.com/patdhlk/stop/ast"
 )
 
-//line grammar.y:16
+// callArgs are the arguments of a function call while it is being parsed.
+type callArgs struct {
+	positional []ast.Node
+	named      []*ast.NamedArg
+}
+
+// span is a node along with the range of the source it was parsed from,
+// which for an interpolation includes the #{ and } around it and for an
+// expression the parentheses around it. The node keeps its own range.
+type span struct {
+	node     ast.Node
+	pos, end ast.Pos
+}
+
+// nodeSpan returns the span of a node that covers just the node.
+func nodeSpan(n ast.Node) span {
+	return span{node: n, pos: n.Pos(), end: ast.End(n)}
+}
+
+//line grammar.y:35
 type parserSymType struct {
 	yys      int
 	node     ast.Node
 	nodeList []ast.Node
-	str      string
+	span     span
+	callArgs callArgs
+	namedArg *ast.NamedArg
 	token    *parserToken
 }
 
@@ -26,14 +50,15 @@ const PROGRAM_STRING_END = 57349
 const PAREN_LEFT = 57350
 const PAREN_RIGHT = 57351
 const COMMA = 57352
-const SQUARE_BRACKET_LEFT = 57353
-const SQUARE_BRACKET_RIGHT = 57354
-const ARITH_OP = 57355
-const IDENTIFIER = 57356
-const INTEGER = 57357
-const FLOAT = 57358
-const BOOL = 57359
-const STRING = 57360
+const EQUAL = 57353
+const SQUARE_BRACKET_LEFT = 57354
+const SQUARE_BRACKET_RIGHT = 57355
+const ARITH_OP = 57356
+const IDENTIFIER = 57357
+const INTEGER = 57358
+const FLOAT = 57359
+const BOOL = 57360
+const STRING = 57361
 
 var parserToknames = [...]string{
 	"$end",
@@ -46,6 +71,7 @@ var parserToknames = [...]string{
 	"PAREN_LEFT",
 	"PAREN_RIGHT",
 	"COMMA",
+	"EQUAL",
 	"SQUARE_BRACKET_LEFT",
 	"SQUARE_BRACKET_RIGHT",
 	"ARITH_OP",
@@ -55,83 +81,82 @@ var parserToknames = [...]string{
 	"BOOL",
 	"STRING",
 }
+
 var parserStatenames = [...]string{}
 
 const parserEofCode = 1
 const parserErrCode = 2
 const parserInitialStackSize = 16
 
-//line grammar.y:208
+//line grammar.y:302
 
 //line yacctab:1
-var parserExca = [...]int{
+var parserExca = [...]int8{
 	-1, 1,
 	1, -1,
 	-2, 0,
 }
 
-const parserNprod = 22
 const parserPrivate = 57344
 
-var parserTokenNames []string
-var parserStates []string
-
-const parserLast = 39
+const parserLast = 52
 
-var parserAct = [...]int{
-
-	9, 7, 30, 18, 24, 17, 18, 21, 18, 3,
-	22, 19, 8, 18, 1, 6, 20, 28, 29, 23,
-	25, 8, 26, 27, 7, 11, 2, 4, 10, 5,
-	31, 0, 0, 15, 16, 12, 13, 14, 6,
+var parserAct = [...]int8{
+	9, 24, 27, 33, 18, 7, 18, 18, 17, 3,
+	1, 19, 8, 25, 7, 5, 20, 18, 10, 23,
+	6, 8, 26, 29, 15, 16, 12, 13, 14, 6,
+	4, 7, 34, 36, 35, 10, 21, 11, 2, 32,
+	22, 15, 28, 12, 13, 14, 6, 21, 0, 30,
+	31, 22,
 }
-var parserPact = [...]int{
 
-	-3, -1000, -3, -1000, -1000, -1000, -1000, 20, -1000, 0,
-	20, -3, -1000, -1000, -1000, 20, -1, -1000, 20, -5,
-	-1000, 20, 20, -1000, -1000, 8, -7, -10, -1000, 20,
-	-1000, -7,
+var parserPact = [...]int16{
+	1, -1000, 1, -1000, -1000, -1000, -1000, 10, -1000, 3,
+	10, 1, -1000, -1000, -1000, 10, 39, -1000, 10, -8,
+	-1000, 27, 10, -1000, -1000, 40, -7, -1000, 28, -10,
+	-1000, 27, 10, -1000, -7, -1000, -7,
 }
-var parserPgo = [...]int{
 
-	0, 0, 29, 27, 25, 9, 20, 14,
+var parserPgo = [...]int8{
+	0, 30, 0, 15, 37, 9, 13, 2, 10,
 }
-var parserR1 = [...]int{
 
-	0, 7, 7, 4, 4, 5, 5, 2, 1, 1,
-	1, 1, 1, 1, 1, 1, 1, 1, 6, 6,
-	6, 3,
+var parserR1 = [...]int8{
+	0, 8, 8, 4, 4, 5, 5, 3, 2, 2,
+	2, 2, 2, 2, 2, 2, 2, 2, 6, 6,
+	6, 6, 6, 7, 1,
 }
-var parserR2 = [...]int{
 
+var parserR2 = [...]int8{
 	0, 0, 1, 1, 2, 1, 1, 3, 3, 1,
 	1, 1, 1, 2, 3, 1, 4, 4, 0, 3,
-	1, 1,
+	3, 1, 1, 3, 1,
 }
-var parserChk = [...]int{
 
-	-1000, -7, -4, -5, -3, -2, 18, 4, -5, -1,
-	8, -4, 15, 16, 17, 13, 14, 5, 13, -1,
-	-1, 8, 11, -1, 9, -6, -1, -1, 9, 10,
-	12, -1,
+var parserChk = [...]int16{
+	-1000, -8, -4, -5, -1, -3, 19, 4, -5, -2,
+	8, -4, 16, 17, 18, 14, 15, 5, 14, -2,
+	-2, 8, 12, -2, 9, -6, -2, -7, 15, -2,
+	9, 10, 11, 13, -2, -7, -2,
 }
-var parserDef = [...]int{
 
-	1, -2, 2, 3, 5, 6, 21, 0, 4, 0,
+var parserDef = [...]int8{
+	1, -2, 2, 3, 5, 6, 24, 0, 4, 0,
 	0, 9, 10, 11, 12, 0, 15, 7, 0, 0,
-	13, 18, 0, 14, 8, 0, 20, 0, 16, 0,
-	17, 19,
+	13, 18, 0, 14, 8, 0, 21, 22, 15, 0,
+	16, 0, 0, 17, 19, 20, 23,
 }
-var parserTok1 = [...]int{
 
+var parserTok1 = [...]int8{
 	1,
 }
-var parserTok2 = [...]int{
 
+var parserTok2 = [...]int8{
 	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
-	12, 13, 14, 15, 16, 17, 18,
+	12, 13, 14, 15, 16, 17, 18, 19,
 }
-var parserTok3 = [...]int{
+
+var parserTok3 = [...]int8{
 	0,
 }
 
@@ -213,9 +238,9 @@ func parserErrorMessage(state, lookAhead int) string {
 	expected := make([]int, 0, 4)
 
 	// Look for shiftable tokens.
-	base := parserPact[state]
+	base := int(parserPact[state])
 	for tok := TOKSTART; tok-1 < len(parserToknames); tok++ {
-		if n := base + tok; n >= 0 && n < parserLast && parserChk[parserAct[n]] == tok {
+		if n := base + tok; n >= 0 && n < parserLast && int(parserChk[int(parserAct[n])]) == tok {
 			if len(expected) == cap(expected) {
 				return res
 			}
@@ -225,13 +250,13 @@ func parserErrorMessage(state, lookAhead int) string {
 
 	if parserDef[state] == -2 {
 		i := 0
-		for parserExca[i] != -1 || parserExca[i+1] != state {
+		for parserExca[i] != -1 || int(parserExca[i+1]) != state {
 			i += 2
 		}
 
 		// Look for tokens that we accept or reduce.
 		for i += 2; parserExca[i] >= 0; i += 2 {
-			tok := parserExca[i]
+			tok := int(parserExca[i])
 			if tok < TOKSTART || parserExca[i+1] == 0 {
 				continue
 			}
@@ -262,30 +287,30 @@ func parserlex1(lex parserLexer, lval *parserSymType) (char, token int) {
 	token = 0
 	char = lex.Lex(lval)
 	if char <= 0 {
-		token = parserTok1[0]
+		token = int(parserTok1[0])
 		goto out
 	}
 	if char < len(parserTok1) {
-		token = parserTok1[char]
+		token = int(parserTok1[char])
 		goto out
 	}
 	if char >= parserPrivate {
 		if char < parserPrivate+len(parserTok2) {
-			token = parserTok2[char-parserPrivate]
+			token = int(parserTok2[char-parserPrivate])
 			goto out
 		}
 	}
 	for i := 0; i < len(parserTok3); i += 2 {
-		token = parserTok3[i+0]
+		token = int(parserTok3[i+0])
 		if token == char {
-			token = parserTok3[i+1]
+			token = int(parserTok3[i+1])
 			goto out
 		}
 	}
 
 out:
 	if token == 0 {
-		token = parserTok2[1] /* unknown char */
+		token = int(parserTok2[1]) /* unknown char */
 	}
 	if parserDebug >= 3 {
 		__yyfmt__.Printf("lex %s(%d)\n", parserTokname(token), uint(char))
@@ -340,7 +365,7 @@ parserstack:
 	parserS[parserp].yys = parserstate
 
 parsernewstate:
-	parsern = parserPact[parserstate]
+	parsern = int(parserPact[parserstate])
 	if parsern <= parserFlag {
 		goto parserdefault /* simple state */
 	}
@@ -351,8 +376,8 @@ parsernewstate:
 	if parsern < 0 || parsern >= parserLast {
 		goto parserdefault
 	}
-	parsern = parserAct[parsern]
-	if parserChk[parsern] == parsertoken { /* valid shift */
+	parsern = int(parserAct[parsern])
+	if int(parserChk[parsern]) == parsertoken { /* valid shift */
 		parserrcvr.char = -1
 		parsertoken = -1
 		parserVAL = parserrcvr.lval
@@ -365,7 +390,7 @@ parsernewstate:
 
 parserdefault:
 	/* default state action */
-	parsern = parserDef[parserstate]
+	parsern = int(parserDef[parserstate])
 	if parsern == -2 {
 		if parserrcvr.char < 0 {
 			parserrcvr.char, parsertoken = parserlex1(parserlex, &parserrcvr.lval)
@@ -374,18 +399,18 @@ parserdefault:
 		/* look through exception table */
 		xi := 0
 		for {
-			if parserExca[xi+0] == -1 && parserExca[xi+1] == parserstate {
+			if parserExca[xi+0] == -1 && int(parserExca[xi+1]) == parserstate {
 				break
 			}
 			xi += 2
 		}
 		for xi += 2; ; xi += 2 {
-			parsern = parserExca[xi+0]
+			parsern = int(parserExca[xi+0])
 			if parsern < 0 || parsern == parsertoken {
 				break
 			}
 		}
-		parsern = parserExca[xi+1]
+		parsern = int(parserExca[xi+1])
 		if parsern < 0 {
 			goto ret0
 		}
@@ -407,10 +432,10 @@ parserdefault:
 
 			/* find a state where "error" is a legal shift action */
 			for parserp >= 0 {
-				parsern = parserPact[parserS[parserp].yys] + parserErrCode
+				parsern = int(parserPact[parserS[parserp].yys]) + parserErrCode
 				if parsern >= 0 && parsern < parserLast {
-					parserstate = parserAct[parsern] /* simulate a shift of "error" */
-					if parserChk[parserstate] == parserErrCode {
+					parserstate = int(parserAct[parsern]) /* simulate a shift of "error" */
+					if int(parserChk[parserstate]) == parserErrCode {
 						goto parserstack
 					}
 				}
@@ -446,7 +471,7 @@ parserdefault:
 	parserpt := parserp
 	_ = parserpt // guard against "declared and not used"
 
-	parserp -= parserR2[parsern]
+	parserp -= int(parserR2[parsern])
 	// parserp is now the index of $0. Perform the default action. Iff the
 	// reduced production is ε, $1 is possibly out of range.
 	if parserp+1 >= len(parserS) {
@@ -457,16 +482,16 @@ parserdefault:
 	parserVAL = parserS[parserp+1]
 
 	/* consult goto table to find next state */
-	parsern = parserR1[parsern]
-	parserg := parserPgo[parsern]
+	parsern = int(parserR1[parsern])
+	parserg := int(parserPgo[parsern])
 	parserj := parserg + parserS[parserp].yys + 1
 
 	if parserj >= parserLast {
-		parserstate = parserAct[parserg]
+		parserstate = int(parserAct[parserg])
 	} else {
-		parserstate = parserAct[parserj]
-		if parserChk[parserstate] != -parsern {
-			parserstate = parserAct[parserg]
+		parserstate = int(parserAct[parserj])
+		if int(parserChk[parserstate]) != -parsern {
+			parserstate = int(parserAct[parserg])
 		}
 	}
 	// dummy call; replaced with literal code
@@ -474,19 +499,20 @@ parserdefault:
 
 	case 1:
 		parserDollar = parserS[parserpt-0 : parserpt+1]
-		//line grammar.y:38
+//line grammar.y:61
 		{
-			parserResult = &ast.LiteralNode{
+			parserResult = parserLimits.add(&ast.LiteralNode{
 				Value: "",
 				Typex: ast.TString,
 				Posx:  ast.Pos{Column: 1, Line: 1},
-			}
+				Endx:  ast.Pos{Column: 1, Line: 1},
+			}, 1)
 		}
 	case 2:
 		parserDollar = parserS[parserpt-1 : parserpt+1]
-		//line grammar.y:46
+//line grammar.y:70
 		{
-			parserResult = parserDollar[1].node
+			parserResult = parserDollar[1].span.node
 
 			// We want to make sure that the top value is always an Output
 			// so that the return value is always a string, list of map from an
@@ -496,100 +522,112 @@ parserdefault:
 			// because functionally the AST is the same, but we do that because
 			// it makes for an easy literal check later (to check if a string
 			// has any interpolations).
-			if _, ok := parserDollar[1].node.(*ast.Output); !ok {
-				if n, ok := parserDollar[1].node.(*ast.LiteralNode); !ok || n.Typex != ast.TString {
-					parserResult = &ast.Output{
-						Exprs: []ast.Node{parserDollar[1].node},
-						Posx:  parserDollar[1].node.Pos(),
-					}
+			if _, ok := parserDollar[1].span.node.(*ast.Output); !ok {
+				if n, ok := parserDollar[1].span.node.(*ast.LiteralNode); !ok || n.Typex != ast.TString {
+					parserResult = parserLimits.add(&ast.Output{
+						Exprs: []ast.Node{parserDollar[1].span.node},
+						Posx:  parserDollar[1].span.pos,
+						Endx:  parserDollar[1].span.end,
+					}, 1)
 				}
 			}
 		}
 	case 3:
 		parserDollar = parserS[parserpt-1 : parserpt+1]
-		//line grammar.y:69
+//line grammar.y:94
 		{
-			parserVAL.node = parserDollar[1].node
+			parserVAL.span = parserDollar[1].span
 		}
 	case 4:
 		parserDollar = parserS[parserpt-2 : parserpt+1]
-		//line grammar.y:73
+//line grammar.y:98
 		{
+			// Adding to an Output replaces it, so there's no new node
 			var result []ast.Node
-			if c, ok := parserDollar[1].node.(*ast.Output); ok {
-				result = append(c.Exprs, parserDollar[2].node)
+			count := 0
+			if c, ok := parserDollar[1].span.node.(*ast.Output); ok {
+				result = append(c.Exprs, parserDollar[2].span.node)
 			} else {
-				result = []ast.Node{parserDollar[1].node, parserDollar[2].node}
+				result = []ast.Node{parserDollar[1].span.node, parserDollar[2].span.node}
+				count = 1
 			}
 
-			parserVAL.node = &ast.Output{
-				Exprs: result,
-				Posx:  result[0].Pos(),
+			parserVAL.span = span{
+				node: parserLimits.add(&ast.Output{
+					Exprs: result,
+					Posx:  parserDollar[1].span.pos,
+					Endx:  parserDollar[2].span.end,
+				}, count),
+				pos: parserDollar[1].span.pos,
+				end: parserDollar[2].span.end,
 			}
 		}
 	case 5:
 		parserDollar = parserS[parserpt-1 : parserpt+1]
-		//line grammar.y:89
+//line grammar.y:122
 		{
-			parserVAL.node = parserDollar[1].node
+			parserVAL.span = span{node: parserDollar[1].node, pos: parserDollar[1].node.Pos(), end: ast.End(parserDollar[1].node)}
 		}
 	case 6:
 		parserDollar = parserS[parserpt-1 : parserpt+1]
-		//line grammar.y:93
+//line grammar.y:126
 		{
-			parserVAL.node = parserDollar[1].node
+			parserVAL.span = parserDollar[1].span
 		}
 	case 7:
 		parserDollar = parserS[parserpt-3 : parserpt+1]
-		//line grammar.y:99
+//line grammar.y:132
 		{
-			parserVAL.node = parserDollar[2].node
+			parserVAL.span = span{node: parserDollar[2].span.node, pos: parserDollar[1].token.Pos, end: parserDollar[3].token.End}
 		}
 	case 8:
 		parserDollar = parserS[parserpt-3 : parserpt+1]
-		//line grammar.y:105
+//line grammar.y:138
 		{
-			parserVAL.node = parserDollar[2].node
+			parserVAL.span = span{node: parserDollar[2].span.node, pos: parserDollar[1].token.Pos, end: parserDollar[3].token.End}
 		}
 	case 9:
 		parserDollar = parserS[parserpt-1 : parserpt+1]
-		//line grammar.y:109
+//line grammar.y:142
 		{
-			parserVAL.node = parserDollar[1].node
+			parserVAL.span = parserDollar[1].span
 		}
 	case 10:
 		parserDollar = parserS[parserpt-1 : parserpt+1]
-		//line grammar.y:113
+//line grammar.y:146
 		{
-			parserVAL.node = &ast.LiteralNode{
+			parserVAL.span = nodeSpan(parserLimits.add(&ast.LiteralNode{
 				Value: parserDollar[1].token.Value.(int),
 				Typex: ast.TInt,
 				Posx:  parserDollar[1].token.Pos,
-			}
+				Endx:  parserDollar[1].token.End,
+			}, 1))
 		}
 	case 11:
 		parserDollar = parserS[parserpt-1 : parserpt+1]
-		//line grammar.y:121
+//line grammar.y:155
 		{
-			parserVAL.node = &ast.LiteralNode{
+			parserVAL.span = nodeSpan(parserLimits.add(&ast.LiteralNode{
 				Value: parserDollar[1].token.Value.(float64),
 				Typex: ast.TFloat,
 				Posx:  parserDollar[1].token.Pos,
-			}
+				Endx:  parserDollar[1].token.End,
+			}, 1))
 		}
 	case 12:
 		parserDollar = parserS[parserpt-1 : parserpt+1]
-		//line grammar.y:129
+//line grammar.y:164
 		{
-			parserVAL.node = &ast.LiteralNode{
+			parserVAL.span = nodeSpan(parserLimits.add(&ast.LiteralNode{
 				Value: parserDollar[1].token.Value.(bool),
 				Typex: ast.TBool,
 				Posx:  parserDollar[1].token.Pos,
-			}
+				Endx:  parserDollar[1].token.End,
+			}, 1))
 		}
 	case 13:
 		parserDollar = parserS[parserpt-2 : parserpt+1]
-		//line grammar.y:137
+//line grammar.y:173
 		{
 			// This is REALLY jank. We assume that a singular ARITH_OP
 			// means 0 ARITH_OP expr, which... is weird. We don't want to
@@ -597,81 +635,143 @@ parserdefault:
 			// Go scanner/parser.
 			if parserDollar[1].token.Value.(ast.ArithmeticOp) != ast.ArithmeticOpSub {
 				if parserErr == nil {
-					parserErr = fmt.Errorf("Invalid unary operation: %v", parserDollar[1].token.Value)
+					parserErr = &ParseError{
+						Pos: parserDollar[1].token.Pos,
+						End: parserDollar[1].token.End,
+						Msg: fmt.Sprintf("Invalid unary operation: %v", parserDollar[1].token.Value),
+					}
 				}
 			}
 
-			parserVAL.node = &ast.Arithmetic{
+			parserVAL.span = nodeSpan(parserLimits.add(&ast.Arithmetic{
 				Op: parserDollar[1].token.Value.(ast.ArithmeticOp),
 				Exprs: []ast.Node{
-					&ast.LiteralNode{Value: 0, Typex: ast.TInt},
-					parserDollar[2].node,
+					parserLimits.add(&ast.LiteralNode{
+						Value: 0,
+						Typex: ast.TInt,
+						Posx:  parserDollar[1].token.Pos,
+						Endx:  parserDollar[1].token.End,
+					}, 1),
+					parserDollar[2].span.node,
 				},
-				Posx: parserDollar[2].node.Pos(),
-			}
+				Posx: parserDollar[1].token.Pos,
+				Endx: parserDollar[2].span.end,
+			}, 1))
 		}
 	case 14:
 		parserDollar = parserS[parserpt-3 : parserpt+1]
-		//line grammar.y:158
+//line grammar.y:204
 		{
-			parserVAL.node = &ast.Arithmetic{
+			parserVAL.span = nodeSpan(parserLimits.add(&ast.Arithmetic{
 				Op:    parserDollar[2].token.Value.(ast.ArithmeticOp),
-				Exprs: []ast.Node{parserDollar[1].node, parserDollar[3].node},
-				Posx:  parserDollar[1].node.Pos(),
-			}
+				Exprs: []ast.Node{parserDollar[1].span.node, parserDollar[3].span.node},
+				Posx:  parserDollar[1].span.pos,
+				Endx:  parserDollar[3].span.end,
+			}, 1))
 		}
 	case 15:
 		parserDollar = parserS[parserpt-1 : parserpt+1]
-		//line grammar.y:166
+//line grammar.y:213
 		{
-			parserVAL.node = &ast.VariableAccess{Name: parserDollar[1].token.Value.(string), Posx: parserDollar[1].token.Pos}
+			parserVAL.span = nodeSpan(parserLimits.add(&ast.VariableAccess{
+				Name: parserDollar[1].token.Value.(string),
+				Posx: parserDollar[1].token.Pos,
+				Endx: parserDollar[1].token.End,
+			}, 1))
 		}
 	case 16:
 		parserDollar = parserS[parserpt-4 : parserpt+1]
-		//line grammar.y:170
+//line grammar.y:221
 		{
-			parserVAL.node = &ast.Call{Func: parserDollar[1].token.Value.(string), Args: parserDollar[3].nodeList, Posx: parserDollar[1].token.Pos}
+			parserVAL.span = nodeSpan(parserLimits.add(&ast.Call{
+				Func:      parserDollar[1].token.Value.(string),
+				Args:      parserDollar[3].callArgs.positional,
+				NamedArgs: parserDollar[3].callArgs.named,
+				Posx:      parserDollar[1].token.Pos,
+				Endx:      parserDollar[4].token.End,
+			}, 1))
 		}
 	case 17:
 		parserDollar = parserS[parserpt-4 : parserpt+1]
-		//line grammar.y:174
+//line grammar.y:231
 		{
-			parserVAL.node = &ast.Index{
-				Target: &ast.VariableAccess{
+			parserVAL.span = nodeSpan(parserLimits.add(&ast.Index{
+				Target: parserLimits.add(&ast.VariableAccess{
 					Name: parserDollar[1].token.Value.(string),
 					Posx: parserDollar[1].token.Pos,
-				},
-				Key:  parserDollar[3].node,
+					Endx: parserDollar[1].token.End,
+				}, 1),
+				Key:  parserDollar[3].span.node,
 				Posx: parserDollar[1].token.Pos,
-			}
+				Endx: parserDollar[4].token.End,
+			}, 1))
 		}
 	case 18:
 		parserDollar = parserS[parserpt-0 : parserpt+1]
-		//line grammar.y:186
+//line grammar.y:245
 		{
-			parserVAL.nodeList = nil
+			parserVAL.callArgs = callArgs{}
 		}
 	case 19:
 		parserDollar = parserS[parserpt-3 : parserpt+1]
-		//line grammar.y:190
+//line grammar.y:249
 		{
-			parserVAL.nodeList = append(parserDollar[1].nodeList, parserDollar[3].node)
+			if len(parserDollar[1].callArgs.named) > 0 && parserErr == nil {
+				parserErr = &ParseError{
+					Pos: parserDollar[3].span.pos,
+					End: parserDollar[3].span.end,
+					Msg: "positional argument after named argument",
+					Related: &Related{
+						Pos:     parserDollar[1].callArgs.named[0].Pos(),
+						End:     parserDollar[1].callArgs.named[0].End(),
+						Message: "the first named argument is here",
+					},
+				}
+			}
+
+			parserVAL.callArgs = parserDollar[1].callArgs
+			parserVAL.callArgs.positional = append(parserVAL.callArgs.positional, parserDollar[3].span.node)
 		}
 	case 20:
-		parserDollar = parserS[parserpt-1 : parserpt+1]
-		//line grammar.y:194
+		parserDollar = parserS[parserpt-3 : parserpt+1]
+//line grammar.y:267
 		{
-			parserVAL.nodeList = append(parserVAL.nodeList, parserDollar[1].node)
+			parserVAL.callArgs = parserDollar[1].callArgs
+			parserVAL.callArgs.named = append(parserVAL.callArgs.named, parserDollar[3].namedArg)
 		}
 	case 21:
 		parserDollar = parserS[parserpt-1 : parserpt+1]
-		//line grammar.y:200
+//line grammar.y:272
 		{
-			parserVAL.node = &ast.LiteralNode{
+			parserVAL.callArgs = callArgs{positional: []ast.Node{parserDollar[1].span.node}}
+		}
+	case 22:
+		parserDollar = parserS[parserpt-1 : parserpt+1]
+//line grammar.y:276
+		{
+			parserVAL.callArgs = callArgs{named: []*ast.NamedArg{parserDollar[1].namedArg}}
+		}
+	case 23:
+		parserDollar = parserS[parserpt-3 : parserpt+1]
+//line grammar.y:282
+		{
+			parserVAL.namedArg = &ast.NamedArg{
+				Name:  parserDollar[1].token.Value.(string),
+				Value: parserDollar[3].span.node,
+				Posx:  parserDollar[1].token.Pos,
+				Endx:  parserDollar[3].span.end,
+			}
+		}
+	case 24:
+		parserDollar = parserS[parserpt-1 : parserpt+1]
+//line grammar.y:293
+		{
+			parserVAL.node = parserLimits.add(&ast.LiteralNode{
 				Value: parserDollar[1].token.Value.(string),
 				Typex: ast.TString,
 				Posx:  parserDollar[1].token.Pos,
-			}
+				Endx:  parserDollar[1].token.End,
+			}, 1)
 		}
 	}
 	goto parserstack /* stack new state and value */